@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"donfra-api/internal/config"
+	"donfra-api/internal/domain/aiagent"
+	"donfra-api/internal/domain/db"
+	"donfra-api/internal/domain/execution"
+	"donfra-api/internal/domain/google"
+	"donfra-api/internal/domain/interview"
+	"donfra-api/internal/domain/livekit"
+	"donfra-api/internal/domain/quota"
+	"donfra-api/internal/domain/runner"
+	"donfra-api/internal/domain/study"
+	"donfra-api/internal/domain/user"
+	"donfra-api/internal/http/router"
+	"donfra-api/internal/pkg/tracing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	cfg := config.Load()
+
+	// Initialize Jaeger tracing
+	shutdown, err := tracing.InitTracer("donfra-api", cfg.JaegerEndpoint)
+	if err != nil {
+		log.Fatalf("failed to initialize tracer: %v", err)
+	}
+	defer func() {
+		if err := shutdown(context.Background()); err != nil {
+			log.Printf("failed to shutdown tracer: %v", err)
+		}
+	}()
+
+	conn, err := db.InitFromEnv()
+	if err != nil {
+		log.Fatalf("failed to initialize database: %v", err)
+	}
+
+	// Initialize Redis client (optional)
+	var redisClient *redis.Client
+	if cfg.UseRedis && cfg.RedisAddr != "" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr: cfg.RedisAddr,
+		})
+		// Test Redis connection
+		if err := redisClient.Ping(context.Background()).Err(); err != nil {
+			log.Fatalf("failed to connect to Redis at %s: %v", cfg.RedisAddr, err)
+		}
+		log.Printf("[donfra-api] connected to Redis at %s", cfg.RedisAddr)
+	}
+
+	studySvc := study.NewService(conn)
+
+	// Initialize user service with PostgreSQL repository
+	userRepo := user.NewPostgresRepository(conn)
+	userSvc := user.NewService(userRepo, cfg.JWTSecret, cfg.JWTExpiryHours, cfg.CookieMaxAgeDays)
+	log.Printf("[donfra-api] user service initialized (JWT expiry: %d hours, cookie: %d days)", cfg.JWTExpiryHours, cfg.CookieMaxAgeDays)
+
+	// Initialize interview room service with PostgreSQL repository
+	interviewRepo := interview.NewRepository(conn)
+	interviewSvc := interview.NewService(interviewRepo, cfg.JWTSecret, cfg.BaseURL, cfg.InviteTokenExpiryHours)
+	log.Printf("[donfra-api] interview room service initialized (invite token expiry: %d hours)", cfg.InviteTokenExpiryHours)
+
+	// Initialize LiveKit service (use PublicURL for client connections)
+	livekitSvc := livekit.NewService(cfg.LiveKitAPIKey, cfg.LiveKitAPISecret, cfg.LiveKitPublicURL, cfg.LiveKitTokenExpiryHours, redisClient)
+	log.Printf("[donfra-api] livekit service initialized (token expiry: %d hours)", cfg.LiveKitTokenExpiryHours)
+
+	// Initialize Google OAuth service
+	googleClientID := os.Getenv("GOOGLE_CLIENT_ID")
+	googleClientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
+	googleRedirectURL := os.Getenv("GOOGLE_REDIRECT_URL")
+	if googleRedirectURL == "" {
+		googleRedirectURL = "http://localhost:8080/api/auth/google/callback"
+	}
+	googleSvc := google.NewGoogleOAuthService(googleClientID, googleClientSecret, googleRedirectURL, cfg.FrontendURL, redisClient, cfg.OAuthStateExpiryMins)
+	if redisClient != nil {
+		log.Printf("[donfra-api] google oauth service initialized with Redis (state expiry: %d mins)", cfg.OAuthStateExpiryMins)
+	} else {
+		log.Printf("[donfra-api] google oauth service initialized with in-memory storage (state expiry: %d mins)", cfg.OAuthStateExpiryMins)
+	}
+
+	// Initialize AI agent service
+	deepSeekAPIKey := os.Getenv("DEEPSEEK_API_KEY")
+	if deepSeekAPIKey == "" {
+		deepSeekAPIKey = "91" // Default API key
+	}
+	aiAgentSvc := aiagent.NewService(deepSeekAPIKey)
+	log.Println("[donfra-api] AI agent service initialized")
+
+	// Initialize runner client. RUNNER_URL=fake serves a scripted fake runner
+	// from this process for local development; no code runs.
+	runnerURL := cfg.RunnerURL
+	if runnerURL == "fake" {
+		var script runner.FakeScript
+		if cfg.RunnerFakeScript != "" {
+			if script, err = runner.LoadFakeScript(cfg.RunnerFakeScript); err != nil {
+				log.Fatalf("[donfra-api] failed to load fake runner script: %v", err)
+			}
+		}
+		if runnerURL, err = runner.StartFakeRunner(script); err != nil {
+			log.Fatalf("[donfra-api] failed to start fake runner: %v", err)
+		}
+		log.Printf("[donfra-api] fake runner started (%d rules)", len(script.Rules))
+	}
+	runnerClient := runner.NewClient(runnerURL, cfg.RunnerAPIToken)
+	log.Printf("[donfra-api] runner client initialized (url: %s)", runnerURL)
+
+	// Initialize per-user execution quotas
+	quotaLimits, err := quota.ParseLimits(quota.DefaultLimits, cfg.ExecQuotaPerMinute, cfg.ExecQuotaCPUSecondsPerDay)
+	if err != nil {
+		log.Fatalf("invalid execution quota config: %v", err)
+	}
+	quotaSvc := quota.NewService(quotaLimits, redisClient)
+	if redisClient != nil {
+		log.Println("[donfra-api] execution quota service initialized with Redis")
+	} else {
+		log.Println("[donfra-api] execution quota service initialized with in-memory counters")
+	}
+
+	executionSvc := execution.NewService(conn)
+
+	// Start background cleanup of empty rooms every 30 seconds
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := livekitSvc.CleanupEmptyRooms(context.Background()); err != nil {
+				log.Printf("[livekit] cleanup error: %v", err)
+			}
+		}
+	}()
+
+	r := router.New(cfg, studySvc, userSvc, googleSvc, interviewSvc, livekitSvc, aiAgentSvc, runnerClient, quotaSvc, executionSvc)
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	// Graceful shutdown
+	go func() {
+		log.Printf("[donfra-api] listening on %s", cfg.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("[donfra-api] shutting down gracefully...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Fatalf("server forced to shutdown: %v", err)
+	}
+
+	// Close Redis connection if open
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			log.Printf("[donfra-api] error closing Redis: %v", err)
+		}
+	}
+
+	log.Println("[donfra-api] server exited")
+}
@@ -20,6 +20,12 @@ type Config struct {
 	LiveKitServerURL     string
 	LiveKitPublicURL     string
 	RunnerURL            string
+	RunnerAPIToken       string
+	RunnerFakeScript     string
+
+	// Execution quota overrides as "role:n,role:n" lists (empty = built-in defaults)
+	ExecQuotaPerMinute        string
+	ExecQuotaCPUSecondsPerDay string
 
 	// Token expiration settings
 	JWTExpiryHours          int // User JWT token expiry in hours (default: 168 = 7 days)
@@ -61,6 +67,11 @@ func Load() Config {
 		LiveKitServerURL: getenv("LIVEKIT_SERVER_URL", "ws://livekit:7880"),
 		LiveKitPublicURL: getenv("LIVEKIT_PUBLIC_URL", "/livekit"),
 		RunnerURL:        getenv("RUNNER_URL", "http://runner:8090"),
+		RunnerAPIToken:   getenv("RUNNER_API_TOKEN", ""), // shared secret sent as a Bearer token to the runner
+		RunnerFakeScript: getenv("RUNNER_FAKE_SCRIPT", ""), // with RUNNER_URL=fake: JSON rules for the embedded fake runner
+
+		ExecQuotaPerMinute:        getenv("EXEC_QUOTA_PER_MINUTE", ""),          // e.g., "user:10,vip:30,admin:120"
+		ExecQuotaCPUSecondsPerDay: getenv("EXEC_QUOTA_CPU_SECONDS_PER_DAY", ""), // e.g., "user:300,vip:1800"
 
 		// Token expiration settings
 		JWTExpiryHours:          getenvInt("JWT_EXPIRY_HOURS", 168),          // 7 days
@@ -0,0 +1,123 @@
+package execution
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+	"unicode/utf8"
+
+	"donfra-api/internal/domain/runner"
+)
+
+// maxStoredTextBytes caps each stored stdin/output field; longer text is cut
+// and marked truncated.
+const maxStoredTextBytes = 4096
+
+// Execution is one code run recorded for history browsing.
+type Execution struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	UserID            uint      `gorm:"not null;index" json:"user_id"`
+	RoomID            string    `gorm:"size:255;index" json:"room_id,omitempty"`
+	LessonSlug        string    `gorm:"size:255;index" json:"lesson_slug,omitempty"`
+	Token             string    `gorm:"size:64;index" json:"token,omitempty"` // runner submission token, for async runs
+	LanguageID        int       `gorm:"not null" json:"language_id"`
+	SourceHash        string    `gorm:"size:64;not null" json:"source_hash"`
+	Stdin             string    `gorm:"type:text" json:"stdin,omitempty"`
+	StatusID          int       `gorm:"not null" json:"status_id"`
+	StatusDescription string    `gorm:"size:64" json:"status_description"`
+	ExecutionTimeMs   int64     `json:"execution_time_ms"`
+	Stdout            string    `gorm:"type:text" json:"stdout,omitempty"`
+	Stderr            string    `gorm:"type:text" json:"stderr,omitempty"`
+	CompileOutput     string    `gorm:"type:text" json:"compile_output,omitempty"`
+	Message           string    `gorm:"type:text" json:"message,omitempty"`
+	TestCasesTotal    int       `json:"test_cases_total,omitempty"`
+	TestCasesPassed   int       `json:"test_cases_passed,omitempty"`
+	Truncated         bool      `gorm:"not null;default:false" json:"truncated"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Execution) TableName() string {
+	return "executions"
+}
+
+// Context is where an execution was run from; both fields are optional.
+type Context struct {
+	RoomID     string `json:"room_id,omitempty"`
+	LessonSlug string `json:"lesson_slug,omitempty"`
+}
+
+// Filter narrows a history query; zero fields match everything.
+type Filter struct {
+	UserID     uint
+	RoomID     string
+	LessonSlug string
+	LanguageID int
+}
+
+// PaginationParams represents pagination parameters for listing executions.
+type PaginationParams struct {
+	Page int // 1-based page number
+	Size int // number of items per page
+}
+
+// PaginatedExecutionsResponse represents a paginated list of executions, newest first.
+type PaginatedExecutionsResponse struct {
+	Executions []Execution `json:"executions"`
+	Total      int64       `json:"total"`      // total number of items
+	Page       int         `json:"page"`       // current page (1-based)
+	Size       int         `json:"size"`       // items per page
+	TotalPages int         `json:"totalPages"` // total number of pages
+}
+
+// New builds the history record of a request. The result may be nil for a
+// submission that has not finished yet; see Apply.
+func New(userID uint, execCtx Context, req runner.ExecuteRequest, result *runner.ExecuteResult) *Execution {
+	sum := sha256.Sum256([]byte(req.SourceCode))
+	e := &Execution{
+		UserID:            userID,
+		RoomID:            execCtx.RoomID,
+		LessonSlug:        execCtx.LessonSlug,
+		LanguageID:        req.LanguageID,
+		SourceHash:        hex.EncodeToString(sum[:]),
+		StatusID:          runner.StatusInQueue,
+		StatusDescription: "In Queue",
+		TestCasesTotal:    len(req.TestCases),
+	}
+	e.Stdin = e.clip(req.Stdin)
+	if result != nil {
+		e.Apply(*result)
+	}
+	return e
+}
+
+// Apply copies the outcome of a finished run into e.
+func (e *Execution) Apply(result runner.ExecuteResult) {
+	e.StatusID = result.Status.ID
+	e.StatusDescription = result.Status.Description
+	e.ExecutionTimeMs = result.ExecutionTimeMs
+	e.Stdout = e.clip(result.Stdout)
+	e.Stderr = e.clip(result.Stderr)
+	e.CompileOutput = e.clip(result.CompileOutput)
+	e.Message = e.clip(result.Message)
+
+	e.TestCasesPassed = 0
+	for _, tc := range result.TestResults {
+		if tc.Status.ID == runner.StatusAccepted {
+			e.TestCasesPassed++
+		}
+	}
+}
+
+// clip cuts s to maxStoredTextBytes on a UTF-8 boundary, flagging e as truncated.
+func (e *Execution) clip(s string) string {
+	if len(s) <= maxStoredTextBytes {
+		return s
+	}
+	e.Truncated = true
+	cut := maxStoredTextBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
@@ -0,0 +1,124 @@
+package execution
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"donfra-api/internal/domain/runner"
+	"donfra-api/internal/pkg/tracing"
+)
+
+// Service stores and queries execution history.
+type Service struct {
+	db *gorm.DB
+}
+
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Record inserts a new execution.
+func (s *Service) Record(ctx context.Context, e *Execution) error {
+	ctx, span := tracing.StartSpan(ctx, "execution.Record",
+		tracing.AttrDBOperation.String("INSERT"),
+		tracing.AttrDBTable.String("executions"),
+	)
+	defer span.End()
+
+	if err := s.db.WithContext(ctx).Create(e).Error; err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// CompleteSubmission fills in the outcome of an asynchronous submission. It
+// only touches executions still queued or processing, so repeated polls of a
+// finished submission are no-ops.
+func (s *Service) CompleteSubmission(ctx context.Context, token string, result runner.ExecuteResult) error {
+	ctx, span := tracing.StartSpan(ctx, "execution.CompleteSubmission",
+		tracing.AttrDBOperation.String("UPDATE"),
+		tracing.AttrDBTable.String("executions"),
+	)
+	defer span.End()
+
+	var e Execution
+	err := s.db.WithContext(ctx).
+		Where("token = ? AND status_id <= ?", token, runner.StatusProcessing).
+		Limit(1).
+		Find(&e).Error
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	if e.ID == 0 {
+		return nil
+	}
+
+	e.Apply(result)
+	if err := s.db.WithContext(ctx).Save(&e).Error; err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// List returns executions matching filter, newest first.
+func (s *Service) List(ctx context.Context, filter Filter, params PaginationParams) (*PaginatedExecutionsResponse, error) {
+	ctx, span := tracing.StartSpan(ctx, "execution.List",
+		tracing.AttrDBOperation.String("SELECT"),
+		tracing.AttrDBTable.String("executions"),
+	)
+	defer span.End()
+
+	// Validate and set defaults
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.Size < 1 || params.Size > 100 {
+		params.Size = 20
+	}
+
+	baseQuery := s.db.WithContext(ctx).Model(&Execution{})
+	if filter.UserID != 0 {
+		baseQuery = baseQuery.Where("user_id = ?", filter.UserID)
+	}
+	if filter.RoomID != "" {
+		baseQuery = baseQuery.Where("room_id = ?", filter.RoomID)
+	}
+	if filter.LessonSlug != "" {
+		baseQuery = baseQuery.Where("lesson_slug = ?", filter.LessonSlug)
+	}
+	if filter.LanguageID != 0 {
+		baseQuery = baseQuery.Where("language_id = ?", filter.LanguageID)
+	}
+
+	// Count total items with filter
+	var total int64
+	if err := baseQuery.Count(&total).Error; err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	offset := (params.Page - 1) * params.Size
+	totalPages := int((total + int64(params.Size) - 1) / int64(params.Size))
+
+	executions := []Execution{}
+	if err := baseQuery.
+		Order("created_at DESC, id DESC").
+		Limit(params.Size).
+		Offset(offset).
+		Find(&executions).Error; err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	return &PaginatedExecutionsResponse{
+		Executions: executions,
+		Total:      total,
+		Page:       params.Page,
+		Size:       params.Size,
+		TotalPages: totalPages,
+	}, nil
+}
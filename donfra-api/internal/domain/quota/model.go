@@ -0,0 +1,101 @@
+package quota
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits are the execution quotas of a role. Zero means unlimited.
+type Limits struct {
+	ExecutionsPerMinute int
+	CPUSecondsPerDay    float64
+}
+
+// DefaultLimits are the quotas per user role; unknown roles get the "user" limits.
+var DefaultLimits = map[string]Limits{
+	"user":  {ExecutionsPerMinute: 10, CPUSecondsPerDay: 300},
+	"vip":   {ExecutionsPerMinute: 30, CPUSecondsPerDay: 1800},
+	"admin": {ExecutionsPerMinute: 120},
+	"god":   {},
+}
+
+// Decision is the outcome of reserving executions against a user's quota.
+type Decision struct {
+	Allowed bool
+	Reason  string // set when Allowed is false
+
+	// Oversized is set when n alone exceeds Limit, so the reservation can
+	// never succeed and retrying is pointless.
+	Oversized bool
+
+	// Per-minute execution window; Limit is 0 when unlimited.
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+
+	// Daily CPU budget in seconds; CPULimit is 0 when unlimited.
+	CPULimit     float64
+	CPURemaining float64
+
+	// RetryAfter is how long until the exhausted quota resets.
+	RetryAfter time.Duration
+}
+
+// ParseLimits overrides base with per-role values from "role:n,role:n" lists,
+// as read from EXEC_QUOTA_PER_MINUTE and EXEC_QUOTA_CPU_SECONDS_PER_DAY.
+// Empty strings leave base unchanged.
+func ParseLimits(base map[string]Limits, perMinute, cpuSecondsPerDay string) (map[string]Limits, error) {
+	limits := make(map[string]Limits, len(base))
+	for role, l := range base {
+		limits[role] = l
+	}
+
+	err := parseRoleValues(perMinute, func(role, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid executions per minute %q for role %q", value, role)
+		}
+		l := limits[role]
+		l.ExecutionsPerMinute = n
+		limits[role] = l
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseRoleValues(cpuSecondsPerDay, func(role, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			return fmt.Errorf("invalid CPU seconds per day %q for role %q", value, role)
+		}
+		l := limits[role]
+		l.CPUSecondsPerDay = f
+		limits[role] = l
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+func parseRoleValues(s string, set func(role, value string) error) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			return fmt.Errorf("invalid quota entry %q (want role:value)", entry)
+		}
+		if err := set(strings.TrimSpace(role), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Service enforces per-user execution quotas: a fixed one-minute window of
+// executions and a daily CPU-seconds budget that resets at midnight UTC.
+type Service struct {
+	limits      map[string]Limits
+	redisClient *redis.Client // Redis client for counters (nil = use in-memory)
+
+	// In-memory counters (fallback when Redis is not available)
+	counters   map[string]*counter
+	countersMu *sync.Mutex
+
+	now func() time.Time // time.Now, replaced in tests
+}
+
+type counter struct {
+	value     float64
+	expiresAt time.Time
+}
+
+// NewService creates a quota service. Counters live in Redis when redisClient
+// is set, so limits hold across API replicas; otherwise they are per process.
+func NewService(limits map[string]Limits, redisClient *redis.Client) *Service {
+	svc := &Service{
+		limits:      limits,
+		redisClient: redisClient,
+		counters:    make(map[string]*counter),
+		countersMu:  &sync.Mutex{},
+		now:         time.Now,
+	}
+
+	// Start cleanup goroutine for expired counters (only for in-memory mode)
+	if redisClient == nil {
+		go svc.cleanupExpiredCounters()
+	}
+
+	return svc
+}
+
+// Reserve charges n executions to the user's per-minute window. It is denied
+// when the window is full or the daily CPU budget is spent, and marked
+// Oversized when n is more than the window can ever hold; denied requests do
+// not count against the window.
+func (s *Service) Reserve(ctx context.Context, userID uint, role string, n int) (Decision, error) {
+	lim := s.limitsFor(role)
+	now := s.now()
+	window := now.Truncate(time.Minute)
+	key := execKey(userID, window)
+	d := Decision{
+		Allowed:  true,
+		Limit:    lim.ExecutionsPerMinute,
+		ResetAt:  window.Add(time.Minute),
+		CPULimit: lim.CPUSecondsPerDay,
+	}
+
+	if lim.ExecutionsPerMinute > 0 && n > lim.ExecutionsPerMinute {
+		d.Allowed = false
+		d.Oversized = true
+		d.Reason = fmt.Sprintf("at most %d executions per request", lim.ExecutionsPerMinute)
+		return d, nil
+	}
+
+	if lim.CPUSecondsPerDay > 0 {
+		used, err := s.get(ctx, cpuKey(userID, now))
+		if err != nil {
+			return d, err
+		}
+		d.CPURemaining = max(lim.CPUSecondsPerDay-used, 0)
+		if d.CPURemaining == 0 {
+			count, err := s.get(ctx, key)
+			if err != nil {
+				return d, err
+			}
+			d.Allowed = false
+			d.Reason = "daily CPU quota exceeded"
+			d.Remaining = max(lim.ExecutionsPerMinute-int(count), 0)
+			d.RetryAfter = nextDay(now).Sub(now)
+			return d, nil
+		}
+	}
+
+	if lim.ExecutionsPerMinute > 0 {
+		count, err := s.incr(ctx, key, float64(n), 2*time.Minute)
+		if err != nil {
+			return d, err
+		}
+		if int(count) > lim.ExecutionsPerMinute {
+			_, _ = s.incr(ctx, key, -float64(n), 2*time.Minute)
+			d.Allowed = false
+			d.Reason = "execution rate limit exceeded"
+			d.Remaining = max(lim.ExecutionsPerMinute-int(count)+n, 0)
+			d.RetryAfter = d.ResetAt.Sub(now)
+			return d, nil
+		}
+		d.Remaining = lim.ExecutionsPerMinute - int(count)
+	}
+
+	return d, nil
+}
+
+// RecordUsage adds CPU time to the user's daily budget.
+func (s *Service) RecordUsage(ctx context.Context, userID uint, cpu time.Duration) error {
+	if cpu <= 0 {
+		return nil
+	}
+	_, err := s.incr(ctx, cpuKey(userID, s.now()), cpu.Seconds(), 48*time.Hour)
+	return err
+}
+
+// RecordUsageOnce is RecordUsage for results that may be seen more than once,
+// such as a polled submission; usageKey identifies the result.
+func (s *Service) RecordUsageOnce(ctx context.Context, userID uint, usageKey string, cpu time.Duration) error {
+	first, err := s.setOnce(ctx, "quota:charged:"+usageKey, 24*time.Hour)
+	if err != nil || !first {
+		return err
+	}
+	return s.RecordUsage(ctx, userID, cpu)
+}
+
+func (s *Service) limitsFor(role string) Limits {
+	if l, ok := s.limits[role]; ok {
+		return l
+	}
+	return s.limits["user"]
+}
+
+func execKey(userID uint, window time.Time) string {
+	return fmt.Sprintf("quota:exec:%d:%d", userID, window.Unix())
+}
+
+func cpuKey(userID uint, now time.Time) string {
+	return fmt.Sprintf("quota:cpu:%d:%s", userID, now.UTC().Format("2006-01-02"))
+}
+
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// incr adds delta to the counter at key and returns the new value.
+func (s *Service) incr(ctx context.Context, key string, delta float64, ttl time.Duration) (float64, error) {
+	if s.redisClient != nil {
+		pipe := s.redisClient.TxPipeline()
+		val := pipe.IncrByFloat(ctx, key, delta)
+		pipe.Expire(ctx, key, ttl)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, fmt.Errorf("failed to update quota counter: %w", err)
+		}
+		return val.Val(), nil
+	}
+
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+	c, ok := s.counters[key]
+	if !ok || s.now().After(c.expiresAt) {
+		c = &counter{}
+		s.counters[key] = c
+	}
+	c.value += delta
+	c.expiresAt = s.now().Add(ttl)
+	return c.value, nil
+}
+
+func (s *Service) get(ctx context.Context, key string) (float64, error) {
+	if s.redisClient != nil {
+		v, err := s.redisClient.Get(ctx, key).Result()
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read quota counter: %w", err)
+		}
+		return strconv.ParseFloat(v, 64)
+	}
+
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+	if c, ok := s.counters[key]; ok && s.now().Before(c.expiresAt) {
+		return c.value, nil
+	}
+	return 0, nil
+}
+
+// setOnce reports whether key was newly set.
+func (s *Service) setOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if s.redisClient != nil {
+		ok, err := s.redisClient.SetNX(ctx, key, 1, ttl).Result()
+		if err != nil {
+			return false, fmt.Errorf("failed to mark quota usage: %w", err)
+		}
+		return ok, nil
+	}
+
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+	if c, ok := s.counters[key]; ok && s.now().Before(c.expiresAt) {
+		return false, nil
+	}
+	s.counters[key] = &counter{value: 1, expiresAt: s.now().Add(ttl)}
+	return true, nil
+}
+
+// cleanupExpiredCounters periodically removes expired in-memory counters.
+func (s *Service) cleanupExpiredCounters() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := s.now()
+		s.countersMu.Lock()
+		for key, c := range s.counters {
+			if now.After(c.expiresAt) {
+				delete(s.counters, key)
+			}
+		}
+		s.countersMu.Unlock()
+	}
+}
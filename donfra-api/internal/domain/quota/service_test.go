@@ -0,0 +1,135 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for Service.now.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestService(limits map[string]Limits, start time.Time) (*Service, *fakeClock) {
+	clock := &fakeClock{t: start}
+	svc := NewService(limits, nil)
+	svc.now = clock.now
+	return svc, clock
+}
+
+func TestReserveWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 30, 0, time.UTC)
+	svc, clock := newTestService(map[string]Limits{"user": {ExecutionsPerMinute: 3}}, start)
+
+	steps := []struct {
+		name          string
+		at            time.Duration // after start
+		n             int
+		wantAllowed   bool
+		wantOversized bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "first batch", at: 0, n: 2, wantAllowed: true, wantRemaining: 1},
+		{name: "over the window", at: 10 * time.Second, n: 2, wantRemaining: 1, wantRetry: 20 * time.Second},
+		{name: "denied batch was not counted", at: 10 * time.Second, n: 1, wantAllowed: true, wantRemaining: 0},
+		{name: "full until the window ends", at: 29 * time.Second, n: 1, wantRemaining: 0, wantRetry: time.Second},
+		{name: "next window", at: 30 * time.Second, n: 1, wantAllowed: true, wantRemaining: 2},
+		{name: "more than a window holds", at: 31 * time.Second, n: 4, wantOversized: true},
+		{name: "oversized batch was not counted", at: 31 * time.Second, n: 2, wantAllowed: true, wantRemaining: 0},
+	}
+	for _, step := range steps {
+		clock.t = start.Add(step.at)
+		d, err := svc.Reserve(context.Background(), 1, "user", step.n)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if d.Allowed != step.wantAllowed || d.Oversized != step.wantOversized {
+			t.Errorf("%s: allowed %v oversized %v (%s), want %v %v", step.name, d.Allowed, d.Oversized, d.Reason, step.wantAllowed, step.wantOversized)
+		}
+		if !step.wantOversized && d.Remaining != step.wantRemaining {
+			t.Errorf("%s: remaining %d, want %d", step.name, d.Remaining, step.wantRemaining)
+		}
+		if d.RetryAfter != step.wantRetry {
+			t.Errorf("%s: retry after %v, want %v", step.name, d.RetryAfter, step.wantRetry)
+		}
+		if want := clock.t.Truncate(time.Minute).Add(time.Minute); !d.ResetAt.Equal(want) {
+			t.Errorf("%s: reset at %v, want %v", step.name, d.ResetAt, want)
+		}
+	}
+}
+
+func TestReserveWindowIsPerUser(t *testing.T) {
+	svc, _ := newTestService(map[string]Limits{"user": {ExecutionsPerMinute: 1}}, time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	for _, userID := range []uint{1, 2} {
+		if d, _ := svc.Reserve(ctx, userID, "user", 1); !d.Allowed {
+			t.Errorf("user %d: first execution denied: %s", userID, d.Reason)
+		}
+	}
+	if d, _ := svc.Reserve(ctx, 1, "user", 1); d.Allowed {
+		t.Error("user 1: second execution allowed")
+	}
+}
+
+func TestReserveDailyCPU(t *testing.T) {
+	start := time.Date(2026, 1, 1, 22, 0, 0, 0, time.UTC)
+	svc, clock := newTestService(map[string]Limits{"user": {ExecutionsPerMinute: 10, CPUSecondsPerDay: 5}}, start)
+	ctx := context.Background()
+
+	if err := svc.RecordUsage(ctx, 1, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := svc.Reserve(ctx, 1, "user", 1)
+	if !d.Allowed || d.CPURemaining != 2 {
+		t.Fatalf("allowed %v with %vs left, want allowed with 2s", d.Allowed, d.CPURemaining)
+	}
+
+	if err := svc.RecordUsage(ctx, 1, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	d, _ = svc.Reserve(ctx, 1, "user", 1)
+	if d.Allowed || d.Reason != "daily CPU quota exceeded" {
+		t.Fatalf("allowed %v (%s), want the CPU quota exceeded", d.Allowed, d.Reason)
+	}
+	if d.RetryAfter != 2*time.Hour {
+		t.Errorf("retry after %v, want 2h until midnight UTC", d.RetryAfter)
+	}
+	if d.Remaining != 9 {
+		t.Errorf("remaining %d, want 9: the denied run must not use the window", d.Remaining)
+	}
+
+	clock.t = start.Add(2 * time.Hour)
+	if d, _ := svc.Reserve(ctx, 1, "user", 1); !d.Allowed || d.CPURemaining != 5 {
+		t.Errorf("next day: allowed %v with %vs left, want allowed with 5s", d.Allowed, d.CPURemaining)
+	}
+}
+
+func TestReserveLimitsByRole(t *testing.T) {
+	svc, _ := newTestService(DefaultLimits, time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	tests := []struct {
+		role      string
+		wantLimit int
+	}{
+		{"user", 10},
+		{"vip", 30},
+		{"admin", 120},
+		{"god", 0},
+		{"unknown", 10},
+	}
+	for i, tt := range tests {
+		d, err := svc.Reserve(ctx, uint(i+1), tt.role, 1)
+		if err != nil || !d.Allowed || d.Limit != tt.wantLimit {
+			t.Errorf("%s: allowed %v limit %d (err %v), want allowed with limit %d", tt.role, d.Allowed, d.Limit, err, tt.wantLimit)
+		}
+	}
+
+	// Unlimited roles are never oversized.
+	if d, _ := svc.Reserve(ctx, 99, "god", 1000); !d.Allowed {
+		t.Errorf("god: 1000 executions denied: %s", d.Reason)
+	}
+}
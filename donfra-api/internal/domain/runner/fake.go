@@ -0,0 +1,387 @@
+package runner
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Fake run outcomes; see FakeRule.
+const (
+	FakeExit         = ""
+	FakeTimeout      = "timeout"
+	FakeOOM          = "oom"
+	FakeCompileError = "compile_error"
+)
+
+// FakeRule scripts the result of the runs it matches. A run matches when its
+// source and stdin contain Source and Stdin and its language is LanguageID;
+// empty or zero fields match anything. It is the format of the runner's
+// FAKE_SCRIPT_FILE, so one JSON script serves both.
+type FakeRule struct {
+	Source     string `json:"source,omitempty"`
+	Stdin      string `json:"stdin,omitempty"`
+	LanguageID int    `json:"language_id,omitempty"`
+
+	// Outcome is FakeExit to exit with ExitCode, or FakeTimeout, FakeOOM or
+	// FakeCompileError.
+	Outcome       string `json:"outcome,omitempty"`
+	Stdout        string `json:"stdout,omitempty"`
+	Stderr        string `json:"stderr,omitempty"`
+	CompileOutput string `json:"compile_output,omitempty"`
+	ExitCode      int    `json:"exit_code,omitempty"`
+	LatencyMs     int    `json:"latency_ms,omitempty"`
+	MemoryKB      int64  `json:"memory_kb,omitempty"`
+}
+
+// FakeScript is the script of a fake runner. The first rule that matches a
+// run decides its result; runs no rule matches are Accepted at once and echo
+// their stdin.
+type FakeScript struct {
+	Rules []FakeRule `json:"rules"`
+}
+
+// LoadFakeScript reads a FakeScript from a JSON file.
+func LoadFakeScript(path string) (FakeScript, error) {
+	var script FakeScript
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return script, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&script); err != nil {
+		return script, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, rule := range script.Rules {
+		switch rule.Outcome {
+		case FakeExit, FakeTimeout, FakeOOM, FakeCompileError:
+		default:
+			return script, fmt.Errorf("%s: rule %d: unknown outcome %q", path, i, rule.Outcome)
+		}
+	}
+	return script, nil
+}
+
+// fakeLanguages is the catalog a fake runner reports.
+var fakeLanguages = []Language{
+	{ID: 71, Name: "Python", SourceFile: "main.py", Extension: ".py", DefaultTimeoutMs: 5000, MaxTimeoutMs: 10000, REPL: true},
+	{ID: 63, Name: "JavaScript", SourceFile: "main.js", Extension: ".js", DefaultTimeoutMs: 5000, MaxTimeoutMs: 10000, REPL: true},
+	{ID: 60, Name: "Go", SourceFile: "main.go", Extension: ".go", Compiled: true, DefaultTimeoutMs: 5000, MaxTimeoutMs: 10000},
+	{ID: 50, Name: "C", SourceFile: "main.c", Extension: ".c", Compiled: true, DefaultTimeoutMs: 5000, MaxTimeoutMs: 10000},
+	{ID: 54, Name: "C++", SourceFile: "main.cpp", Extension: ".cpp", Compiled: true, DefaultTimeoutMs: 5000, MaxTimeoutMs: 10000},
+	{ID: 62, Name: "Java", SourceFile: "Main.java", Extension: ".java", Compiled: true, DefaultTimeoutMs: 5000, MaxTimeoutMs: 10000},
+}
+
+// StartFakeRunner serves the runner's API on a loopback port with results
+// from script, so the API can be developed without the runner service. No
+// code runs: test cases are judged on the scripted output, and modes,
+// checkers and files are ignored. It returns the base URL for NewClient.
+func StartFakeRunner(script FakeScript) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	f := &fakeRunner{script: script, submissions: make(map[string]fakeSubmission)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /execute", f.execute)
+	mux.HandleFunc("POST /execute/stream", f.executeStream)
+	mux.HandleFunc("POST /execute/batch", f.executeBatch)
+	mux.HandleFunc("GET /execute/session", f.session)
+	mux.HandleFunc("POST /submissions", f.submit)
+	mux.HandleFunc("GET /submissions/{token}", f.getSubmission)
+	mux.HandleFunc("GET /languages", func(w http.ResponseWriter, r *http.Request) {
+		fakeJSON(w, http.StatusOK, map[string]any{"languages": fakeLanguages})
+	})
+
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			log.Printf("[fake-runner] stopped: %v", err)
+		}
+	}()
+	return "http://" + ln.Addr().String(), nil
+}
+
+type fakeRunner struct {
+	script FakeScript
+
+	mu          sync.Mutex
+	submissions map[string]fakeSubmission
+}
+
+type fakeSubmission struct {
+	callerID string
+	result   ExecuteResult
+}
+
+func (f *fakeRunner) execute(w http.ResponseWriter, r *http.Request) {
+	var req ExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	fakeJSON(w, http.StatusOK, f.judge(r.Context(), req))
+}
+
+func (f *fakeRunner) executeStream(w http.ResponseWriter, r *http.Request) {
+	var req ExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	result := f.run(r.Context(), req.LanguageID, req.SourceCode, req.Stdin, req.TimeoutMs)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, chunk := range []OutputChunk{
+		{Stream: "stdout", Data: result.Stdout, ElapsedMs: result.ExecutionTimeMs},
+		{Stream: "stderr", Data: result.Stderr, ElapsedMs: result.ExecutionTimeMs},
+	} {
+		if chunk.Data != "" {
+			fakeEvent(w, chunk.Stream, chunk)
+		}
+	}
+	fakeEvent(w, "result", result)
+}
+
+func (f *fakeRunner) executeBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Items []ExecuteRequest `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	results := make([]ExecuteResult, len(req.Items))
+	for i, item := range req.Items {
+		results[i] = f.run(r.Context(), item.LanguageID, item.SourceCode, item.Stdin, item.TimeoutMs)
+	}
+	fakeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func (f *fakeRunner) submit(w http.ResponseWriter, r *http.Request) {
+	var req ExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	token := uuid.New().String()
+
+	if r.URL.Query().Get("wait") == "true" {
+		result := f.judge(r.Context(), req)
+		result.Token = token
+		f.store(req.CallerID, result)
+		fakeJSON(w, http.StatusCreated, result)
+		return
+	}
+
+	f.store(req.CallerID, ExecuteResult{Token: token, Status: ExecuteStatus{ID: StatusProcessing, Description: "Processing"}})
+	go func() {
+		result := f.judge(context.Background(), req)
+		result.Token = token
+		f.store(req.CallerID, result)
+	}()
+	fakeJSON(w, http.StatusCreated, map[string]string{"token": token})
+}
+
+func (f *fakeRunner) getSubmission(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	sub, ok := f.submissions[r.PathValue("token")]
+	f.mu.Unlock()
+	if !ok || sub.callerID != r.URL.Query().Get("caller_id") {
+		fakeJSON(w, http.StatusNotFound, map[string]string{"error": "submission not found"})
+		return
+	}
+	fakeJSON(w, http.StatusOK, sub.result)
+}
+
+func (f *fakeRunner) store(callerID string, result ExecuteResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.submissions[result.Token] = fakeSubmission{callerID: callerID, result: result}
+}
+
+// session writes the matching rule's output, echoes each stdin message to
+// stdout and ends with the rule's verdict once the client sends eof.
+func (f *fakeRunner) session(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r, nil, 0, 0)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var start SessionMessage
+	if err := conn.ReadJSON(&start); err != nil || start.Type != SessionStart || start.SessionRequest == nil {
+		return
+	}
+	rule := f.match(start.LanguageID, start.SourceCode, "")
+	if rule.Outcome == FakeCompileError {
+		result := fakeVerdict(rule, 0)
+		conn.WriteJSON(SessionMessage{Type: SessionResult, Result: &result})
+		return
+	}
+
+	began := time.Now()
+	time.Sleep(time.Duration(rule.LatencyMs) * time.Millisecond)
+	for _, msg := range []SessionMessage{
+		{Type: SessionStdout, Data: rule.Stdout},
+		{Type: SessionStderr, Data: rule.Stderr},
+	} {
+		if msg.Data != "" {
+			msg.ElapsedMs = time.Since(began).Milliseconds()
+			conn.WriteJSON(msg)
+		}
+	}
+
+	for {
+		var msg SessionMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case SessionStdin:
+			conn.WriteJSON(SessionMessage{Type: SessionStdout, Data: msg.Data, ElapsedMs: time.Since(began).Milliseconds()})
+		case SessionEOF:
+			result := fakeVerdict(rule, time.Since(began).Milliseconds())
+			result.Stdout, result.Stderr = "", ""
+			conn.WriteJSON(SessionMessage{Type: SessionResult, Result: &result})
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
+
+// judge runs req, once per test case when it has any, comparing stdout with
+// the expected output line by line, ignoring trailing whitespace.
+func (f *fakeRunner) judge(ctx context.Context, req ExecuteRequest) ExecuteResult {
+	if len(req.TestCases) == 0 {
+		return f.run(ctx, req.LanguageID, req.SourceCode, req.Stdin, req.TimeoutMs)
+	}
+
+	var summary ExecuteResult
+	failed := -1
+	results := make([]TestCaseResult, len(req.TestCases))
+	for i, tc := range req.TestCases {
+		res := f.run(ctx, req.LanguageID, req.SourceCode, tc.Input, req.TimeoutMs)
+		if res.Status.ID == StatusCompilationError {
+			return res
+		}
+		if res.Status.ID == StatusAccepted && fakeNormalize(res.Stdout) != fakeNormalize(tc.ExpectedOutput) {
+			res.Status = ExecuteStatus{ID: StatusWrongAnswer, Description: "Wrong Answer"}
+		}
+		results[i] = TestCaseResult{Index: i, Status: res.Status, Stdout: res.Stdout, Stderr: res.Stderr,
+			Message: res.Message, ExecutionTimeMs: res.ExecutionTimeMs, Usage: res.Usage}
+		summary.ExecutionTimeMs += res.ExecutionTimeMs
+		summary.CPUUserMs += res.CPUUserMs
+		if failed < 0 && res.Status.ID != StatusAccepted {
+			failed = i
+		}
+	}
+
+	last := results[len(results)-1]
+	summary.Message = fmt.Sprintf("all %d test cases passed", len(results))
+	if failed >= 0 {
+		last = results[failed]
+		summary.Message = fmt.Sprintf("test case %d failed: %s", failed, last.Status.Description)
+	}
+	summary.Status, summary.Stdout, summary.Stderr = last.Status, last.Stdout, last.Stderr
+	summary.TestResults = results
+	return summary
+}
+
+// run produces the scripted result of one run, taking the rule's latency.
+func (f *fakeRunner) run(ctx context.Context, languageID int, source, stdin string, timeoutMs int) ExecuteResult {
+	rule := f.match(languageID, source, stdin)
+	if rule.Outcome == FakeCompileError {
+		return fakeVerdict(rule, 0)
+	}
+
+	timeout := time.Duration(cmp.Or(timeoutMs, 5000)) * time.Millisecond
+	latency := time.Duration(rule.LatencyMs) * time.Millisecond
+	if rule.Outcome == FakeTimeout || latency > timeout {
+		latency = timeout
+		rule.Outcome = FakeTimeout
+	}
+	select {
+	case <-time.After(latency):
+	case <-ctx.Done():
+		return ExecuteResult{Status: ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
+			Message: "execution was cancelled"}
+	}
+
+	result := fakeVerdict(rule, latency.Milliseconds())
+	if rule.Outcome != FakeTimeout {
+		result.Stdout, result.Stderr = rule.Stdout, rule.Stderr
+	}
+	return result
+}
+
+// match returns the first rule matching a run, or the echo rule.
+func (f *fakeRunner) match(languageID int, source, stdin string) FakeRule {
+	for _, rule := range f.script.Rules {
+		if strings.Contains(source, rule.Source) && strings.Contains(stdin, rule.Stdin) &&
+			(rule.LanguageID == 0 || rule.LanguageID == languageID) {
+			return rule
+		}
+	}
+	return FakeRule{Stdout: stdin}
+}
+
+// fakeVerdict is the result of a run that took elapsedMs and ended as rule
+// says; output is left to the caller.
+func fakeVerdict(rule FakeRule, elapsedMs int64) ExecuteResult {
+	result := ExecuteResult{ExecutionTimeMs: elapsedMs}
+	result.CPUUserMs, result.MemoryKB = elapsedMs, rule.MemoryKB
+
+	switch {
+	case rule.Outcome == FakeCompileError:
+		result.Status = ExecuteStatus{ID: StatusCompilationError, Description: "Compilation Error"}
+		result.Message, result.CompileOutput = "compilation failed", rule.CompileOutput
+	case rule.Outcome == FakeTimeout:
+		result.Status = ExecuteStatus{ID: StatusTimeLimitExceeded, Description: "Time Limit Exceeded"}
+	case rule.Outcome == FakeOOM:
+		result.Status = ExecuteStatus{ID: StatusMemoryLimit, Description: "Memory Limit Exceeded"}
+		result.Message, result.Signal = "process was killed due to memory limit", "SIGKILL"
+	case rule.ExitCode == 0:
+		result.Status = ExecuteStatus{ID: StatusAccepted, Description: "Accepted"}
+		result.ExitCode = &rule.ExitCode
+	default:
+		result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+		result.Message = fmt.Sprintf("Process exited with code %d", rule.ExitCode)
+		result.ExitCode = &rule.ExitCode
+	}
+	return result
+}
+
+// fakeNormalize strips trailing whitespace from every line and trailing
+// blank lines, like the runner's default comparison.
+func fakeNormalize(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+func fakeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func fakeEvent(w http.ResponseWriter, event string, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -1,20 +1,167 @@
 package runner
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// ErrSubmissionNotFound is returned for unknown or expired submission tokens.
+var ErrSubmissionNotFound = errors.New("submission not found")
+
+// statusError is returned by doJSON when the runner answers with an
+// unexpected status code.
+type statusError struct {
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("runner returned %d: %s", e.code, e.body)
+}
+
+// Status IDs reported by donfra-runner.
+const (
+	StatusInQueue           = 1
+	StatusProcessing        = 2
+	StatusAccepted          = 3
+	StatusWrongAnswer       = 4
+	StatusTimeLimitExceeded = 5
+	StatusCompilationError  = 6
+	StatusMemoryLimit       = 7
+	StatusRuntimeError      = 11
+	StatusInternalError     = 13
+)
+
+// Priority is the runner's scheduling class for an execution: when every
+// slot is busy, waiting interview runs start before practice runs.
+type Priority string
+
+const (
+	PriorityPractice  Priority = ""
+	PriorityInterview Priority = "interview"
+)
+
+// CompareMode controls how the runner matches a test case's stdout against its expected output.
+type CompareMode string
+
+const (
+	CompareExact   CompareMode = "exact"
+	CompareTrimmed CompareMode = "trimmed"
+	CompareFloat   CompareMode = "float"
 )
 
 type ExecuteRequest struct {
+	SourceCode string     `json:"source_code"`
+	LanguageID int        `json:"language_id"`
+	Stdin      string     `json:"stdin"`
+	TimeoutMs  int        `json:"timeout_ms"`
+	TestCases  []TestCase `json:"test_cases,omitempty"`
+
+	// Files are more files of the program (helper modules, data files,
+	// packages) written beside the source, whose own path is Entrypoint, or
+	// the language's source file by default. Paths are relative and use
+	// forward slashes.
+	Files      []ProjectFile `json:"files,omitempty"`
+	Entrypoint string        `json:"entrypoint,omitempty"`
+
+	// Mode is "" to run the program, "visualize" to also record a
+	// step-by-step trace of it (Python only), "complexity" to time it on
+	// inputs of increasing size from Generator, or "unit_tests" to run
+	// TestCode against it.
+	Mode string `json:"mode,omitempty"`
+
+	// Checker, when set, decides each test case's verdict instead of
+	// comparing stdout with the expected output.
+	Checker *Checker `json:"checker,omitempty"`
+
+	// Generator produces the inputs of a complexity run.
+	Generator *Generator `json:"generator,omitempty"`
+
+	// TestCode is the unit-test file of a unit_tests run. It imports the
+	// program as the module "solution".
+	TestCode string `json:"test_code,omitempty"`
+
+	// Schema creates and seeds the database a SQL program runs against;
+	// ExpectedResult, when set, grades the program's result set.
+	Schema         string          `json:"schema,omitempty"`
+	ExpectedResult *ExpectedResult `json:"expected_result,omitempty"`
+
+	// Cache lets the runner answer from its result cache when an identical
+	// request ran before. Only useful for deterministic code, such as an
+	// unchanged lesson template. Cached results cost no CPU quota, so
+	// handlers must not accept it from clients.
+	Cache bool `json:"cache,omitempty"`
+
+	// Trusted lets the runner reuse a warm pod across executions. Only set it
+	// for staff-authored code; handlers must not accept it from clients.
+	Trusted bool `json:"trusted,omitempty"`
+
+	// CallerID and Priority place the execution in the runner's queue: each
+	// caller gets a fair share of it. Handlers set both; clients cannot.
+	CallerID string   `json:"caller_id,omitempty"`
+	Priority Priority `json:"priority,omitempty"`
+}
+
+// ProjectFile is one more file of a multi-file program.
+type ProjectFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Checker is a special judge program for problems with many valid answers.
+// It reads the test input, expected output and program output from stdin,
+// each preceded by a line holding its length in bytes, and exits 0 to accept
+// or 1 to reject; the first line of its stdout is the test case message.
+type Checker struct {
+	SourceCode string `json:"source_code"`
+	LanguageID int    `json:"language_id"`
+	TimeoutMs  int    `json:"timeout_ms,omitempty"`
+}
+
+// Generator is run once per size with the size on stdin and prints an input
+// of that size for the program under test.
+type Generator struct {
 	SourceCode string `json:"source_code"`
 	LanguageID int    `json:"language_id"`
-	Stdin      string `json:"stdin"`
-	TimeoutMs  int    `json:"timeout_ms"`
+	Sizes      []int  `json:"sizes,omitempty"`
+}
+
+// ExpectedResult is the result set a SQL program must return. Columns, when
+// set, are compared ignoring case; rows are compared in order only when
+// Ordered is set.
+type ExpectedResult struct {
+	Columns []string            `json:"columns,omitempty"`
+	Rows    [][]json.RawMessage `json:"rows"`
+	Ordered bool                `json:"ordered,omitempty"`
+}
+
+// TestCase is a single input/expected-output pair for judging mode.
+type TestCase struct {
+	Input          string      `json:"input"`
+	ExpectedOutput string      `json:"expected_output"`
+	Compare        CompareMode `json:"compare,omitempty"`
+	Tolerance      float64     `json:"tolerance,omitempty"`
+}
+
+type TestCaseResult struct {
+	Index           int           `json:"index"`
+	Status          ExecuteStatus `json:"status"`
+	Stdout          string        `json:"stdout,omitempty"`
+	Stderr          string        `json:"stderr,omitempty"`
+	Message         string        `json:"message,omitempty"`
+	ExecutionTimeMs int64         `json:"execution_time_ms"`
+	Usage
 }
 
 type ExecuteStatus struct {
@@ -22,40 +169,303 @@ type ExecuteStatus struct {
 	Description string `json:"description"`
 }
 
+// Usage is what a run consumed and how it ended, as measured by the runner.
+type Usage struct {
+	CPUUserMs int64 `json:"cpu_user_ms,omitempty"`
+	CPUSysMs  int64 `json:"cpu_sys_ms,omitempty"`
+	MemoryKB  int64 `json:"memory_kb,omitempty"` // peak resident set size
+
+	// ExitCode is set when the process exited on its own; Signal names the
+	// signal that killed it otherwise (e.g. "SIGSEGV").
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Signal   string `json:"signal,omitempty"`
+
+	StdoutTruncated bool `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool `json:"stderr_truncated,omitempty"`
+}
+
 type ExecuteResult struct {
-	Token           string        `json:"token"`
+	Token           string        `json:"token,omitempty"` // submission token; empty for synchronous runs
 	Status          ExecuteStatus `json:"status"`
 	Stdout          string        `json:"stdout,omitempty"`
 	Stderr          string        `json:"stderr,omitempty"`
 	Message         string        `json:"message,omitempty"`
+	CompileOutput   string        `json:"compile_output,omitempty"`
 	ExecutionTimeMs int64         `json:"execution_time_ms"`
+	Usage
+
+	// Cached is set when the runner served the result from its cache.
+	Cached bool `json:"cached,omitempty"`
+
+	// Queue is where a submission stands while it is In Queue.
+	Queue *QueueStatus `json:"queue,omitempty"`
+
+	TestResults []TestCaseResult `json:"test_results,omitempty"`
+
+	// Trace is the runner's step trace of a visualize run, passed through
+	// to the client as is.
+	Trace json.RawMessage `json:"trace,omitempty"`
+
+	// Complexity holds the measurements of a complexity run.
+	Complexity *ComplexityReport `json:"complexity,omitempty"`
+
+	// UnitTests holds per-test results of a unit_tests run, in run order.
+	UnitTests []UnitTestResult `json:"unit_tests,omitempty"`
+
+	// ResultSet holds the rows returned by a SQL program.
+	ResultSet *ResultSet `json:"result_set,omitempty"`
+}
+
+// ResultSet is the rows of the last statement of a SQL program that returned
+// any. Values are JSON null, numbers or strings.
+type ResultSet struct {
+	Columns   []string            `json:"columns"`
+	Rows      [][]json.RawMessage `json:"rows"`
+	Truncated bool                `json:"truncated,omitempty"`
+}
+
+// UnitTestResult is one test of a unit_tests run. Status is "passed",
+// "failed" (assertion), "error" (any other exception) or "skipped".
+type UnitTestResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Message    string  `json:"message,omitempty"`
+	Details    string  `json:"details,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// ComplexityReport is the best-fitting growth curve of a complexity run, the
+// other candidates by error, and the measured points.
+type ComplexityReport struct {
+	BestFit string            `json:"best_fit"` // e.g. "O(n log n)"
+	Metric  string            `json:"metric"`   // "cpu" or "wall"
+	Fits    []ComplexityFit   `json:"fits"`
+	Points  []ComplexityPoint `json:"points"`
+}
+
+type ComplexityFit struct {
+	Class       string  `json:"class"`
+	Coefficient float64 `json:"coefficient"`
+	InterceptMs float64 `json:"intercept_ms"`
+	RMSEMs      float64 `json:"rmse_ms"`
+}
+
+type ComplexityPoint struct {
+	Size     int   `json:"size"`
+	TimeMs   int64 `json:"time_ms"`
+	MemoryKB int64 `json:"memory_kb,omitempty"`
+}
+
+// Language describes a language the runner accepts.
+type Language struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	Version          string `json:"version,omitempty"`
+	SourceFile       string `json:"source_file"`
+	Extension        string `json:"extension"`
+	Compiled         bool   `json:"compiled"`
+	DefaultTimeoutMs int    `json:"default_timeout_ms"`
+	MaxTimeoutMs     int    `json:"max_timeout_ms"`
+	MemoryMB         int    `json:"memory_mb,omitempty"`
+	Visualize        bool   `json:"visualize,omitempty"`    // supports "mode": "visualize"
+	TestHarness      string `json:"test_harness,omitempty"` // supports "mode": "unit_tests"
+	Database         string `json:"database,omitempty"`     // a SQL language run against this engine
+	REPL             bool   `json:"repl,omitempty"`         // supports "repl" sessions
+}
+
+// QueueStatus is where an execution stands while it waits for a runner slot.
+// Position 1 starts next; 0 means the execution has started.
+type QueueStatus struct {
+	Position        int   `json:"position"`
+	EstimatedWaitMs int64 `json:"estimated_wait_ms"`
+}
+
+// OutputChunk is a piece of program output streamed while the program runs.
+type OutputChunk struct {
+	Stream    string `json:"stream"`
+	Data      string `json:"data"`
+	ElapsedMs int64  `json:"elapsed_ms"`
 }
 
+// maxResponseBytes caps runner responses; batch results can hold many outputs.
+const maxResponseBytes = 16 << 20
+
 type Client struct {
 	baseURL    string
+	apiToken   string
 	httpClient *http.Client
 }
 
-func NewClient(baseURL string) *Client {
+// NewClient creates a runner client. apiToken is the runner's shared secret;
+// it may be empty when the runner does not require one.
+func NewClient(baseURL, apiToken string) *Client {
 	return &Client{
-		baseURL: baseURL,
+		baseURL:  baseURL,
+		apiToken: apiToken,
 		httpClient: &http.Client{
-			Timeout: 15 * time.Second,
+			// Judge requests run each test case sequentially on the runner.
+			Timeout: 5 * time.Minute,
+			// Propagates the trace context so runner spans join the API request's trace.
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
 	}
 }
 
 func (c *Client) Execute(ctx context.Context, req ExecuteRequest) (*ExecuteResult, error) {
+	var result ExecuteResult
+	if err := c.doJSON(ctx, http.MethodPost, "/execute", req, http.StatusOK, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExecuteBatch runs many submissions on the runner in one request. Items with
+// the same source and language share compilation. Results are in item order;
+// invalid items get an error result rather than failing the batch.
+func (c *Client) ExecuteBatch(ctx context.Context, items []ExecuteRequest) ([]ExecuteResult, error) {
+	var resp struct {
+		Results []ExecuteResult `json:"results"`
+	}
+	body := map[string]any{"items": items}
+	if err := c.doJSON(ctx, http.MethodPost, "/execute/batch", body, http.StatusOK, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(items) {
+		return nil, fmt.Errorf("runner returned %d results for %d items", len(resp.Results), len(items))
+	}
+	return resp.Results, nil
+}
+
+// Submit queues code for asynchronous execution and returns its token.
+// The result is fetched later with GetSubmission.
+func (c *Client) Submit(ctx context.Context, req ExecuteRequest) (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/submissions", req, http.StatusCreated, &resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// SubmitAndWait creates a submission and blocks until it has finished.
+func (c *Client) SubmitAndWait(ctx context.Context, req ExecuteRequest) (*ExecuteResult, error) {
+	var result ExecuteResult
+	if err := c.doJSON(ctx, http.MethodPost, "/submissions?wait=true", req, http.StatusCreated, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetSubmission returns the state of a submission made by callerID (the
+// CallerID of its request); other callers get ErrSubmissionNotFound. Its
+// status is StatusInQueue or StatusProcessing until the execution has finished.
+func (c *Client) GetSubmission(ctx context.Context, token, callerID string) (*ExecuteResult, error) {
+	var result ExecuteResult
+	path := "/submissions/" + url.PathEscape(token) + "?caller_id=" + url.QueryEscape(callerID)
+	err := c.doJSON(ctx, http.MethodGet, path, nil, http.StatusOK, &result)
+	if err != nil {
+		// Only the runner's own answer means the token is unknown; a 404
+		// from a runner without the route is an upstream error.
+		var se *statusError
+		if errors.As(err, &se) && se.code == http.StatusNotFound {
+			var body struct {
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(se.body, &body) == nil && body.Error == ErrSubmissionNotFound.Error() {
+				return nil, ErrSubmissionNotFound
+			}
+		}
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Languages fetches the runner's language catalog.
+func (c *Client) Languages(ctx context.Context) ([]Language, error) {
+	var resp struct {
+		Languages []Language `json:"languages"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/languages", nil, http.StatusOK, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Languages, nil
+}
+
+// authorize adds the runner API token to req.
+func (c *Client) authorize(req *http.Request) {
+	if c.apiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	}
+}
+
+// doJSON sends body (if non-nil) as JSON and decodes a response with the
+// wanted status code into out.
+func (c *Client) doJSON(ctx context.Context, method, path string, body any, want int, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(httpReq)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("runner request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != want {
+		return &statusError{code: resp.StatusCode, body: respBody}
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// Judge runs the source against every test case and returns per-case verdicts.
+func (c *Client) Judge(ctx context.Context, req ExecuteRequest, cases []TestCase) (*ExecuteResult, error) {
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("at least one test case is required")
+	}
+	req.TestCases = cases
+	return c.Execute(ctx, req)
+}
+
+// ExecuteStream runs code via the runner's streaming endpoint, invoking onChunk
+// for every stdout/stderr chunk as it arrives and onQueue whenever the
+// execution's place in the queue changes. It returns the final result.
+func (c *Client) ExecuteStream(ctx context.Context, req ExecuteRequest, onQueue func(QueueStatus), onChunk func(OutputChunk)) (*ExecuteResult, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/execute", bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/execute/stream", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	c.authorize(httpReq)
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
@@ -63,19 +473,50 @@ func (c *Client) Execute(ctx context.Context, req ExecuteRequest) (*ExecuteResul
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
-	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
-	}
-
 	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 		return nil, fmt.Errorf("runner returned %d: %s", resp.StatusCode, string(respBody))
 	}
 
-	var result ExecuteResult
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
-	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
 
-	return &result, nil
+	var event string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "result":
+				var result ExecuteResult
+				if err := json.Unmarshal(data, &result); err != nil {
+					return nil, fmt.Errorf("decode result: %w", err)
+				}
+				return &result, nil
+			case "queue":
+				var status QueueStatus
+				if err := json.Unmarshal(data, &status); err != nil {
+					return nil, fmt.Errorf("decode queue status: %w", err)
+				}
+				if onQueue != nil {
+					onQueue(status)
+				}
+				continue
+			}
+			var chunk OutputChunk
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				return nil, fmt.Errorf("decode chunk: %w", err)
+			}
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+	return nil, fmt.Errorf("runner stream ended without a result")
 }
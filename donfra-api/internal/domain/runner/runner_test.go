@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetSubmissionNotFound(t *testing.T) {
+	fakeURL, err := StartFakeRunner(FakeScript{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A server without the runner's routes answers every path with 404.
+	bare := httptest.NewServer(http.NotFoundHandler())
+	defer bare.Close()
+	ctx := context.Background()
+
+	fake := NewClient(fakeURL, "")
+	token, err := fake.Submit(ctx, ExecuteRequest{SourceCode: "print(1)", LanguageID: 71, CallerID: "user:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.GetSubmission(ctx, token, "user:1"); err != nil {
+		t.Errorf("own submission: %v", err)
+	}
+	if _, err := fake.GetSubmission(ctx, token, "user:2"); !errors.Is(err, ErrSubmissionNotFound) {
+		t.Errorf("other caller's submission: err = %v, want ErrSubmissionNotFound", err)
+	}
+	if _, err := fake.GetSubmission(ctx, "no-such-token", "user:1"); !errors.Is(err, ErrSubmissionNotFound) {
+		t.Errorf("unknown token: err = %v, want ErrSubmissionNotFound", err)
+	}
+
+	client := NewClient(bare.URL, "")
+	if _, err := client.GetSubmission(ctx, token, "user:1"); err == nil || errors.Is(err, ErrSubmissionNotFound) {
+		t.Errorf("runner without the route: err = %v, want an upstream error", err)
+	}
+	if _, err := client.Languages(ctx); err == nil || errors.Is(err, ErrSubmissionNotFound) {
+		t.Errorf("languages: err = %v, want an upstream error", err)
+	}
+}
@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Session message types. Clients send "start", then "stdin" and "eof"; the
+// runner sends "queue" updates while the session waits for a slot, "stdout"
+// and "stderr" chunks and one final "result".
+const (
+	SessionStart  = "start"
+	SessionStdin  = "stdin"
+	SessionEOF    = "eof"
+	SessionQueue  = "queue"
+	SessionStdout = "stdout"
+	SessionStderr = "stderr"
+	SessionResult = "result"
+)
+
+// sessionWriteTimeout bounds each write to the runner.
+const sessionWriteTimeout = 10 * time.Second
+
+// SessionRequest starts an interactive session: a program whose stdin is
+// sent while it runs. With REPL set, the language's interactive interpreter
+// starts with SourceCode loaded (see Language.REPL).
+type SessionRequest struct {
+	SourceCode string `json:"source_code"`
+	LanguageID int    `json:"language_id"`
+	REPL       bool   `json:"repl,omitempty"`
+
+	// CallerID and Priority place the session in the runner's queue, as for
+	// ExecuteRequest.
+	CallerID string   `json:"caller_id,omitempty"`
+	Priority Priority `json:"priority,omitempty"`
+}
+
+// SessionMessage is one WebSocket message of the runner's session protocol.
+// SessionRequest is set on "start", Data on "stdin" and output chunks, Queue
+// on "queue" and Result on "result". The result carries no output: it was
+// all streamed.
+type SessionMessage struct {
+	Type string `json:"type"`
+	*SessionRequest
+	Data      string         `json:"data,omitempty"`
+	ElapsedMs int64          `json:"elapsed_ms,omitempty"`
+	Queue     *QueueStatus   `json:"queue,omitempty"`
+	Result    *ExecuteResult `json:"result,omitempty"`
+}
+
+// Session is an interactive session running on the runner. Closing it
+// before the result arrives kills the program.
+type Session struct {
+	conn *websocket.Conn
+}
+
+// OpenSession connects to the runner's session endpoint and starts req.
+func (c *Client) OpenSession(ctx context.Context, req SessionRequest) (*Session, error) {
+	u, err := url.Parse(c.baseURL + "/execute/session")
+	if err != nil {
+		return nil, fmt.Errorf("parse runner url: %w", err)
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+
+	header := http.Header{}
+	if c.apiToken != "" {
+		header.Set("Authorization", "Bearer "+c.apiToken)
+	}
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("runner returned %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("runner request failed: %w", err)
+	}
+	conn.SetReadLimit(maxResponseBytes)
+
+	s := &Session{conn: conn}
+	if err := s.send(SessionMessage{Type: SessionStart, SessionRequest: &req}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// WriteStdin sends data to the program's stdin.
+func (s *Session) WriteStdin(data string) error {
+	return s.send(SessionMessage{Type: SessionStdin, Data: data})
+}
+
+// CloseInput closes the program's stdin.
+func (s *Session) CloseInput() error {
+	return s.send(SessionMessage{Type: SessionEOF})
+}
+
+// Next waits for the next output chunk or the result. WriteStdin and
+// CloseInput may be called concurrently with it.
+func (s *Session) Next() (SessionMessage, error) {
+	var msg SessionMessage
+	if err := s.conn.ReadJSON(&msg); err != nil {
+		return msg, fmt.Errorf("read session: %w", err)
+	}
+	return msg, nil
+}
+
+// Close ends the session.
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Session) send(msg SessionMessage) error {
+	s.conn.SetWriteDeadline(time.Now().Add(sessionWriteTimeout))
+	if err := s.conn.WriteJSON(msg); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+
+	"donfra-api/internal/domain/execution"
+	"donfra-api/internal/domain/interview"
+	"donfra-api/internal/domain/runner"
+	"donfra-api/internal/pkg/httputil"
+	"donfra-api/internal/pkg/tracing"
+)
+
+// recordExecution stores a run in the caller's history. result is nil for a
+// submission that is still queued; token links it to the runner submission.
+// Failures are logged and never fail the execution itself.
+func (h *Handlers) recordExecution(ctx context.Context, execCtx execution.Context, req runner.ExecuteRequest, result *runner.ExecuteResult, token string) {
+	userID, ok := getUserID(ctx)
+	if h.executionSvc == nil || !ok {
+		return
+	}
+
+	e := execution.New(userID, execCtx, req, result)
+	e.Token = token
+	if err := h.executionSvc.Record(context.WithoutCancel(ctx), e); err != nil {
+		log.Printf("[execution] record failed for user %d: %v", userID, err)
+	}
+}
+
+// completeExecution stores the outcome of a finished asynchronous submission.
+func (h *Handlers) completeExecution(ctx context.Context, token string, result runner.ExecuteResult) {
+	if h.executionSvc == nil || result.Status.ID <= runner.StatusProcessing {
+		return
+	}
+	if err := h.executionSvc.CompleteSubmission(context.WithoutCancel(ctx), token, result); err != nil {
+		log.Printf("[execution] complete failed for submission %s: %v", token, err)
+	}
+}
+
+// ListMyExecutionsHandler handles GET /api/executions/me
+// Returns the authenticated user's runs, newest first.
+// Supports ?page=1&size=20 and the lesson, room_id and language_id filters.
+func (h *Handlers) ListMyExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.StartSpan(r.Context(), "handler.ListMyExecutions")
+	defer span.End()
+
+	if h.executionSvc == nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "execution service unavailable")
+		return
+	}
+
+	userID, ok := getUserID(ctx)
+	if !ok {
+		httputil.WriteError(w, http.StatusUnauthorized, "user authentication required")
+		return
+	}
+
+	filter := parseExecutionFilter(r)
+	filter.UserID = userID
+	h.writeExecutions(ctx, w, r, span, filter)
+}
+
+// ListRoomExecutionsHandler handles GET /api/interview/rooms/{room_id}/executions
+// Returns the runs made in an interview room (room owner only).
+func (h *Handlers) ListRoomExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.StartSpan(r.Context(), "handler.ListRoomExecutions")
+	defer span.End()
+
+	if h.executionSvc == nil || h.interviewSvc == nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "execution service unavailable")
+		return
+	}
+
+	userID, ok := getUserID(ctx)
+	if !ok {
+		httputil.WriteError(w, http.StatusUnauthorized, "user authentication required")
+		return
+	}
+
+	roomID := chi.URLParam(r, "room_id")
+	span.SetAttributes(tracing.AttrRoomID.String(roomID))
+
+	room, err := h.interviewSvc.GetRoomByID(ctx, roomID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		if errors.Is(err, interview.ErrRoomNotFound) {
+			httputil.WriteError(w, http.StatusNotFound, "room not found")
+		} else {
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to get room")
+		}
+		return
+	}
+	if room.OwnerID != userID {
+		httputil.WriteError(w, http.StatusForbidden, "only the room owner can view its executions")
+		return
+	}
+
+	filter := parseExecutionFilter(r)
+	filter.RoomID = roomID
+	h.writeExecutions(ctx, w, r, span, filter)
+}
+
+// ListAllExecutionsHandler handles GET /api/admin/executions
+// Returns runs across all users (admin or god only).
+// Supports the user_id, room_id, lesson and language_id filters.
+func (h *Handlers) ListAllExecutionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.StartSpan(r.Context(), "handler.ListAllExecutions")
+	defer span.End()
+
+	if h.executionSvc == nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "execution service unavailable")
+		return
+	}
+
+	filter := parseExecutionFilter(r)
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, "invalid user_id")
+			return
+		}
+		filter.UserID = uint(id)
+	}
+	h.writeExecutions(ctx, w, r, span, filter)
+}
+
+// writeExecutions responds with one page of executions matching filter.
+func (h *Handlers) writeExecutions(ctx context.Context, w http.ResponseWriter, r *http.Request, span trace.Span, filter execution.Filter) {
+	query := r.URL.Query()
+	params := execution.PaginationParams{
+		Page: parsePaginationParam(query.Get("page"), 1),
+		Size: parsePaginationParam(query.Get("size"), 20),
+	}
+
+	response, err := h.executionSvc.List(ctx, filter, params)
+	if err != nil {
+		tracing.RecordError(span, err)
+		httputil.WriteError(w, http.StatusInternalServerError, "failed to load executions")
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, response)
+}
+
+// parseExecutionFilter reads the history filters shared by all listing endpoints.
+func parseExecutionFilter(r *http.Request) execution.Filter {
+	query := r.URL.Query()
+	filter := execution.Filter{
+		RoomID:     query.Get("room_id"),
+		LessonSlug: query.Get("lesson"),
+	}
+	if id, err := strconv.Atoi(query.Get("language_id")); err == nil {
+		filter.LanguageID = id
+	}
+	return filter
+}
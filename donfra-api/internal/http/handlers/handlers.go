@@ -3,11 +3,14 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"donfra-api/internal/domain/aiagent"
+	"donfra-api/internal/domain/execution"
 	"donfra-api/internal/domain/google"
 	"donfra-api/internal/domain/interview"
 	"donfra-api/internal/domain/livekit"
+	"donfra-api/internal/domain/quota"
 	"donfra-api/internal/domain/runner"
 	"donfra-api/internal/domain/study"
 	"donfra-api/internal/domain/user"
@@ -80,6 +83,20 @@ type AIAgentService interface {
 	ChatStream(ctx context.Context, codeContent, question string, history []aiagent.DeepSeekMessage) (*http.Response, error)
 }
 
+// QuotaService defines the interface for per-user execution quotas.
+type QuotaService interface {
+	Reserve(ctx context.Context, userID uint, role string, n int) (quota.Decision, error)
+	RecordUsage(ctx context.Context, userID uint, cpu time.Duration) error
+	RecordUsageOnce(ctx context.Context, userID uint, usageKey string, cpu time.Duration) error
+}
+
+// ExecutionService defines the interface for execution history operations.
+type ExecutionService interface {
+	Record(ctx context.Context, e *execution.Execution) error
+	CompleteSubmission(ctx context.Context, token string, result runner.ExecuteResult) error
+	List(ctx context.Context, filter execution.Filter, params execution.PaginationParams) (*execution.PaginatedExecutionsResponse, error)
+}
+
 // Handlers holds all service dependencies for HTTP handlers.
 type Handlers struct {
 	studySvc     StudyService
@@ -89,10 +106,12 @@ type Handlers struct {
 	livekitSvc   LiveKitService
 	aiAgentSvc   AIAgentService
 	runnerClient *runner.Client
+	quotaSvc     QuotaService
+	executionSvc ExecutionService
 }
 
 // New creates a new Handlers instance with the given services.
-func New(studySvc StudyService, userSvc UserService, googleSvc GoogleService, interviewSvc InterviewService, livekitSvc LiveKitService, aiAgentSvc AIAgentService, runnerClient *runner.Client) *Handlers {
+func New(studySvc StudyService, userSvc UserService, googleSvc GoogleService, interviewSvc InterviewService, livekitSvc LiveKitService, aiAgentSvc AIAgentService, runnerClient *runner.Client, quotaSvc QuotaService, executionSvc ExecutionService) *Handlers {
 	return &Handlers{
 		studySvc:     studySvc,
 		userSvc:      userSvc,
@@ -101,5 +120,7 @@ func New(studySvc StudyService, userSvc UserService, googleSvc GoogleService, in
 		livekitSvc:   livekitSvc,
 		aiAgentSvc:   aiAgentSvc,
 		runnerClient: runnerClient,
+		quotaSvc:     quotaSvc,
+		executionSvc: executionSvc,
 	}
 }
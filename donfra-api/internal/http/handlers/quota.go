@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"donfra-api/internal/domain/quota"
+	"donfra-api/internal/domain/runner"
+	"donfra-api/internal/pkg/httputil"
+	"donfra-api/internal/pkg/metrics"
+)
+
+// reserveExecutions charges n executions to the caller's quota and reports the
+// remaining quota in response headers. It writes a 429 and returns false when
+// the quota is exhausted, or a 400 stating the cap when n exceeds the
+// caller's per-minute limit outright. Quota store failures are logged and do
+// not block execution. Must be used after RequireAuth middleware.
+func (h *Handlers) reserveExecutions(w http.ResponseWriter, r *http.Request, n int) bool {
+	if h.quotaSvc == nil {
+		return true
+	}
+
+	ctx := r.Context()
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return true
+	}
+	role, _ := ctx.Value("user_role").(string)
+
+	d, err := h.quotaSvc.Reserve(ctx, userID, role, n)
+	if err != nil {
+		log.Printf("[quota] reserve failed for user %d: %v", userID, err)
+		return true
+	}
+
+	setQuotaHeaders(w, d)
+	if d.Allowed {
+		return true
+	}
+
+	if d.Oversized {
+		httputil.WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":          d.Reason,
+			"max_executions": d.Limit,
+		})
+		return false
+	}
+
+	metrics.RecordQuotaRejection(role, d.Reason)
+	retryAfter := int(math.Ceil(d.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	httputil.WriteJSON(w, http.StatusTooManyRequests, map[string]any{
+		"error":               d.Reason,
+		"retry_after_seconds": retryAfter,
+	})
+	return false
+}
+
+func setQuotaHeaders(w http.ResponseWriter, d quota.Decision) {
+	if d.Limit > 0 {
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(d.ResetAt.Unix(), 10))
+	}
+	if d.CPULimit > 0 {
+		w.Header().Set("X-Quota-CPU-Limit", strconv.FormatFloat(d.CPULimit, 'f', 1, 64))
+		w.Header().Set("X-Quota-CPU-Remaining", strconv.FormatFloat(d.CPURemaining, 'f', 1, 64))
+	}
+}
+
+// recordUsage charges the run time of finished results to the caller's daily
+// CPU budget. It outlives the request so a disconnecting client is still charged.
+func (h *Handlers) recordUsage(ctx context.Context, results ...runner.ExecuteResult) {
+	userID, ok := getUserID(ctx)
+	if h.quotaSvc == nil || !ok {
+		return
+	}
+	if err := h.quotaSvc.RecordUsage(context.WithoutCancel(ctx), userID, usageOf(results...)); err != nil {
+		log.Printf("[quota] record usage failed for user %d: %v", userID, err)
+	}
+}
+
+// recordSubmissionUsage charges a finished submission once, however often it is polled.
+func (h *Handlers) recordSubmissionUsage(ctx context.Context, token string, result runner.ExecuteResult) {
+	userID, ok := getUserID(ctx)
+	if h.quotaSvc == nil || !ok || result.Status.ID <= runner.StatusProcessing {
+		return
+	}
+	if err := h.quotaSvc.RecordUsageOnce(context.WithoutCancel(ctx), userID, "submission:"+token, usageOf(result)); err != nil {
+		log.Printf("[quota] record usage failed for user %d: %v", userID, err)
+	}
+}
+
+// usageOf totals the CPU time of results, falling back to wall-clock time
+// for results the runner reported no CPU figures for. Cached results cost
+// nothing.
+func usageOf(results ...runner.ExecuteResult) time.Duration {
+	var ms int64
+	for _, res := range results {
+		if res.Cached {
+			continue
+		}
+		if cpu := res.CPUUserMs + res.CPUSysMs; cpu > 0 {
+			ms += cpu
+		} else {
+			ms += res.ExecutionTimeMs
+		}
+	}
+	return time.Duration(ms) * time.Millisecond
+}
@@ -1,39 +1,470 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+
+	"donfra-api/internal/domain/execution"
+	"donfra-api/internal/domain/interview"
 	"donfra-api/internal/domain/runner"
 	"donfra-api/internal/pkg/httputil"
 )
 
 func (h *Handlers) ExecuteCode(w http.ResponseWriter, r *http.Request) {
-	var req runner.ExecuteRequest
-	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+	req, execCtx, ok := h.decodeExecuteRequest(w, r)
+	if !ok || !h.reserveExecutions(w, r, 1) {
+		return
+	}
+
+	result, err := h.runnerClient.Execute(r.Context(), req)
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadGateway, "Code execution service unavailable")
+		return
+	}
+	h.recordUsage(r.Context(), *result)
+	h.recordExecution(r.Context(), execCtx, req, result, "")
+
+	httputil.WriteJSON(w, http.StatusOK, result)
+}
+
+// ExecuteCodeStream runs code and relays its place in the runner's queue
+// ("queue" events) and stdout/stderr to the client as Server-Sent Events
+// while it runs, followed by a final "result" event.
+func (h *Handlers) ExecuteCodeStream(w http.ResponseWriter, r *http.Request) {
+	req, execCtx, ok := h.decodeExecuteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if len(req.TestCases) > 0 {
+		httputil.WriteError(w, http.StatusBadRequest, "test_cases are not supported for streaming execution")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httputil.WriteError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	if !h.reserveExecutions(w, r, 1) {
+		return
+	}
+
+	// Set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	result, err := h.runnerClient.ExecuteStream(r.Context(), req, func(status runner.QueueStatus) {
+		data, _ := json.Marshal(status)
+		fmt.Fprintf(w, "event: queue\ndata: %s\n\n", data)
+		flusher.Flush()
+	}, func(chunk runner.OutputChunk) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", chunk.Stream, data)
+		flusher.Flush()
+	})
+	if err != nil {
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", "Code execution service unavailable")
+		flusher.Flush()
+		return
+	}
+	h.recordUsage(r.Context(), *result)
+	h.recordExecution(r.Context(), execCtx, req, result, "")
+
+	data, _ := json.Marshal(result)
+	fmt.Fprintf(w, "event: result\ndata: %s\n\n", data)
+	flusher.Flush()
+}
+
+const (
+	// sessionStartTimeout is how long a browser has to send its start message.
+	sessionStartTimeout = 10 * time.Second
+	// sessionWriteTimeout bounds each write to the browser.
+	sessionWriteTimeout = 10 * time.Second
+	// sessionPingInterval keeps proxies from closing a quiet connection.
+	sessionPingInterval = 30 * time.Second
+	// maxTranscriptBytes caps the stdin and output kept for history, which
+	// stores at most 4 KiB of each and marks longer text truncated.
+	maxTranscriptBytes = 8 << 10
+)
+
+// sessionUpgrader keeps the default same-origin check: the session is
+// authenticated by cookie, so other sites must not be able to open one.
+var sessionUpgrader = websocket.Upgrader{}
+
+// ExecuteCodeSession proxies an interactive session between the browser and
+// the runner over WebSockets, speaking the runner's protocol (see
+// runner.SessionMessage). The room_id and lesson_slug query parameters tag
+// the run like the body fields of the other execute routes. Stdin and output
+// are recorded in history when the session ends.
+func (h *Handlers) ExecuteCodeSession(w http.ResponseWriter, r *http.Request) {
+	execCtx := execution.Context{
+		RoomID:     r.URL.Query().Get("room_id"),
+		LessonSlug: r.URL.Query().Get("lesson_slug"),
+	}
+	if !h.validateExecutionContext(w, r, execCtx) || !h.reserveExecutions(w, r, 1) {
+		return
+	}
+
+	conn, err := sessionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error.
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(1 << 20)
+
+	var start runner.SessionMessage
+	conn.SetReadDeadline(time.Now().Add(sessionStartTimeout))
+	if err := conn.ReadJSON(&start); err != nil || start.Type != runner.SessionStart || start.SessionRequest == nil {
+		closeSession(conn, websocket.ClosePolicyViolation, "expected a start message")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	req := *start.SessionRequest
+	if req.LanguageID == 0 {
+		closeSession(conn, websocket.ClosePolicyViolation, "language_id is required")
+		return
+	}
+	req.CallerID, req.Priority = executionCaller(r, execCtx)
+
+	session, err := h.runnerClient.OpenSession(r.Context(), req)
+	if err != nil {
+		closeSession(conn, websocket.CloseTryAgainLater, "Code execution service unavailable")
+		return
+	}
+	defer session.Close()
+
+	// The browser going away closes the runner session, which kills the program.
+	var mu sync.Mutex
+	var stdin strings.Builder
+	go func() {
+		defer session.Close()
+		for {
+			var msg runner.SessionMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch msg.Type {
+			case runner.SessionStdin:
+				mu.Lock()
+				appendTranscript(&stdin, msg.Data)
+				mu.Unlock()
+				err = session.WriteStdin(msg.Data)
+			case runner.SessionEOF:
+				err = session.CloseInput()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(sessionPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(sessionWriteTimeout))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var stdout, stderr strings.Builder
+	for {
+		msg, err := session.Next()
+		if err != nil {
+			closeSession(conn, websocket.CloseInternalServerErr, "Code execution service unavailable")
+			return
+		}
+		switch msg.Type {
+		case runner.SessionStdout:
+			appendTranscript(&stdout, msg.Data)
+		case runner.SessionStderr:
+			appendTranscript(&stderr, msg.Data)
+		}
+		conn.SetWriteDeadline(time.Now().Add(sessionWriteTimeout))
+		if err := conn.WriteJSON(msg); err != nil {
+			return
+		}
+		if msg.Type == runner.SessionResult && msg.Result != nil {
+			result := *msg.Result
+			result.Stdout, result.Stderr = stdout.String(), stderr.String()
+			mu.Lock()
+			run := runner.ExecuteRequest{SourceCode: req.SourceCode, LanguageID: req.LanguageID, Stdin: stdin.String()}
+			mu.Unlock()
+			h.recordUsage(r.Context(), result)
+			h.recordExecution(r.Context(), execCtx, run, &result, "")
+			closeSession(conn, websocket.CloseNormalClosure, "")
+			return
+		}
+	}
+}
+
+// appendTranscript adds s to b up to maxTranscriptBytes.
+func appendTranscript(b *strings.Builder, s string) {
+	if room := maxTranscriptBytes - b.Len(); len(s) > room {
+		s = s[:max(room, 0)]
+	}
+	b.WriteString(s)
+}
+
+// closeSession sends a close frame; the caller closes the connection.
+func closeSession(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(sessionWriteTimeout))
+}
+
+// ExecuteCodeBatch runs many submissions and returns their results in order.
+func (h *Handlers) ExecuteCodeBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Items []runner.ExecuteRequest `json:"items"`
+		execution.Context
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<20)).Decode(&req); err != nil {
 		httputil.WriteError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
+	if len(req.Items) == 0 {
+		httputil.WriteError(w, http.StatusBadRequest, "items is required")
+		return
+	}
+
+	if !h.validateExecutionContext(w, r, req.Context) || !h.reserveExecutions(w, r, len(req.Items)) {
+		return
+	}
+
+	for i := range req.Items {
+		if req.Items[i].TimeoutMs == 0 {
+			req.Items[i].TimeoutMs = 5000
+		}
+		req.Items[i].Trusted, req.Items[i].Cache = false, false
+		req.Items[i].CallerID, req.Items[i].Priority = executionCaller(r, req.Context)
+	}
+
+	results, err := h.runnerClient.ExecuteBatch(r.Context(), req.Items)
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadGateway, "Code execution service unavailable")
+		return
+	}
+	h.recordUsage(r.Context(), results...)
+	for i := range results {
+		h.recordExecution(r.Context(), req.Context, req.Items[i], &results[i], "")
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// SubmitCode queues code for asynchronous execution and returns its token.
+// With ?wait=true the response carries the finished result instead.
+func (h *Handlers) SubmitCode(w http.ResponseWriter, r *http.Request) {
+	req, execCtx, ok := h.decodeExecuteRequest(w, r)
+	if !ok || !h.reserveExecutions(w, r, 1) {
+		return
+	}
+
+	if r.URL.Query().Get("wait") == "true" {
+		result, err := h.runnerClient.SubmitAndWait(r.Context(), req)
+		if err != nil {
+			httputil.WriteError(w, http.StatusBadGateway, "Code execution service unavailable")
+			return
+		}
+		h.recordSubmissionUsage(r.Context(), result.Token, *result)
+		h.recordExecution(r.Context(), execCtx, req, result, result.Token)
+		httputil.WriteJSON(w, http.StatusCreated, result)
+		return
+	}
+
+	token, err := h.runnerClient.Submit(r.Context(), req)
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadGateway, "Code execution service unavailable")
+		return
+	}
+	h.recordExecution(r.Context(), execCtx, req, nil, token)
+	go h.watchSubmission(context.WithoutCancel(r.Context()), token, req.CallerID)
+
+	httputil.WriteJSON(w, http.StatusCreated, map[string]string{"token": token})
+}
+
+const (
+	// submissionPollInterval is how often watchSubmission polls the runner.
+	submissionPollInterval = time.Second
+	// submissionWatchTimeout bounds how long a submission is watched; it
+	// outlasts the runner's queue wait and longest execution.
+	submissionWatchTimeout = 10 * time.Minute
+)
+
+// watchSubmission polls a submission until it finishes, then charges its
+// run time to the submitter and stores its outcome, so usage does not depend
+// on the client polling GetSubmission. ctx carries the submitter's identity.
+func (h *Handlers) watchSubmission(ctx context.Context, token, callerID string) {
+	ctx, cancel := context.WithTimeout(ctx, submissionWatchTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(submissionPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[runner] gave up watching submission %s: %v", token, ctx.Err())
+			return
+		case <-ticker.C:
+		}
+		result, err := h.runnerClient.GetSubmission(ctx, token, callerID)
+		if errors.Is(err, runner.ErrSubmissionNotFound) {
+			log.Printf("[runner] submission %s expired before it finished", token)
+			return
+		}
+		if err != nil || result.Status.ID <= runner.StatusProcessing {
+			continue
+		}
+		h.recordSubmissionUsage(ctx, token, *result)
+		h.completeExecution(ctx, token, *result)
+		return
+	}
+}
+
+// GetSubmission polls the state of a submission created by SubmitCode.
+// Only the user who submitted it can see it; for anyone else it does not
+// exist.
+func (h *Handlers) GetSubmission(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	callerID, _ := executionCaller(r, execution.Context{})
+
+	result, err := h.runnerClient.GetSubmission(r.Context(), token, callerID)
+	if errors.Is(err, runner.ErrSubmissionNotFound) {
+		httputil.WriteError(w, http.StatusNotFound, "Submission not found")
+		return
+	}
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadGateway, "Code execution service unavailable")
+		return
+	}
+	h.completeExecution(r.Context(), token, *result)
+
+	httputil.WriteJSON(w, http.StatusOK, result)
+}
+
+// ListLanguages returns the runner's language catalog so clients need not
+// hard-code language IDs.
+func (h *Handlers) ListLanguages(w http.ResponseWriter, r *http.Request) {
+	languages, err := h.runnerClient.Languages(r.Context())
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadGateway, "Code execution service unavailable")
+		return
+	}
+
+	httputil.WriteJSON(w, http.StatusOK, map[string]any{"languages": languages})
+}
+
+// decodeExecuteRequest parses and validates an execute request body along
+// with its optional room/lesson context, writing a 400 response and
+// returning false when it is invalid.
+func (h *Handlers) decodeExecuteRequest(w http.ResponseWriter, r *http.Request) (runner.ExecuteRequest, execution.Context, bool) {
+	var body struct {
+		runner.ExecuteRequest
+		execution.Context
+	}
+	// Test inputs can run to several megabytes.
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<20)).Decode(&body); err != nil {
+		httputil.WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return body.ExecuteRequest, body.Context, false
+	}
+	req, execCtx := body.ExecuteRequest, body.Context
+
 	if req.SourceCode == "" {
 		httputil.WriteError(w, http.StatusBadRequest, "source_code is required")
-		return
+		return req, execCtx, false
 	}
 
 	if req.LanguageID == 0 {
 		httputil.WriteError(w, http.StatusBadRequest, "language_id is required")
-		return
+		return req, execCtx, false
 	}
 
 	if req.TimeoutMs == 0 {
 		req.TimeoutMs = 5000
 	}
 
-	result, err := h.runnerClient.Execute(r.Context(), req)
+	if !h.validateExecutionContext(w, r, execCtx) {
+		return req, execCtx, false
+	}
+
+	// Only server-side callers may mark code as trusted or cacheable: a
+	// cached result costs no CPU quota and is shared across users.
+	req.Trusted, req.Cache = false, false
+	req.CallerID, req.Priority = executionCaller(r, execCtx)
+
+	return req, execCtx, true
+}
+
+// executionCaller places an execution in the runner's queue: each user gets
+// a fair share of it, and runs in interview rooms go ahead of practice. Only
+// call it once execCtx has passed validateExecutionContext, so interview
+// priority goes only to the room's owner and participants.
+func executionCaller(r *http.Request, execCtx execution.Context) (string, runner.Priority) {
+	var callerID string
+	if userID, ok := getUserID(r.Context()); ok {
+		callerID = "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	if execCtx.RoomID != "" {
+		return callerID, runner.PriorityInterview
+	}
+	return callerID, runner.PriorityPractice
+}
+
+// validateExecutionContext rejects executions that name an unknown interview
+// room or one the caller is not in.
+func (h *Handlers) validateExecutionContext(w http.ResponseWriter, r *http.Request, execCtx execution.Context) bool {
+	if execCtx.RoomID == "" {
+		return true
+	}
+	if h.interviewSvc == nil {
+		httputil.WriteError(w, http.StatusInternalServerError, "interview service unavailable")
+		return false
+	}
+	room, err := h.interviewSvc.GetRoomByID(r.Context(), execCtx.RoomID)
 	if err != nil {
-		httputil.WriteError(w, http.StatusBadGateway, "Code execution service unavailable")
-		return
+		if errors.Is(err, interview.ErrRoomNotFound) {
+			httputil.WriteError(w, http.StatusBadRequest, "room not found")
+		} else {
+			httputil.WriteError(w, http.StatusInternalServerError, "failed to get room")
+		}
+		return false
 	}
+	if !inRoom(r, room) {
+		httputil.WriteError(w, http.StatusForbidden, "not a member of this room")
+		return false
+	}
+	return true
+}
 
-	httputil.WriteJSON(w, http.StatusOK, result)
+// inRoom reports whether the caller is in room: its owner, or a participant
+// holding the room_access cookie that JoinInterviewRoomHandler sets.
+func inRoom(r *http.Request, room *interview.InterviewRoom) bool {
+	if userID, ok := getUserID(r.Context()); ok && room.OwnerID == userID {
+		return true
+	}
+	cookie, err := r.Cookie("room_access")
+	return err == nil && cookie.Value == room.RoomID
 }
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -30,6 +33,16 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// Hijack implements http.Hijacker so WebSocket upgrades work through this wrapper.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
 // Metrics middleware records HTTP request metrics for Prometheus
 func Metrics(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
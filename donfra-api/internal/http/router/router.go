@@ -9,9 +9,11 @@ import (
 
 	"donfra-api/internal/config"
 	"donfra-api/internal/domain/aiagent"
+	"donfra-api/internal/domain/execution"
 	"donfra-api/internal/domain/google"
 	"donfra-api/internal/domain/interview"
 	"donfra-api/internal/domain/livekit"
+	"donfra-api/internal/domain/quota"
 	"donfra-api/internal/domain/runner"
 	"donfra-api/internal/domain/study"
 	"donfra-api/internal/domain/user"
@@ -19,7 +21,7 @@ import (
 	"donfra-api/internal/http/middleware"
 )
 
-func New(cfg config.Config, studySvc *study.Service, userSvc *user.Service, googleSvc *google.GoogleOAuthService, interviewSvc interview.Service, livekitSvc *livekit.Service, aiAgentSvc *aiagent.Service, runnerClient *runner.Client) http.Handler {
+func New(cfg config.Config, studySvc *study.Service, userSvc *user.Service, googleSvc *google.GoogleOAuthService, interviewSvc interview.Service, livekitSvc *livekit.Service, aiAgentSvc *aiagent.Service, runnerClient *runner.Client, quotaSvc *quota.Service, executionSvc *execution.Service) http.Handler {
 	root := chi.NewRouter()
 
 	// Tracing middleware (must be first to capture all requests)
@@ -31,13 +33,13 @@ func New(cfg config.Config, studySvc *study.Service, userSvc *user.Service, goog
 	root.Use(cors.Handler(cors.Options{
 		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Content-Type", "X-CSRF-Token", "Authorization"},
-		ExposedHeaders:   []string{"X-Request-Id"},
+		ExposedHeaders:   []string{"X-Request-Id", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Quota-CPU-Limit", "X-Quota-CPU-Remaining"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 	root.Use(middleware.RequestID)
 
-	h := handlers.New(studySvc, userSvc, googleSvc, interviewSvc, livekitSvc, aiAgentSvc, runnerClient)
+	h := handlers.New(studySvc, userSvc, googleSvc, interviewSvc, livekitSvc, aiAgentSvc, runnerClient, quotaSvc, executionSvc)
 	v1 := chi.NewRouter()
 
 	// System endpoints
@@ -93,6 +95,7 @@ func New(cfg config.Config, studySvc *study.Service, userSvc *user.Service, goog
 	v1.With(middleware.RequireAuth(userSvc)).Get("/interview/my-rooms", h.GetMyRoomsHandler)
 	v1.Get("/interview/rooms/{room_id}/status", h.GetRoomStatusHandler) // Public: get room status by room_id
 	v1.With(middleware.RequireAuth(userSvc), middleware.RequireAdminOrAbove()).Get("/interview/rooms/all", h.GetAllRoomsHandler) // Admin or God: get all rooms
+	v1.With(middleware.RequireAuth(userSvc)).Get("/interview/rooms/{room_id}/executions", h.ListRoomExecutionsHandler) // Room owner: code runs in the room
 
 	// ===== LiveKit Live Streaming Routes =====
 	// Admin or God: create and end sessions
@@ -106,6 +109,16 @@ func New(cfg config.Config, studySvc *study.Service, userSvc *user.Service, goog
 
 	// ===== Code Execution Routes =====
 	v1.With(middleware.RequireAuth(userSvc)).Post("/execute", h.ExecuteCode)
+	v1.With(middleware.RequireAuth(userSvc)).Post("/execute/stream", h.ExecuteCodeStream)
+	v1.With(middleware.RequireAuth(userSvc)).Get("/execute/session", h.ExecuteCodeSession)
+	v1.With(middleware.RequireAuth(userSvc)).Post("/execute/batch", h.ExecuteCodeBatch)
+	v1.With(middleware.RequireAuth(userSvc)).Post("/execute/submissions", h.SubmitCode)
+	v1.With(middleware.RequireAuth(userSvc)).Get("/execute/submissions/{token}", h.GetSubmission)
+	v1.Get("/languages", h.ListLanguages)
+
+	// ===== Execution History Routes =====
+	v1.With(middleware.RequireAuth(userSvc)).Get("/executions/me", h.ListMyExecutionsHandler)
+	v1.With(middleware.RequireAuth(userSvc), middleware.RequireAdminOrAbove()).Get("/admin/executions", h.ListAllExecutionsHandler)
 
 	// ===== AI Agent Routes =====
 	// VIP and Admin only: AI-powered code analysis and chat
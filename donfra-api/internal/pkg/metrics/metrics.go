@@ -95,6 +95,15 @@ var (
 		},
 		[]string{"type", "status"}, // type: analyze, chat; status: success, failed
 	)
+
+	// Execution quota metrics
+	ExecutionQuotaRejectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "donfra_execution_quota_rejections_total",
+			Help: "Total number of code executions rejected by user quotas",
+		},
+		[]string{"role", "reason"},
+	)
 )
 
 // RecordHTTPRequest records metrics for an HTTP request
@@ -130,6 +139,11 @@ func RecordAIRequest(requestType string, success bool) {
 	AIRequestsTotal.WithLabelValues(requestType, status).Inc()
 }
 
+// RecordQuotaRejection records an execution rejected by a user quota
+func RecordQuotaRejection(role, reason string) {
+	ExecutionQuotaRejectionsTotal.WithLabelValues(role, reason).Inc()
+}
+
 // UpdateLessonCounts updates the lesson gauge metrics
 func UpdateLessonCounts(total, published int64) {
 	LessonsTotal.Set(float64(total))
@@ -1,99 +1,410 @@
 #!/bin/sh
 # donfra-jail entrypoint
-# Executes user code and publishes result to Redis
+# Compiles (if needed) and executes user code, then publishes the result to Redis
 set -e
 
 # Parse Redis address
 REDIS_HOST="${REDIS_HOST:-redis}"
 REDIS_PORT="${REDIS_PORT:-6379}"
 TIMEOUT_MS="${TIMEOUT_MS:-5000}"
+COMPILE_TIMEOUT_MS="${COMPILE_TIMEOUT_MS:-10000}"
+RUN_MEMORY_MB="${RUN_MEMORY_MB:-0}"
 MAX_OUTPUT_BYTES="${MAX_OUTPUT_BYTES:-65536}"
+STREAM_OUTPUT="${STREAM_OUTPUT:-false}"
+INTERACTIVE="${INTERACTIVE:-false}"
 
-# Publish helper
+# Toolchains that cache build output need a writable location.
+export GOCACHE="${GOCACHE:-/tmp/go-build}"
+
+# This script runs as the jail user and holds the execution's keys. User code
+# (compile and run steps) runs as the sandbox user through jail-rusage -s, so
+# it can read neither this process's environment nor PRIV_DIR, where the
+# inputs, outputs and usage files live. It shares WORK_DIR.
+PRIV_DIR="/tmp/jail"
+mkdir -p "$PRIV_DIR"
+chmod 700 "$PRIV_DIR"
+
+# hmac KEY MESSAGE -- hex HMAC-SHA256 of MESSAGE. The key is passed in the
+# environment, since command lines are visible to every uid in the pod.
+HMAC_PY='import hashlib, hmac, os, sys; print(hmac.new(os.environb[b"HMAC_KEY"], sys.stdin.buffer.read(), hashlib.sha256).hexdigest())'
+hmac() {
+  printf '%s' "$2" | HMAC_KEY="$1" python3 -c "$HMAC_PY"
+}
+
+# hmac_file KEY FILE -- hex HMAC-SHA256 of FILE's content
+hmac_file() {
+  HMAC_KEY="$1" python3 -c "$HMAC_PY" < "$2"
+}
+
+# reap_sandbox -- kill whatever user code left running, which could hold the
+# output pipes open or, in a warm pod, outlive its execution
+reap_sandbox() {
+  jail-rusage -s /dev/null 5000 /bin/sh -c 'kill -9 -1' >/dev/null 2>&1 || :
+}
+
+# redis_cli ARGS... -- redis-cli logged in as REDIS_USER, the Redis user of
+# this pod or execution, which may only use its own keys and channel. The
+# password goes in the environment, since command lines are visible to every
+# uid in the pod.
+redis_cli() {
+  if [ -n "$REDIS_USER" ]; then
+    REDISCLI_AUTH="$REDIS_PASSWORD" redis-cli --user "$REDIS_USER" -h "$REDIS_HOST" -p "$REDIS_PORT" "$@"
+  else
+    redis-cli -h "$REDIS_HOST" -p "$REDIS_PORT" "$@"
+  fi
+}
+
+# Publish helper. Messages are "<hmac> <json>" signed with RESULT_KEY, the
+# per-execution key, so the runner can tell them from messages forged by
+# other Redis clients, including the user program.
 publish_result() {
-  redis-cli -h "$REDIS_HOST" -p "$REDIS_PORT" PUBLISH "exec:${EXEC_ID}" "$1" >/dev/null 2>&1 || true
+  redis_cli PUBLISH "exec:${EXEC_ID}" "$(hmac "$RESULT_KEY" "$1") $1" >/dev/null 2>&1 || true
 }
 
-# Validate required env vars
-if [ -z "$EXEC_ID" ] || [ -z "$SOURCE_CODE" ] || [ -z "$LANGUAGE_ID" ]; then
-  publish_result '{"execution_id":"'"$EXEC_ID"'","status_id":11,"status_desc":"Runtime Error","stdout_b64":"","stderr_b64":"","message":"missing required env vars","exit_code":1,"execution_time_ms":0}'
-  exit 0
+# publish_error MESSAGE -- report a setup failure as a Runtime Error
+publish_error() {
+  publish_result '{"execution_id":"'"$EXEC_ID"'","status_id":11,"status_desc":"Runtime Error","stdout_b64":"","stderr_b64":"","message":"'"$1"'","exit_code":1,"execution_time_ms":0}'
+}
+
+now_ms() {
+  date +%s%3N 2>/dev/null || echo 0
+}
+
+# b64 FILE -- base64 without line wrapping
+b64() {
+  base64 -w0 "$1" 2>/dev/null || base64 "$1" | tr -d '\n'
+}
+
+# redis_cmd ARGS... -- run a Redis command and print the raw reply
+redis_cmd() {
+  redis_cli --raw "$@"
+}
+
+# Warm pool mode: instead of reading one execution from the environment, wait
+# for executions pushed to POOL_QUEUE. The pod advertises itself in
+# POOL_IDLE_SET while idle; the runner claims it by removing it from the set.
+if [ -n "$POOL_QUEUE" ]; then
+  POOL_MAX_EXECUTIONS="${POOL_MAX_EXECUTIONS:-1}"
+  POOL_IDLE_TIMEOUT_SEC="${POOL_IDLE_TIMEOUT_SEC:-600}"
+  RUNS=0
+
+  while :; do
+    # The previous execution's files belong to the sandbox user.
+    reap_sandbox
+    [ -d /tmp/box ] && { jail-rusage -s /dev/null 10000 find /tmp/box -mindepth 1 -delete >/dev/null 2>&1 || :; }
+    rm -rf /tmp/box "$PRIV_DIR"
+    redis_cmd SADD "$POOL_IDLE_SET" "$POD_NAME" >/dev/null
+
+    REPLY=$(redis_cmd BRPOP "$POOL_QUEUE" "$POOL_IDLE_TIMEOUT_SEC")
+    if [ -z "$REPLY" ]; then
+      # Idle timeout. If the runner claimed this pod meanwhile, its job is on the way.
+      if [ "$(redis_cmd SREM "$POOL_IDLE_SET" "$POD_NAME")" = "1" ]; then
+        exit 0
+      fi
+      REPLY=$(redis_cmd BRPOP "$POOL_QUEUE" 10)
+      [ -z "$REPLY" ] && exit 0
+    fi
+
+    # BRPOP replies with the key, then the job: a JSON object of env vars
+    # signed with this pod's POOL_KEY. Anything else is ignored.
+    MSG=$(printf '%s\n' "$REPLY" | sed -n 2p)
+    JOB=${MSG#* }
+    if [ "${MSG%% *}" != "$(hmac "$POOL_KEY" "$JOB")" ]; then
+      echo "rejecting job with invalid signature" >&2
+      continue
+    fi
+
+    RETIRE=$(printf '%s' "$JOB" | jq -r '.POOL_RETIRE // "true"')
+    (
+      unset POOL_KEY
+      eval "$(printf '%s' "$JOB" | jq -r 'to_entries[] | "export \(.key)=\(.value | tostring | @sh)"')"
+      POOL_QUEUE="" exec "$0"
+    ) || true
+
+    RUNS=$(( RUNS + 1 ))
+    if [ "$RETIRE" = "true" ] || [ "$RUNS" -ge "$POOL_MAX_EXECUTIONS" ]; then
+      exit 0
+    fi
+  done
 fi
 
-# Determine interpreter and extension
-case "$LANGUAGE_ID" in
-  71) INTERP="/usr/bin/python3"; EXT=".py" ;;
-  63) INTERP="/usr/bin/node";    EXT=".js" ;;
-  *)
-    publish_result '{"execution_id":"'"$EXEC_ID"'","status_id":11,"status_desc":"Runtime Error","stdout_b64":"","stderr_b64":"","message":"unsupported language","exit_code":1,"execution_time_ms":0}'
+# The inputs (SOURCE_CODE, STDIN_DATA, STDIN_BATCH and FILES) are fetched
+# from Redis at PAYLOAD_KEY: a JSON object encrypted and signed with
+# RESULT_KEY. Runners that predate the encryption send it as plain JSON. The
+# inputs are kept in unexported shell variables, which have no size limit.
+# Runners that predate PAYLOAD_KEY put them in the environment.
+if [ -n "$PAYLOAD_KEY" ]; then
+  redis_cmd GET "$PAYLOAD_KEY" > "$PRIV_DIR/payload_msg.txt" 2>/dev/null || :
+  head -c 64 "$PRIV_DIR/payload_msg.txt" > "$PRIV_DIR/payload_sig.txt"
+  tail -c +66 "$PRIV_DIR/payload_msg.txt" | tr -d '\n' > "$PRIV_DIR/payload.txt"
+  if [ "$(cat "$PRIV_DIR/payload_sig.txt")" != "$(hmac_file "$RESULT_KEY" "$PRIV_DIR/payload.txt")" ]; then
+    publish_error "failed to fetch the execution payload"
     exit 0
-    ;;
-esac
+  fi
+  if [ "$(head -c 1 "$PRIV_DIR/payload.txt")" != "{" ]; then
+    openssl enc -d -aes-256-ctr -pbkdf2 -a -A -pass env:RESULT_KEY \
+      -in "$PRIV_DIR/payload.txt" -out "$PRIV_DIR/payload.json" || {
+      publish_error "failed to decrypt the execution payload"
+      exit 0
+    }
+    mv "$PRIV_DIR/payload.json" "$PRIV_DIR/payload.txt"
+  fi
+  SOURCE_CODE=$(jq -r '.SOURCE_CODE // ""' "$PRIV_DIR/payload.txt")
+  STDIN_DATA=$(jq -r '.STDIN_DATA // ""' "$PRIV_DIR/payload.txt")
+  STDIN_BATCH=$(jq -r '.STDIN_BATCH // ""' "$PRIV_DIR/payload.txt")
+  FILES=$(jq -r '.FILES // ""' "$PRIV_DIR/payload.txt")
+  rm -f "$PRIV_DIR"/payload*.txt
+fi
+
+# Validate required env vars. REPL sessions may start with no source.
+if [ -z "$EXEC_ID" ] || [ -z "$LANGUAGE_ID" ] || { [ -z "$SOURCE_CODE" ] && [ "$INTERACTIVE" != "true" ]; }; then
+  publish_error "missing required env vars"
+  exit 0
+fi
+
+# Runners that predate COMPILE_CMD/RUN_CMD only send LANGUAGE_ID.
+if [ -z "$RUN_CMD" ] || [ "$RUN_CMD" = "null" ]; then
+  case "$LANGUAGE_ID" in
+    71) SOURCE_FILE="main.py"; RUN_CMD='["/usr/bin/python3","{source}"]' ;;
+    63) SOURCE_FILE="main.js"; RUN_CMD='["/usr/bin/node","{source}"]' ;;
+    *)
+      publish_error "unsupported language"
+      exit 0
+      ;;
+  esac
+fi
+
+WORK_DIR="/tmp/box"
+SOURCE_PATH="${WORK_DIR}/${SOURCE_FILE:-main}"
+BINARY_PATH="${WORK_DIR}/main"
+mkdir -p "$WORK_DIR"
+
+# expand_cmd JSON -- print an argv template as shell words with the
+# {dir}, {source} and {binary} placeholders substituted
+expand_cmd() {
+  printf '%s' "$1" | jq -r --arg dir "$WORK_DIR" --arg source "$SOURCE_PATH" --arg binary "$BINARY_PATH" \
+    '(. // []) | map(gsub("{dir}"; $dir) | gsub("{source}"; $source) | gsub("{binary}"; $binary)) | @sh'
+}
 
 # Decode source code from base64
-echo "$SOURCE_CODE" | base64 -d > "/tmp/code${EXT}" 2>/dev/null
-if [ $? -ne 0 ]; then
-  publish_result '{"execution_id":"'"$EXEC_ID"'","status_id":11,"status_desc":"Runtime Error","stdout_b64":"","stderr_b64":"","message":"failed to decode source code","exit_code":1,"execution_time_ms":0}'
+mkdir -p "$(dirname "$SOURCE_PATH")"
+if ! echo "$SOURCE_CODE" | base64 -d > "$SOURCE_PATH" 2>/dev/null; then
+  publish_error "failed to decode source code"
   exit 0
 fi
 
-# Decode stdin
-STDIN_FILE="/tmp/stdin.txt"
-if [ -n "$STDIN_DATA" ]; then
-  echo "$STDIN_DATA" | base64 -d > "$STDIN_FILE" 2>/dev/null || : > "$STDIN_FILE"
-else
-  : > "$STDIN_FILE"
+# Project files: a JSON array of {path, content_b64}. The runner has checked
+# that every path stays inside WORK_DIR.
+if [ -n "$FILES" ]; then
+  COUNT=$(printf '%s' "$FILES" | jq length)
+  i=0
+  while [ "$i" -lt "$COUNT" ]; do
+    FILE_PATH="${WORK_DIR}/$(printf '%s' "$FILES" | jq -r ".[$i].path")"
+    mkdir -p "$(dirname "$FILE_PATH")"
+    if ! printf '%s' "$FILES" | jq -r ".[$i].content_b64" | base64 -d > "$FILE_PATH" 2>/dev/null; then
+      publish_error "failed to decode project files"
+      exit 0
+    fi
+    i=$(( i + 1 ))
+  done
 fi
 
-# Calculate timeout in seconds (round up)
-TIMEOUT_SEC=$(( (TIMEOUT_MS + 999) / 1000 ))
+# The sandbox user compiles and runs in WORK_DIR.
+chmod -R a+rwX "$WORK_DIR"
 
-# Execute with timeout
-STDOUT_FILE="/tmp/stdout.txt"
-STDERR_FILE="/tmp/stderr.txt"
-START_MS=$(date +%s%3N 2>/dev/null || echo 0)
+# Compile step (compiled languages only)
+COMPILE_ARGS=$(expand_cmd "$COMPILE_CMD")
+if [ -n "$COMPILE_ARGS" ]; then
+  eval "set -- $COMPILE_ARGS"
 
-set +e
-timeout "${TIMEOUT_SEC}s" "$INTERP" "/tmp/code${EXT}" < "$STDIN_FILE" \
-  > "$STDOUT_FILE" 2> "$STDERR_FILE"
-EXIT_CODE=$?
-set -e
+  set +e
+  (unset RESULT_KEY REDIS_PASSWORD; cd "$WORK_DIR" && HOME="$WORK_DIR" jail-rusage -s "$PRIV_DIR/compile_usage.txt" "$COMPILE_TIMEOUT_MS" "$@") \
+    < /dev/null > "$PRIV_DIR/compile.txt" 2>&1
+  COMPILE_EXIT=$?
+  reap_sandbox
+  set -e
 
-END_MS=$(date +%s%3N 2>/dev/null || echo 0)
-DURATION=$(( END_MS - START_MS ))
-[ "$DURATION" -lt 0 ] && DURATION=0
-
-# Truncate output to MAX_OUTPUT_BYTES
-head -c "$MAX_OUTPUT_BYTES" "$STDOUT_FILE" > /tmp/stdout_trunc.txt
-head -c "$MAX_OUTPUT_BYTES" "$STDERR_FILE" > /tmp/stderr_trunc.txt
-
-# Determine status from exit code
-# 124 = timeout command killed the process
-# 137 = SIGKILL (OOM or external kill)
-if [ $EXIT_CODE -eq 124 ]; then
-  STATUS_ID=5; STATUS_DESC="Time Limit Exceeded"
-elif [ $EXIT_CODE -eq 137 ]; then
-  STATUS_ID=7; STATUS_DESC="Memory Limit Exceeded"
-elif [ $EXIT_CODE -ne 0 ]; then
-  STATUS_ID=11; STATUS_DESC="Runtime Error"
-else
-  STATUS_ID=3; STATUS_DESC="Accepted"
+  if [ $COMPILE_EXIT -ne 0 ]; then
+    head -c "$MAX_OUTPUT_BYTES" "$PRIV_DIR/compile.txt" > "$PRIV_DIR/compile_trunc.txt"
+    if [ $COMPILE_EXIT -eq 124 ]; then
+      MSG="compilation timed out"
+    else
+      MSG="compiler exited with code ${COMPILE_EXIT}"
+    fi
+    RESULT=$(printf '{"execution_id":"%s","status_id":6,"status_desc":"Compilation Error","stdout_b64":"","stderr_b64":"","compile_output_b64":"%s","message":"%s","exit_code":%d,"execution_time_ms":0}' \
+      "$EXEC_ID" "$(b64 "$PRIV_DIR/compile_trunc.txt")" "$MSG" "$COMPILE_EXIT")
+    publish_result "$RESULT"
+    exit 0
+  fi
 fi
 
-# Base64 encode stdout/stderr to avoid JSON escaping issues
-STDOUT_B64=$(base64 -w0 /tmp/stdout_trunc.txt 2>/dev/null || base64 /tmp/stdout_trunc.txt | tr -d '\n')
-STDERR_B64=$(base64 -w0 /tmp/stderr_trunc.txt 2>/dev/null || base64 /tmp/stderr_trunc.txt | tr -d '\n')
+RUN_ARGS=$(expand_cmd "$RUN_CMD")
 
-# Build result message (skip for successful runs)
-if [ $EXIT_CODE -eq 0 ]; then
-  MSG=""
-else
-  MSG="Process exited with code ${EXIT_CODE}"
+STDIN_FILE="$PRIV_DIR/stdin.txt"
+STDOUT_FILE="$PRIV_DIR/stdout.txt"
+STDERR_FILE="$PRIV_DIR/stderr.txt"
+USAGE_FILE="$PRIV_DIR/usage.txt"
+
+# stream_pipe STREAM FIFO FILE -- copy FIFO into FILE, publishing each read
+# as an output chunk until MAX_OUTPUT_BYTES have been sent. A read returns
+# whatever the program has written so far, so prompts without a trailing
+# newline reach an interactive client.
+stream_pipe() {
+  SENT=0
+  CHUNK="${3%.txt}_chunk.txt"
+  while dd bs=4096 count=1 of="$CHUNK" 2>/dev/null; do
+    N=$(wc -c < "$CHUNK")
+    [ "$N" -eq 0 ] && break
+    [ "$SENT" -le "$MAX_OUTPUT_BYTES" ] && cat "$CHUNK" >> "$3"
+    SENT=$(( SENT + N ))
+    [ "$SENT" -gt "$MAX_OUTPUT_BYTES" ] && continue
+    ELAPSED=$(( $(now_ms) - START_MS ))
+    publish_result "$(printf '{"type":"chunk","stream":"%s","data_b64":"%s","elapsed_ms":%d}' "$1" "$(b64 "$CHUNK")" "$ELAPSED")"
+  done < "$2"
+}
+
+# stdin_feed FIFO -- write the input of an interactive session into FIFO
+# until the end of input. The runner pushes it to stdin:EXEC_ID as
+# "<hmac> <seq> <base64>" messages signed with RESULT_KEY, ending with
+# "<hmac> <seq> -"; unsigned or out-of-sequence messages are dropped.
+stdin_feed() {
+  SEQ=0
+  exec 3> "$1"
+  while :; do
+    REPLY=$(redis_cmd BRPOP "stdin:${EXEC_ID}" 5) || { sleep 1; continue; }
+    [ -z "$REPLY" ] && continue
+    MSG=$(printf '%s\n' "$REPLY" | sed -n 2p)
+    BODY=${MSG#* }
+    [ "${MSG%% *}" = "$(hmac "$RESULT_KEY" "$BODY")" ] || continue
+    [ "${BODY%% *}" = "$SEQ" ] || continue
+    SEQ=$(( SEQ + 1 ))
+    DATA=${BODY#* }
+    [ "$DATA" = "-" ] && break
+    printf '%s' "$DATA" | base64 -d >&3 2>/dev/null || break
+  done
+  exec 3>&-
+}
+
+# run_program [INDEX] -- run the program on STDIN_FILE and publish its result;
+# INDEX tags the result with its position in a batch
+run_program() {
+  INDEX_FIELD=""
+  [ -n "$1" ] && INDEX_FIELD="\"index\":$1,"
+
+  : > "$STDOUT_FILE"
+  : > "$STDERR_FILE"
+  rm -f "$USAGE_FILE"
+  eval "set -- $RUN_ARGS"
+  START_MS=$(now_ms)
+
+  # Interactive sessions read stdin from the runner as it is sent.
+  STDIN_SOURCE="$STDIN_FILE"
+  FEED_PID=""
+  if [ "$INTERACTIVE" = "true" ]; then
+    rm -f "$PRIV_DIR/stdin.fifo"
+    mkfifo "$PRIV_DIR/stdin.fifo"
+    stdin_feed "$PRIV_DIR/stdin.fifo" &
+    FEED_PID=$!
+    STDIN_SOURCE="$PRIV_DIR/stdin.fifo"
+  fi
+
+  # Execute with timeout as the sandbox user; jail-rusage records CPU time,
+  # peak memory and how the program ended in USAGE_FILE. The container's
+  # memory limit covers the compile step, so jail-rusage kills a program that
+  # exceeds RUN_MEMORY_MB. Leftover processes are reaped before the output
+  # pipes are drained.
+  set +e
+  if [ "$STREAM_OUTPUT" = "true" ]; then
+    rm -f "$PRIV_DIR/stdout.fifo" "$PRIV_DIR/stderr.fifo"
+    mkfifo "$PRIV_DIR/stdout.fifo" "$PRIV_DIR/stderr.fifo"
+    stream_pipe stdout "$PRIV_DIR/stdout.fifo" "$STDOUT_FILE" &
+    STDOUT_PID=$!
+    stream_pipe stderr "$PRIV_DIR/stderr.fifo" "$STDERR_FILE" &
+    STDERR_PID=$!
+
+    (unset RESULT_KEY REDIS_PASSWORD; cd "$WORK_DIR" && HOME="$WORK_DIR" jail-rusage -m "$RUN_MEMORY_MB" -s "$USAGE_FILE" "$TIMEOUT_MS" "$@") \
+      < "$STDIN_SOURCE" > "$PRIV_DIR/stdout.fifo" 2> "$PRIV_DIR/stderr.fifo"
+    EXIT_CODE=$?
+    reap_sandbox
+    wait "$STDOUT_PID" "$STDERR_PID"
+  else
+    (unset RESULT_KEY REDIS_PASSWORD; cd "$WORK_DIR" && HOME="$WORK_DIR" jail-rusage -m "$RUN_MEMORY_MB" -s "$USAGE_FILE" "$TIMEOUT_MS" "$@") \
+      < "$STDIN_SOURCE" > "$STDOUT_FILE" 2> "$STDERR_FILE"
+    EXIT_CODE=$?
+    reap_sandbox
+  fi
+  [ -n "$FEED_PID" ] && kill "$FEED_PID" 2>/dev/null
+  set -e
+
+  END_MS=$(now_ms)
+  DURATION=$(( END_MS - START_MS ))
+  [ "$DURATION" -lt 0 ] && DURATION=0
+
+  # Usage line: user_ms sys_ms peak_rss_kb exit_code|- signal|- timed_out
+  CPU_USER_MS=0; CPU_SYS_MS=0; MEMORY_KB=0; EXIT_FIELD="$EXIT_CODE"; SIGNAL="-"; TIMED_OUT=0
+  if [ -s "$USAGE_FILE" ]; then
+    read -r CPU_USER_MS CPU_SYS_MS MEMORY_KB EXIT_FIELD SIGNAL TIMED_OUT < "$USAGE_FILE"
+  fi
+  [ "$EXIT_FIELD" = "-" ] && EXIT_FIELD="null"
+  [ "$SIGNAL" = "-" ] && SIGNAL=""
+
+  # Truncate output to MAX_OUTPUT_BYTES
+  STDOUT_TRUNCATED=false
+  STDERR_TRUNCATED=false
+  [ "$(wc -c < "$STDOUT_FILE")" -gt "$MAX_OUTPUT_BYTES" ] && STDOUT_TRUNCATED=true
+  [ "$(wc -c < "$STDERR_FILE")" -gt "$MAX_OUTPUT_BYTES" ] && STDERR_TRUNCATED=true
+  head -c "$MAX_OUTPUT_BYTES" "$STDOUT_FILE" > "$PRIV_DIR/stdout_trunc.txt"
+  head -c "$MAX_OUTPUT_BYTES" "$STDERR_FILE" > "$PRIV_DIR/stderr_trunc.txt"
+
+  # Determine status from how the program ended
+  # SIGKILL outside a timeout means the OOM killer (or an external kill)
+  if [ "$TIMED_OUT" = "1" ] || [ $EXIT_CODE -eq 124 ]; then
+    STATUS_ID=5; STATUS_DESC="Time Limit Exceeded"
+  elif [ "$SIGNAL" = "SIGKILL" ] || [ $EXIT_CODE -eq 137 ]; then
+    STATUS_ID=7; STATUS_DESC="Memory Limit Exceeded"
+  elif [ $EXIT_CODE -ne 0 ]; then
+    STATUS_ID=11; STATUS_DESC="Runtime Error"
+  else
+    STATUS_ID=3; STATUS_DESC="Accepted"
+  fi
+
+  # Base64 encode stdout/stderr to avoid JSON escaping issues
+  STDOUT_B64=$(b64 "$PRIV_DIR/stdout_trunc.txt")
+  STDERR_B64=$(b64 "$PRIV_DIR/stderr_trunc.txt")
+
+  # Build result message (skip for successful runs)
+  if [ $EXIT_CODE -eq 0 ]; then
+    MSG=""
+  elif [ -n "$SIGNAL" ]; then
+    MSG="Process was killed by ${SIGNAL}"
+  else
+    MSG="Process exited with code ${EXIT_CODE}"
+  fi
+
+  # Build result JSON and publish to Redis
+  RESULT=$(printf '{%s"execution_id":"%s","status_id":%d,"status_desc":"%s","stdout_b64":"%s","stderr_b64":"%s","message":"%s","exit_code":%s,"signal":"%s","execution_time_ms":%d,"cpu_user_ms":%d,"cpu_sys_ms":%d,"memory_kb":%d,"stdout_truncated":%s,"stderr_truncated":%s}' \
+    "$INDEX_FIELD" "$EXEC_ID" "$STATUS_ID" "$STATUS_DESC" "$STDOUT_B64" "$STDERR_B64" "$MSG" "$EXIT_FIELD" "$SIGNAL" "$DURATION" \
+    "$CPU_USER_MS" "$CPU_SYS_MS" "$MEMORY_KB" "$STDOUT_TRUNCATED" "$STDERR_TRUNCATED")
+  publish_result "$RESULT"
+}
+
+# STDIN_BATCH (a JSON array of base64 stdins) runs the program once per entry.
+if [ -n "$STDIN_BATCH" ]; then
+  COUNT=$(printf '%s' "$STDIN_BATCH" | jq length)
+  i=0
+  while [ "$i" -lt "$COUNT" ]; do
+    printf '%s' "$STDIN_BATCH" | jq -r ".[$i]" | base64 -d > "$STDIN_FILE" 2>/dev/null || : > "$STDIN_FILE"
+    run_program "$i"
+    i=$(( i + 1 ))
+  done
+  exit 0
 fi
 
-# Build result JSON
-RESULT=$(printf '{"execution_id":"%s","status_id":%d,"status_desc":"%s","stdout_b64":"%s","stderr_b64":"%s","message":"%s","exit_code":%d,"execution_time_ms":%d}' \
-  "$EXEC_ID" "$STATUS_ID" "$STATUS_DESC" "$STDOUT_B64" "$STDERR_B64" "$MSG" "$EXIT_CODE" "$DURATION")
+# Decode stdin
+if [ -n "$STDIN_DATA" ]; then
+  echo "$STDIN_DATA" | base64 -d > "$STDIN_FILE" 2>/dev/null || : > "$STDIN_FILE"
+else
+  : > "$STDIN_FILE"
+fi
 
-# Publish to Redis
-publish_result "$RESULT"
+run_program
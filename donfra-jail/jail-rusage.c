@@ -0,0 +1,231 @@
+/*
+ * jail-rusage [-m MEMORY_MB] [-s] USAGE_FILE TIMEOUT_MS PROG [ARGS...]
+ *
+ * Runs PROG with a wall-clock timeout and writes what it used to USAGE_FILE
+ * as one line:
+ *
+ *   <user_ms> <sys_ms> <peak_rss_kb> <exit_code|-> <signal|-> <timed_out 0|1>
+ *
+ * Exits like a shell would: PROG's exit code, 128+N when killed by signal N,
+ * or 124 on timeout. Figures cover PROG and the descendants it waited for.
+ *
+ * -m kills PROG with SIGKILL, like the OOM killer would, once its resident
+ * memory exceeds MEMORY_MB. It holds a run step to a smaller budget than the
+ * container's limit, which is sized for the compile step. Memory is sampled
+ * every few milliseconds, so the container's limit still bounds a burst
+ * between samples.
+ *
+ * -s runs PROG as the sandbox user (SANDBOX_UID), so user code cannot read
+ * the environment, memory or files of the entrypoint, which runs as the jail
+ * user (JAIL_UID) and holds the execution's keys. The binary is installed
+ * setuid root for this: it keeps its privileges only when started by the
+ * jail user, opens USAGE_FILE as that user, and otherwise uses them only to
+ * switch PROG's uid and to signal it.
+ */
+#include <errno.h>
+#include <fcntl.h>
+#include <grp.h>
+#include <signal.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/resource.h>
+#include <sys/time.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#define JAIL_UID 1000
+#define SANDBOX_UID 1001
+
+static volatile sig_atomic_t timed_out;
+static volatile pid_t child;
+
+static void on_alarm(int sig) {
+	(void)sig;
+	timed_out = 1;
+	kill(child, SIGKILL);
+}
+
+static void on_term(int sig) {
+	kill(child, sig);
+}
+
+static const char *signal_name(int sig) {
+	static char buf[16];
+	switch (sig) {
+	case SIGHUP:  return "SIGHUP";
+	case SIGINT:  return "SIGINT";
+	case SIGQUIT: return "SIGQUIT";
+	case SIGILL:  return "SIGILL";
+	case SIGTRAP: return "SIGTRAP";
+	case SIGABRT: return "SIGABRT";
+	case SIGBUS:  return "SIGBUS";
+	case SIGFPE:  return "SIGFPE";
+	case SIGKILL: return "SIGKILL";
+	case SIGUSR1: return "SIGUSR1";
+	case SIGSEGV: return "SIGSEGV";
+	case SIGUSR2: return "SIGUSR2";
+	case SIGPIPE: return "SIGPIPE";
+	case SIGALRM: return "SIGALRM";
+	case SIGTERM: return "SIGTERM";
+	case SIGXCPU: return "SIGXCPU";
+	case SIGXFSZ: return "SIGXFSZ";
+	case SIGSYS:  return "SIGSYS";
+	}
+	snprintf(buf, sizeof(buf), "SIG%d", sig);
+	return buf;
+}
+
+/* rss_kb returns the resident memory of pid, or 0 if it cannot be read. */
+static long rss_kb(pid_t pid) {
+	char path[32];
+	long size, resident = 0;
+	snprintf(path, sizeof(path), "/proc/%d/statm", (int)pid);
+	FILE *f = fopen(path, "r");
+	if (!f) {
+		return 0;
+	}
+	if (fscanf(f, "%ld %ld", &size, &resident) != 2) {
+		resident = 0;
+	}
+	fclose(f);
+	return resident * (sysconf(_SC_PAGESIZE) / 1024);
+}
+
+static long ms(struct timeval tv) {
+	return tv.tv_sec * 1000L + tv.tv_usec / 1000;
+}
+
+/* drop_to_sandbox makes the calling process the sandbox user for good. */
+static int drop_to_sandbox(void) {
+	if (setgroups(0, NULL) != 0 || setgid(SANDBOX_UID) != 0 || setuid(SANDBOX_UID) != 0) {
+		return -1;
+	}
+	/* Regaining root must fail, or the switch did not take. */
+	if (setuid(0) == 0) {
+		errno = EPERM;
+		return -1;
+	}
+	return 0;
+}
+
+int main(int argc, char **argv) {
+	/* Only the jail user may use the setuid bit; anyone else, such as user
+	 * code calling this binary, runs it with their own uid. */
+	if (geteuid() == 0 && getuid() != 0 && getuid() != JAIL_UID) {
+		if (setgid(getgid()) != 0 || setuid(getuid()) != 0) {
+			return 125;
+		}
+	}
+
+	long memory_mb = 0;
+	int sandbox = 0;
+	int opt;
+	while ((opt = getopt(argc, argv, "+m:s")) != -1) {
+		switch (opt) {
+		case 'm':
+			memory_mb = strtol(optarg, NULL, 10);
+			break;
+		case 's':
+			sandbox = 1;
+			break;
+		default:
+			goto usage;
+		}
+	}
+	argc -= optind - 1;
+	argv += optind - 1;
+	if (argc < 4) {
+	usage:
+		fprintf(stderr, "usage: jail-rusage [-m MEMORY_MB] [-s] USAGE_FILE TIMEOUT_MS PROG [ARGS...]\n");
+		return 125;
+	}
+	long timeout_ms = strtol(argv[2], NULL, 10);
+
+	/* Open the usage file with the caller's own permissions. */
+	uid_t euid = geteuid();
+	if (seteuid(getuid()) != 0) {
+		perror("jail-rusage: seteuid");
+		return 125;
+	}
+	int usage_fd = open(argv[1], O_WRONLY | O_CREAT | O_TRUNC | O_CLOEXEC, 0644);
+	if (seteuid(euid) != 0) {
+		perror("jail-rusage: seteuid");
+		return 125;
+	}
+
+	child = fork();
+	if (child < 0) {
+		perror("jail-rusage: fork");
+		return 125;
+	}
+	if (child == 0) {
+		if (sandbox && drop_to_sandbox() != 0) {
+			fprintf(stderr, "jail-rusage: cannot switch to the sandbox user: %s\n", strerror(errno));
+			_exit(126);
+		}
+		if (!sandbox && (setgid(getgid()) != 0 || setuid(getuid()) != 0)) {
+			_exit(126);
+		}
+		execvp(argv[3], &argv[3]);
+		fprintf(stderr, "jail-rusage: %s: %s\n", argv[3], strerror(errno));
+		_exit(errno == ENOENT ? 127 : 126);
+	}
+
+	struct sigaction sa;
+	memset(&sa, 0, sizeof(sa));
+	sa.sa_handler = on_alarm;
+	sigaction(SIGALRM, &sa, NULL);
+	sa.sa_handler = on_term;
+	sigaction(SIGTERM, &sa, NULL);
+	sigaction(SIGINT, &sa, NULL);
+
+	if (timeout_ms > 0) {
+		struct itimerval it;
+		memset(&it, 0, sizeof(it));
+		it.it_value.tv_sec = timeout_ms / 1000;
+		it.it_value.tv_usec = (timeout_ms % 1000) * 1000;
+		setitimer(ITIMER_REAL, &it, NULL);
+	}
+
+	int status;
+	struct rusage ru;
+	for (;;) {
+		pid_t done = wait4(child, &status, memory_mb > 0 ? WNOHANG : 0, &ru);
+		if (done == child) {
+			break;
+		}
+		if (done < 0 && errno != EINTR) {
+			perror("jail-rusage: wait4");
+			return 125;
+		}
+		if (done == 0) {
+			if (rss_kb(child) > memory_mb * 1024) {
+				kill(child, SIGKILL);
+			}
+			usleep(5000);
+		}
+	}
+
+	char exit_field[16] = "-";
+	const char *sig_field = "-";
+	int code;
+	if (WIFSIGNALED(status)) {
+		sig_field = signal_name(WTERMSIG(status));
+		code = 128 + WTERMSIG(status);
+	} else {
+		code = WEXITSTATUS(status);
+		snprintf(exit_field, sizeof(exit_field), "%d", code);
+	}
+	if (timed_out) {
+		code = 124;
+	}
+
+	FILE *f = usage_fd >= 0 ? fdopen(usage_fd, "w") : NULL;
+	if (f) {
+		fprintf(f, "%ld %ld %ld %s %s %d\n", ms(ru.ru_utime), ms(ru.ru_stime),
+			ru.ru_maxrss, exit_field, sig_field, timed_out ? 1 : 0);
+		fclose(f);
+	}
+	return code;
+}
@@ -5,56 +5,119 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"donfra-runner/internal/handler"
+	"donfra-runner/internal/metrics"
 	"donfra-runner/internal/runner"
+	"donfra-runner/internal/submission"
+	"donfra-runner/internal/tracing"
 )
 
 const version = "2.0.0"
 
 func main() {
+	// The sandbox jail re-executes this binary to build the namespaces
+	// before replacing itself with the user's program.
+	if len(os.Args) > 1 && os.Args[1] == runner.SandboxInitCommand {
+		runner.RunSandboxInit()
+		return
+	}
+
 	addr := envOrDefault("ADDR", ":8090")
 	jailMode := runner.JailMode(envOrDefault("JAIL_MODE", "direct"))
 	maxConcurrent := envIntOrDefault("MAX_CONCURRENT", 4)
+
+	// Executions beyond MAX_CONCURRENT wait in a queue of at most MAX_QUEUE,
+	// MAX_QUEUE_PER_CALLER of them from any one caller, for up to
+	// QUEUE_TIMEOUT_MS. More are rejected at once.
+	maxQueue := envIntOrDefault("MAX_QUEUE", 64)
+	maxQueuePerCaller := envIntOrDefault("MAX_QUEUE_PER_CALLER", 8)
+	queueTimeoutMs := envIntOrDefault("QUEUE_TIMEOUT_MS", 30000)
+
 	defaultTimeoutMs := envIntOrDefault("DEFAULT_TIMEOUT_MS", 5000)
 	maxTimeoutMs := envIntOrDefault("MAX_TIMEOUT_MS", 10000)
 	maxOutputBytes := envIntOrDefault("MAX_OUTPUT_BYTES", 65536)
+	maxTestCases := envIntOrDefault("MAX_TEST_CASES", 20)
+	maxBatchSize := envIntOrDefault("MAX_BATCH_SIZE", 50)
+	maxTraceSteps := envIntOrDefault("MAX_TRACE_STEPS", 1000)
+	maxProjectFiles := envIntOrDefault("MAX_PROJECT_FILES", 20)
+	maxProjectBytes := envIntOrDefault("MAX_PROJECT_BYTES", 512<<10)
 
-	limiter := runner.NewLimiter(maxConcurrent)
+	// Interactive sessions each hold an execution slot while they run, so
+	// MAX_SESSIONS defaults to half of them.
+	maxSessions := envIntOrDefault("MAX_SESSIONS", max(maxConcurrent/2, 1))
+	sessionIdleMs := envIntOrDefault("SESSION_IDLE_TIMEOUT_MS", 60000)
+	sessionMaxMs := envIntOrDefault("SESSION_MAX_MS", 600000)
+	sessionCPUMs := envIntOrDefault("SESSION_CPU_MS", 10000)
+	sessionMaxOutputBytes := envIntOrDefault("SESSION_MAX_OUTPUT_BYTES", 1<<20)
+
+	// Initialize tracing; disabled when JAEGER_ENDPOINT is not set.
+	shutdownTracing, err := tracing.InitTracer("donfra-runner", version, os.Getenv("JAEGER_ENDPOINT"))
+	if err != nil {
+		log.Fatalf("tracing init failed: %v", err)
+	}
+
+	limiter := runner.NewLimiter(maxConcurrent, maxQueue, maxQueuePerCaller)
+	metrics.RegisterSlots(limiter.Max, limiter.InUse, limiter.Queued)
 
 	cfg := runner.Config{
-		JailMode:    jailMode,
+		JailMode:       jailMode,
 		MaxTimeoutMs:   maxTimeoutMs,
 		DefaultTimeout: defaultTimeoutMs,
 		MaxOutputBytes: maxOutputBytes,
+		MaxTestCases:   maxTestCases,
+		MaxBatchSize:   maxBatchSize,
+		MaxTraceSteps:  maxTraceSteps,
+		QueueTimeoutMs: queueTimeoutMs,
+
+		MaxProjectFiles: maxProjectFiles,
+		MaxProjectBytes: maxProjectBytes,
+
+		MaxSessions:           maxSessions,
+		SessionIdleMs:         sessionIdleMs,
+		SessionMaxMs:          sessionMaxMs,
+		SessionCPUMs:          sessionCPUMs,
+		SessionMaxOutputBytes: sessionMaxOutputBytes,
 	}
 
-	// Initialize K8s executor when in k8s jail mode.
-	var k8sExecutor *runner.K8sExecutor
-	if jailMode == runner.JailK8sJob {
-		redisAddr := envOrDefault("REDIS_ADDR", "redis:6379")
-		jailImage := envOrDefault("JAIL_IMAGE", "doneowth/donfra-jail:1.0.0")
-		k8sNamespace := envOrDefault("K8S_NAMESPACE", "donfra-eng")
+	// bgCtx scopes background workers and is cancelled on shutdown.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
-		// Parse Redis host:port
-		redisHost := "redis"
-		redisPort := "6379"
-		if parts := strings.SplitN(redisAddr, ":", 2); len(parts) == 2 {
-			redisHost = parts[0]
-			redisPort = parts[1]
+	// The language catalog is built in unless LANGUAGES_FILE points at a
+	// YAML/JSON catalog, which is then reloaded whenever it changes
+	// (LANGUAGES_RELOAD_SECONDS=0 disables reloading).
+	if languagesFile := os.Getenv("LANGUAGES_FILE"); languagesFile != "" {
+		if err := runner.LoadLanguagesFile(languagesFile); err != nil {
+			log.Fatalf("languages load failed: %v", err)
+		}
+		if reloadSec := envIntOrDefault("LANGUAGES_RELOAD_SECONDS", 30); reloadSec > 0 {
+			go runner.WatchLanguagesFile(bgCtx, languagesFile, time.Duration(reloadSec)*time.Second)
 		}
-		_ = redisHost // used only for logging below
+		log.Printf("[runner] languages loaded from %s", languagesFile)
+	}
 
-		redisClient := redis.NewClient(&redis.Options{
+	// Redis is required in k8s mode for jail results and optional otherwise,
+	// where it lets submission results be shared between runner replicas.
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" && jailMode == runner.JailK8sJob {
+		redisAddr = "redis:6379"
+	}
+	var redisClient *redis.Client
+	if redisAddr != "" {
+		redisClient = redis.NewClient(&redis.Options{
 			Addr: redisAddr,
 		})
 
@@ -65,6 +128,13 @@ func main() {
 			log.Fatalf("redis connection failed (%s): %v", redisAddr, err)
 		}
 		log.Printf("[runner] redis connected: %s", redisAddr)
+	}
+
+	// Initialize K8s executor when in k8s jail mode.
+	var k8sExecutor *runner.K8sExecutor
+	if jailMode == runner.JailK8sJob {
+		jailImage := envOrDefault("JAIL_IMAGE", "doneowth/donfra-jail:1.0.0")
+		k8sNamespace := envOrDefault("K8S_NAMESPACE", "donfra-eng")
 
 		// Initialize K8s in-cluster client.
 		k8sCfg, err := rest.InClusterConfig()
@@ -77,22 +147,155 @@ func main() {
 		}
 		log.Printf("[runner] k8s client initialized (namespace: %s)", k8sNamespace)
 
-		k8sExecutor = runner.NewK8sExecutor(kubeClient, redisClient, k8sNamespace, jailImage, cfg)
-		log.Printf("[runner] jail image: %s, redis: %s:%s", jailImage, redisHost, redisPort)
+		// The signing secret derives the keys that authenticate warm pool jobs.
+		// Replicas sharing a pool must share it.
+		signingSecret := os.Getenv("JAIL_SIGNING_SECRET")
+		if signingSecret == "" {
+			log.Printf("[runner] JAIL_SIGNING_SECRET not set, using a random per-process secret")
+			signingSecret = uuid.New().String()
+		}
+
+		// Jail pods reach the runner through Redis, as users limited to their
+		// own keys. JAIL_REDIS_ADDR gives them a server of their own, whose
+		// default user needs JAIL_REDIS_PASSWORD, so they cannot reach the one
+		// at REDIS_ADDR; without it they share that one.
+		jailRedisAddr := envOrDefault("JAIL_REDIS_ADDR", redisAddr)
+		jailRedis := redisClient
+		if jailRedisAddr != redisAddr {
+			jailRedis = redis.NewClient(&redis.Options{
+				Addr:     jailRedisAddr,
+				Password: os.Getenv("JAIL_REDIS_PASSWORD"),
+			})
+			pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer pingCancel()
+			if err := jailRedis.Ping(pingCtx).Err(); err != nil {
+				log.Fatalf("jail redis connection failed (%s): %v", jailRedisAddr, err)
+			}
+		}
+
+		k8sExecutor = runner.NewK8sExecutor(kubeClient, jailRedis, k8sNamespace, jailImage, signingSecret, cfg)
+		log.Printf("[runner] jail image: %s, redis: %s", jailImage, jailRedisAddr)
+
+		// Optional warm pod pool, e.g. POOL_SIZES="71:2,63:2".
+		if sizes := parsePoolSizes(os.Getenv("POOL_SIZES")); len(sizes) > 0 {
+			k8sExecutor.StartPool(bgCtx, runner.PoolConfig{
+				Sizes:                sizes,
+				TrustedMaxExecutions: envIntOrDefault("POOL_TRUSTED_MAX_EXECUTIONS", 20),
+				IdleTimeoutSec:       envIntOrDefault("POOL_IDLE_TIMEOUT_SEC", 600),
+			})
+		}
+
+		// Remove Jobs and pool pods left behind by earlier runner instances.
+		k8sExecutor.StartGC(bgCtx, time.Duration(envIntOrDefault("JAIL_GC_INTERVAL_SECONDS", 300))*time.Second)
 	}
 
-	r := runner.New(cfg, limiter, k8sExecutor)
-	h := handler.New(r, limiter, version)
+	// Initialize the namespace/cgroup sandbox when in sandbox jail mode.
+	var sandbox *runner.Sandbox
+	if jailMode == runner.JailSandbox {
+		sandboxCfg := runner.SandboxConfig{
+			CgroupRoot: envOrDefault("SANDBOX_CGROUP_ROOT", "/sys/fs/cgroup/donfra-runner"),
+			UID:        envIntOrDefault("SANDBOX_UID", 65534),
+			GID:        envIntOrDefault("SANDBOX_GID", 65534),
+			PidsMax:    envIntOrDefault("SANDBOX_PIDS_MAX", 64),
+			CPUMillis:  envIntOrDefault("SANDBOX_CPU_MILLIS", 1000),
+			TmpfsMB:    envIntOrDefault("SANDBOX_TMPFS_MB", 64),
+			FileSizeMB: envIntOrDefault("SANDBOX_FILE_SIZE_MB", 16),
+		}
+		if paths := os.Getenv("SANDBOX_READONLY_PATHS"); paths != "" {
+			sandboxCfg.ReadOnlyPaths = strings.Split(paths, ",")
+		}
+
+		var err error
+		sandbox, err = runner.NewSandbox(sandboxCfg)
+		if err != nil {
+			log.Fatalf("sandbox init failed: %v", err)
+		}
+		log.Printf("[runner] sandbox initialized (cgroup: %s)", sandboxCfg.CgroupRoot)
+	}
+
+	// Requests that set "cache" reuse results of identical earlier runs for
+	// RESULT_CACHE_TTL_SECONDS; caching needs Redis and is off by default.
+	var cache *runner.ResultCache
+	if cacheTTL := envIntOrDefault("RESULT_CACHE_TTL_SECONDS", 0); cacheTTL > 0 && redisClient != nil {
+		cache = runner.NewResultCache(redisClient, time.Duration(cacheTTL)*time.Second)
+		log.Printf("[runner] result cache enabled (ttl: %ds)", cacheTTL)
+	}
+
+	var executor runner.Executor
+	switch jailMode {
+	case runner.JailK8sJob:
+		executor = k8sExecutor
+	case runner.JailFake:
+		// FAKE_SCRIPT_FILE scripts the results; without it every run echoes its stdin.
+		var script runner.FakeScript
+		if scriptFile := os.Getenv("FAKE_SCRIPT_FILE"); scriptFile != "" {
+			script, err = runner.LoadFakeScript(scriptFile)
+			if err != nil {
+				log.Fatalf("fake script load failed: %v", err)
+			}
+		}
+		executor = runner.NewFakeExecutor(script)
+		log.Printf("[runner] fake executor: %d rules, no code will run", len(script.Rules))
+	default:
+		// Bare runs measure peak memory and enforce the memory limit through
+		// jail-rusage (built from donfra-jail) when it is installed.
+		var rusage string
+		if sandbox == nil {
+			if path, err := exec.LookPath(envOrDefault("RUSAGE_PATH", "jail-rusage")); err == nil {
+				rusage = path
+				log.Printf("[runner] bare runs measured by %s", path)
+			} else {
+				log.Printf("[runner] jail-rusage not found: bare runs report no peak memory")
+			}
+		}
+		executor = runner.NewDirectExecutor(cfg, sandbox, rusage)
+	}
+
+	r := runner.New(cfg, limiter, executor, cache)
+
+	// Submission results live in Redis when available, otherwise in memory.
+	submissionTTL := time.Duration(envIntOrDefault("SUBMISSION_TTL_SECONDS", 3600)) * time.Second
+	var store submission.Store
+	if redisClient != nil {
+		store = submission.NewRedisStore(redisClient, submissionTTL)
+	} else {
+		store = submission.NewMemoryStore(submissionTTL)
+	}
+	submissions := submission.NewService(r, store)
+
+	h := handler.New(r, limiter, submissions, version)
+
+	// Execution endpoints require the shared API token; /languages, /health
+	// and /metrics stay open. Running without one takes an explicit opt-in,
+	// meant for local development only.
+	apiToken := os.Getenv("RUNNER_API_TOKEN")
+	auth := handler.RequireToken(apiToken)
+	if apiToken == "" {
+		if os.Getenv("RUNNER_INSECURE_NO_AUTH") != "true" {
+			log.Fatalf("RUNNER_API_TOKEN not set (set RUNNER_INSECURE_NO_AUTH=true to run without authentication)")
+		}
+		log.Printf("[runner] RUNNER_INSECURE_NO_AUTH set, execution endpoints are unauthenticated")
+		auth = func(next http.HandlerFunc) http.HandlerFunc { return next }
+	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/execute", h.Execute)
+	mux.HandleFunc("/execute", auth(h.Accepting(h.Execute)))
+	mux.HandleFunc("/execute/stream", auth(h.Accepting(h.ExecuteStream)))
+	mux.HandleFunc("/execute/batch", auth(h.Accepting(h.ExecuteBatch)))
+	mux.HandleFunc("GET /execute/session", auth(h.Accepting(h.ExecuteSession)))
+	mux.HandleFunc("POST /submissions", auth(h.Accepting(h.CreateSubmission)))
+	mux.HandleFunc("GET /submissions/{token}", auth(h.GetSubmission))
+	mux.HandleFunc("GET /languages", h.Languages)
 	mux.HandleFunc("/health", h.Health)
+	mux.Handle("/metrics", promhttp.Handler())
 
+	// WriteTimeout is generous because judge and batch requests run many
+	// executions within a single response.
 	srv := &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      tracing.Middleware(mux),
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: 5 * time.Minute,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -110,6 +313,18 @@ func main() {
 
 	<-done
 	log.Println("shutting down...")
+	stopBackground()
+
+	// Drain first: new executions get 503 (and /health turns 503 so the
+	// instance leaves the Service) while in-flight ones finish. Whatever is
+	// still running at the deadline is cancelled and its Jobs deleted.
+	drainTimeout := time.Duration(envIntOrDefault("DRAIN_TIMEOUT_SECONDS", 30)) * time.Second
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer drainCancel()
+	log.Printf("draining %d in-flight executions (timeout %s)", r.InFlight(), drainTimeout)
+	if err := r.Drain(drainCtx); err != nil {
+		log.Printf("drain timed out, remaining executions were cancelled")
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -118,9 +333,32 @@ func main() {
 		log.Fatalf("shutdown error: %v", err)
 	}
 
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Printf("tracing shutdown error: %v", err)
+	}
+
 	log.Println("shutdown complete")
 }
 
+// parsePoolSizes parses "languageID:size" pairs separated by commas.
+func parsePoolSizes(s string) map[int]int {
+	sizes := make(map[int]int)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		id, size, ok := strings.Cut(pair, ":")
+		langID, err1 := strconv.Atoi(id)
+		n, err2 := strconv.Atoi(size)
+		if !ok || err1 != nil || err2 != nil {
+			log.Printf("[runner] ignoring invalid POOL_SIZES entry %q", pair)
+			continue
+		}
+		sizes[langID] = n
+	}
+	return sizes
+}
+
 func envOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
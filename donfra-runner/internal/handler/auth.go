@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// RequireToken returns a wrapper that rejects requests not carrying
+// "Authorization: Bearer <token>". An empty token rejects every request.
+func RequireToken(token string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -2,61 +2,270 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
+	"time"
 
 	"donfra-runner/internal/runner"
+	"donfra-runner/internal/submission"
 )
 
 type Handler struct {
-	runner  *runner.Runner
-	limiter *runner.Limiter
-	version string
+	runner      *runner.Runner
+	limiter     *runner.Limiter
+	submissions *submission.Service
+	version     string
 }
 
-func New(r *runner.Runner, l *runner.Limiter, version string) *Handler {
-	return &Handler{runner: r, limiter: l, version: version}
+func New(r *runner.Runner, l *runner.Limiter, subs *submission.Service, version string) *Handler {
+	return &Handler{runner: r, limiter: l, submissions: subs, version: version}
 }
 
 func (h *Handler) Execute(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeExecuteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result := h.runner.Execute(r.Context(), req)
+
+	log.Printf("execute lang=%d status=%d duration=%dms stdout_len=%d stderr_len=%d cases=%d",
+		req.LanguageID, result.Status.ID, result.ExecutionTimeMs,
+		len(result.Stdout), len(result.Stderr), len(req.TestCases))
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// ExecuteStream runs code and streams output as Server-Sent Events: "queue"
+// events carry the QueueStatus while the execution waits for a slot, "stdout"
+// and "stderr" events carry OutputChunks, and a final "result" event carries
+// the ExecuteResult.
+func (h *Handler) ExecuteStream(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeExecuteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if len(req.TestCases) > 0 {
+		writeError(w, http.StatusBadRequest, "test_cases are not supported for streaming execution")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// stdout and stderr are delivered from separate goroutines.
+	var mu sync.Mutex
+	ctx := runner.WithQueueReporter(r.Context(), queueEvents(func(status runner.QueueStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		writeEvent(w, "queue", status)
+		flusher.Flush()
+	}))
+	result := h.runner.ExecuteStream(ctx, req, func(chunk runner.OutputChunk) {
+		mu.Lock()
+		defer mu.Unlock()
+		writeEvent(w, chunk.Stream, chunk)
+		flusher.Flush()
+	})
+
+	log.Printf("execute stream lang=%d status=%d duration=%dms stdout_len=%d stderr_len=%d",
+		req.LanguageID, result.Status.ID, result.ExecutionTimeMs,
+		len(result.Stdout), len(result.Stderr))
+
+	mu.Lock()
+	defer mu.Unlock()
+	writeEvent(w, "result", result)
+	flusher.Flush()
+}
+
+type batchRequest struct {
+	Items []runner.ExecuteRequest `json:"items"`
+}
+
+// ExecuteBatch runs many submissions and responds with their results in
+// request order. Invalid items get an error result rather than failing the batch.
+func (h *Handler) ExecuteBatch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
 
-	var req runner.ExecuteRequest
-	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<20)).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
+	if err := h.runner.ValidateBatch(req.Items); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	start := time.Now()
+	results := h.runner.ExecuteBatch(r.Context(), req.Items)
+
+	log.Printf("execute batch items=%d duration=%dms", len(req.Items), time.Since(start).Milliseconds())
+
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+// CreateSubmission queues code for asynchronous execution and responds with
+// its token. With ?wait=true the response is held until the result is ready.
+func (h *Handler) CreateSubmission(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeExecuteRequest(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := h.submissions.Submit(r.Context(), req)
+	if err != nil {
+		log.Printf("submission create failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to create submission")
+		return
+	}
+
+	if r.URL.Query().Get("wait") != "true" {
+		writeJSON(w, http.StatusCreated, map[string]string{"token": token})
+		return
+	}
+
+	result, err := h.submissions.Wait(r.Context(), token, req.CallerID)
+	if err != nil {
+		log.Printf("submission %s wait failed: %v", token, err)
+		writeError(w, http.StatusInternalServerError, "failed to read submission")
+		return
+	}
+	writeJSON(w, http.StatusCreated, result)
+}
+
+// GetSubmission returns the status, and once finished the result, of a
+// submission. Its caller_id query parameter must name the caller that made
+// the submission; other callers get 404 as for an unknown token.
+func (h *Handler) GetSubmission(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	result, err := h.submissions.Get(r.Context(), token, r.URL.Query().Get("caller_id"))
+	if errors.Is(err, submission.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "submission not found")
+		return
+	}
+	if err != nil {
+		log.Printf("submission %s get failed: %v", token, err)
+		writeError(w, http.StatusInternalServerError, "failed to read submission")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// decodeExecuteRequest parses and validates an execute request body, writing
+// a 4xx response and returning false when it is invalid.
+func (h *Handler) decodeExecuteRequest(w http.ResponseWriter, r *http.Request) (runner.ExecuteRequest, bool) {
+	var req runner.ExecuteRequest
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return req, false
+	}
+
+	// Test inputs can run to several megabytes.
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return req, false
+	}
+
 	if req.SourceCode == "" {
 		writeError(w, http.StatusBadRequest, "source_code is required")
-		return
+		return req, false
 	}
 
 	if req.LanguageID == 0 {
 		writeError(w, http.StatusBadRequest, "language_id is required")
-		return
+		return req, false
 	}
 
 	if _, err := runner.GetLanguage(req.LanguageID); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
-		return
+		return req, false
 	}
 
-	result := h.runner.Execute(r.Context(), req)
+	if err := h.runner.ValidateTestCases(req.TestCases); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return req, false
+	}
 
-	log.Printf("execute lang=%d status=%d duration=%dms stdout_len=%d stderr_len=%d",
-		req.LanguageID, result.Status.ID, result.ExecutionTimeMs,
-		len(result.Stdout), len(result.Stderr))
+	if err := h.runner.ValidateChecker(req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return req, false
+	}
 
-	writeJSON(w, http.StatusOK, result)
+	if err := h.runner.ValidateProject(req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return req, false
+	}
+
+	if err := runner.ValidatePriority(req.Priority); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return req, false
+	}
+
+	return req, true
 }
 
+// queueEvents wraps a queue reporter for clients: an execution that started
+// without waiting reports nothing, and one that waited reports its position
+// until it starts, ending with position 0.
+func queueEvents(send func(runner.QueueStatus)) func(runner.QueueStatus) {
+	waited := false
+	return func(status runner.QueueStatus) {
+		if status.Position > 0 {
+			waited = true
+		}
+		if waited {
+			send(status)
+		}
+	}
+}
+
+// Languages lists the languages the runner accepts.
+func (h *Handler) Languages(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"languages": h.runner.Languages()})
+}
+
+// Accepting wraps endpoints that start executions so they are rejected with
+// 503 once the runner is draining for shutdown.
+func (h *Handler) Accepting(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.runner.Draining() {
+			w.Header().Set("Retry-After", "5")
+			writeError(w, http.StatusServiceUnavailable, "runner is shutting down")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Health reports 503 while draining so the instance is taken out of rotation.
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]any{
-		"status":    "ok",
+	code, status := http.StatusOK, "ok"
+	if h.runner.Draining() {
+		code, status = http.StatusServiceUnavailable, "draining"
+	}
+	writeJSON(w, code, map[string]any{
+		"status":    status,
+		"in_flight": h.runner.InFlight(),
 		"languages": runner.SupportedLanguageIDs(),
 		"version":   h.version,
 		"slots": map[string]int{
@@ -73,6 +282,12 @@ func writeJSON(w http.ResponseWriter, code int, v any) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// writeEvent writes one Server-Sent Event with a JSON payload.
+func writeEvent(w http.ResponseWriter, event string, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
 func writeError(w http.ResponseWriter, code int, msg string) {
 	writeJSON(w, code, map[string]string{"error": msg})
 }
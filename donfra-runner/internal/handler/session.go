@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"donfra-runner/internal/runner"
+)
+
+const (
+	// sessionStartTimeout is how long a client has to send its start message.
+	sessionStartTimeout = 10 * time.Second
+	// sessionWriteTimeout bounds each write to the client.
+	sessionWriteTimeout = 10 * time.Second
+	// sessionPingInterval keeps proxies from closing a quiet connection.
+	sessionPingInterval = 30 * time.Second
+	// maxSessionMessageBytes caps client messages; stdin arrives as it is typed.
+	maxSessionMessageBytes = 1 << 20
+)
+
+var sessionUpgrader = websocket.Upgrader{}
+
+// sessionClientMessage is a message from the client: "start" with the
+// SessionRequest fields, then any number of "stdin" with Data and an
+// optional "eof" that closes the program's stdin.
+type sessionClientMessage struct {
+	Type string `json:"type"`
+	runner.SessionRequest
+	Data string `json:"data,omitempty"`
+}
+
+// sessionServerMessage is a message to the client: "queue" with Queue while
+// the session waits for a slot, "stdout" and "stderr" chunks while the
+// program runs, then one "result".
+type sessionServerMessage struct {
+	Type      string                `json:"type"`
+	Data      string                `json:"data,omitempty"`
+	ElapsedMs int64                 `json:"elapsed_ms,omitempty"`
+	Queue     *runner.QueueStatus   `json:"queue,omitempty"`
+	Result    *runner.ExecuteResult `json:"result,omitempty"`
+}
+
+// ExecuteSession runs an interactive session over a WebSocket. The client
+// starts it, then sends stdin as the program needs it; output is relayed as
+// it is written. The connection is closed after the result, and closing it
+// early kills the program.
+func (h *Handler) ExecuteSession(w http.ResponseWriter, r *http.Request) {
+	conn, err := sessionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error.
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxSessionMessageBytes)
+
+	var start sessionClientMessage
+	conn.SetReadDeadline(time.Now().Add(sessionStartTimeout))
+	if err := conn.ReadJSON(&start); err != nil || start.Type != "start" {
+		closeSession(conn, websocket.ClosePolicyViolation, "expected a start message")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Writes come from the stdout and stderr goroutines and the final result.
+	var mu sync.Mutex
+	send := func(msg sessionServerMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(sessionWriteTimeout))
+		if err := conn.WriteJSON(msg); err != nil {
+			cancel()
+		}
+	}
+
+	// The client going away ends the session.
+	input := make(chan []byte)
+	go func() {
+		defer cancel()
+		eof := false
+		for {
+			var msg sessionClientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			switch {
+			case msg.Type == "stdin" && !eof && msg.Data != "":
+				select {
+				case input <- []byte(msg.Data):
+				case <-ctx.Done():
+					return
+				}
+			case msg.Type == "eof" && !eof:
+				eof = true
+				close(input)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(sessionPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(sessionWriteTimeout))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ctx = runner.WithQueueReporter(ctx, queueEvents(func(status runner.QueueStatus) {
+		send(sessionServerMessage{Type: "queue", Queue: &status})
+	}))
+	result := h.runner.Session(ctx, start.SessionRequest, input, func(chunk runner.OutputChunk) {
+		send(sessionServerMessage{Type: chunk.Stream, Data: chunk.Data, ElapsedMs: chunk.ElapsedMs})
+	})
+
+	log.Printf("execute session lang=%d repl=%t status=%d duration=%dms",
+		start.LanguageID, start.REPL, result.Status.ID, result.ExecutionTimeMs)
+
+	send(sessionServerMessage{Type: "result", Result: &result})
+	closeSession(conn, websocket.CloseNormalClosure, "")
+}
+
+// closeSession sends a close frame; the caller closes the connection.
+func closeSession(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(sessionWriteTimeout))
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// Execution metrics
+	ExecutionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "donfra_runner_executions_total",
+			Help: "Total number of executions by language and final status",
+		},
+		[]string{"language", "status"},
+	)
+
+	ExecutionDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "donfra_runner_execution_duration_seconds",
+			Help:    "Wall time of an execution after it got a slot, including compilation and Job startup",
+			Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60},
+		},
+		[]string{"language"},
+	)
+
+	CacheLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "donfra_runner_cache_lookups_total",
+			Help: "Total number of result cache lookups by outcome",
+		},
+		[]string{"result"}, // hit, miss
+	)
+
+	SessionsActive = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "donfra_runner_sessions_active",
+			Help: "Number of interactive sessions currently running",
+		},
+	)
+
+	QueueWait = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "donfra_runner_queue_wait_seconds",
+			Help:    "Time spent waiting for an execution slot",
+			Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+	)
+
+	// K8s jail metrics
+	K8sJobCreateDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "donfra_runner_k8s_job_create_duration_seconds",
+			Help:    "Latency of the Kubernetes API call creating a jail Job",
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+	)
+
+	K8sJobTimeoutsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "donfra_runner_k8s_job_timeouts_total",
+			Help: "Total number of jail Jobs that produced no result in time",
+		},
+		[]string{"reason"}, // oom, timeout
+	)
+
+	JailMessagesRejectedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "donfra_runner_jail_messages_rejected_total",
+			Help: "Total number of result channel messages dropped for a missing or invalid signature",
+		},
+	)
+
+	PoolDispatchTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "donfra_runner_pool_dispatch_total",
+			Help: "Total number of K8s executions by warm pool outcome",
+		},
+		[]string{"result"}, // warm, fallback
+	)
+)
+
+// RegisterSlots exposes execution slot usage as gauges read at scrape time.
+func RegisterSlots(max, inUse, queued func() int) {
+	gauge := func(name, help string, f func() int) {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+			return float64(f())
+		})
+	}
+	gauge("donfra_runner_slots_max", "Maximum number of concurrent executions", max)
+	gauge("donfra_runner_slots_in_use", "Number of execution slots currently in use", inUse)
+	gauge("donfra_runner_slots_queued", "Number of executions waiting for a slot", queued)
+}
+
+// RecordExecution records a finished execution
+func RecordExecution(language, status string, seconds float64) {
+	ExecutionsTotal.WithLabelValues(language, status).Inc()
+	ExecutionDuration.WithLabelValues(language).Observe(seconds)
+}
+
+// RecordK8sJobTimeout records a jail Job that timed out, and whether it was OOM-killed
+func RecordK8sJobTimeout(oomKilled bool) {
+	reason := "timeout"
+	if oomKilled {
+		reason = "oom"
+	}
+	K8sJobTimeoutsTotal.WithLabelValues(reason).Inc()
+}
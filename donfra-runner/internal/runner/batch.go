@@ -0,0 +1,175 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"donfra-runner/internal/metrics"
+	"donfra-runner/internal/tracing"
+)
+
+// batchGroup is a set of batch items that share source, project files, language, timeout and trust,
+// so they can be compiled once and run in the same Job.
+type batchGroup struct {
+	lang      Language
+	req       ExecuteRequest
+	timeoutMs int
+	indexes   []int
+}
+
+type batchKey struct {
+	languageID int
+	source     string
+	project    string // see projectKey
+	timeoutMs  int
+	trusted    bool
+	caller     Caller
+}
+
+// ValidateBatch checks the size of a batch request. Problems with individual
+// items are reported in their results instead.
+func (r *Runner) ValidateBatch(items []ExecuteRequest) error {
+	if len(items) == 0 {
+		return errors.New("items is required")
+	}
+	if r.cfg.MaxBatchSize > 0 && len(items) > r.cfg.MaxBatchSize {
+		return fmt.Errorf("too many items: %d (max %d)", len(items), r.cfg.MaxBatchSize)
+	}
+	return nil
+}
+
+// ExecuteBatch runs many submissions and returns their results in order.
+// Items with identical source, language, timeout and trust are grouped: each group
+// takes one execution slot, compiles once and runs every stdin in turn (in
+// K8s mode, inside a single Job). Groups run in parallel up to the caller's
+// share of the queue (see Limiter.MaxPerCaller).
+func (r *Runner) ExecuteBatch(ctx context.Context, items []ExecuteRequest) []ExecuteResult {
+	results := make([]ExecuteResult, len(items))
+	if err := r.ValidateBatch(items); err != nil {
+		for i := range results {
+			results[i] = errorResult(err.Error())
+		}
+		return results
+	}
+
+	ctx, done, ok := r.drain.begin(ctx)
+	if !ok {
+		for i := range results {
+			results[i] = drainingResult()
+		}
+		return results
+	}
+	defer done()
+
+	ctx, span := tracing.StartSpan(ctx, "runner.batch", tracing.AttrBatchItems.Int(len(items)))
+	defer span.End()
+
+	groups := make(map[batchKey]*batchGroup)
+	var order []*batchGroup
+	for i, item := range items {
+		lang, err := r.validateBatchItem(item)
+		if err != nil {
+			results[i] = errorResult(err.Error())
+			continue
+		}
+
+		timeout := r.resolveTimeout(lang, item.TimeoutMs)
+		key := batchKey{
+			languageID: item.LanguageID,
+			source:     item.SourceCode,
+			project:    projectKey(item),
+			timeoutMs:  timeout,
+			trusted:    item.Trusted,
+			caller:     Caller{ID: item.CallerID, Priority: item.Priority},
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &batchGroup{lang: lang.withEntrypoint(item.Entrypoint), req: item, timeoutMs: timeout}
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.indexes = append(g.indexes, i)
+	}
+
+	// Bound how many of this batch's groups wait on the limiter at once, so a
+	// large batch queues behind itself rather than timing out or filling its
+	// caller's share of the queue.
+	sem := make(chan struct{}, r.limiter.MaxPerCaller())
+	var wg sync.WaitGroup
+	for _, g := range order {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.runBatchGroup(ctx, g, items, results)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (r *Runner) validateBatchItem(item ExecuteRequest) (Language, error) {
+	if item.SourceCode == "" {
+		return Language{}, errors.New("source_code is required")
+	}
+	if len(item.TestCases) > 0 || item.Checker != nil {
+		return Language{}, errors.New("test_cases are not supported in batch items")
+	}
+	if item.Mode != ModeRun {
+		return Language{}, errors.New("mode is not supported in batch items")
+	}
+	lang, err := GetLanguage(item.LanguageID)
+	if err != nil {
+		return Language{}, err
+	}
+	if lang.Database != "" {
+		return Language{}, errors.New("SQL is not supported in batch items")
+	}
+	if err := ValidatePriority(item.Priority); err != nil {
+		return Language{}, err
+	}
+	return lang, r.ValidateProject(item)
+}
+
+// runBatchGroup executes every item of g and stores the results by index.
+// Each index belongs to exactly one group, so writes to results do not race.
+func (r *Runner) runBatchGroup(ctx context.Context, g *batchGroup, items []ExecuteRequest, results []ExecuteResult) {
+	if err := r.acquire(ctx, Caller{ID: g.req.CallerID, Priority: g.req.Priority}); err != nil {
+		for _, i := range g.indexes {
+			results[i] = queueFullResult(err)
+			metrics.ExecutionsTotal.WithLabelValues(g.lang.Name, results[i].Status.Description).Inc()
+		}
+		return
+	}
+	defer r.limiter.Release()
+
+	ctx, span := tracing.StartSpan(ctx, "runner.batch_group",
+		tracing.AttrLanguage.String(g.lang.Name),
+		tracing.AttrBatchItems.Int(len(g.indexes)),
+	)
+	defer span.End()
+
+	// Each item is recorded with an equal share of the group's wall time,
+	// since compilation and Job startup are shared.
+	start := time.Now()
+	defer func() {
+		share := time.Since(start).Seconds() / float64(len(g.indexes))
+		for _, i := range g.indexes {
+			metrics.RecordExecution(g.lang.Name, results[i].Status.Description, share)
+		}
+	}()
+
+	stdins := make([]string, len(g.indexes))
+	for j, i := range g.indexes {
+		stdins[j] = items[i].Stdin
+	}
+
+	for j, res := range r.runEach(ctx, g.lang, g.req, stdins, g.timeoutMs, false) {
+		results[g.indexes[j]] = res
+	}
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"donfra-runner/internal/metrics"
+)
+
+// cacheTimeout bounds each cache lookup or store so a slow Redis never holds
+// up an execution.
+const cacheTimeout = 500 * time.Millisecond
+
+// ResultCache stores results of deterministic executions in Redis, keyed by
+// everything that can influence them. Requests opt in with Cache.
+type ResultCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewResultCache(client *redis.Client, ttl time.Duration) *ResultCache {
+	return &ResultCache{client: client, ttl: ttl}
+}
+
+// cacheKey hashes the request together with the resolved language definition
+// (commands, version, limits, image), the run timeout and the output limit.
+func (r *Runner) cacheKey(lang Language, req ExecuteRequest, timeoutMs int) string {
+	data, _ := json.Marshal(struct {
+		Lang           Language        `json:"lang"`
+		SourceCode     string          `json:"source_code"`
+		Files          []ProjectFile   `json:"files"`
+		Entrypoint     string          `json:"entrypoint"`
+		Stdin          string          `json:"stdin"`
+		TestCases      []TestCase      `json:"test_cases"`
+		Checker        *Checker        `json:"checker"`
+		Mode           ExecuteMode     `json:"mode"`
+		Generator      *Generator      `json:"generator"`
+		TestCode       string          `json:"test_code"`
+		Schema         string          `json:"schema"`
+		ExpectedResult *ExpectedResult `json:"expected_result"`
+		TimeoutMs      int             `json:"timeout_ms"`
+		MaxOutputBytes int             `json:"max_output_bytes"`
+		JailMode       JailMode        `json:"jail_mode"`
+	}{lang, req.SourceCode, req.Files, req.Entrypoint, req.Stdin, req.TestCases, req.Checker, req.Mode, req.Generator, req.TestCode, req.Schema, req.ExpectedResult, timeoutMs, r.cfg.MaxOutputBytes, r.cfg.JailMode})
+	sum := sha256.Sum256(data)
+	return "runner:cache:" + hex.EncodeToString(sum[:])
+}
+
+// get returns the cached result for key, marked as cached.
+func (c *ResultCache) get(ctx context.Context, key string) (ExecuteResult, bool) {
+	ctx, cancel := context.WithTimeout(ctx, cacheTimeout)
+	defer cancel()
+
+	var result ExecuteResult
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("[cache] lookup failed: %v", err)
+		}
+		metrics.CacheLookupsTotal.WithLabelValues("miss").Inc()
+		return result, false
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		log.Printf("[cache] corrupt entry %s: %v", key, err)
+		metrics.CacheLookupsTotal.WithLabelValues("miss").Inc()
+		return result, false
+	}
+	metrics.CacheLookupsTotal.WithLabelValues("hit").Inc()
+	result.Cached = true
+	return result, true
+}
+
+// put stores result under key when it is a property of the code and input
+// rather than of the moment it ran.
+func (c *ResultCache) put(ctx context.Context, key string, result ExecuteResult) {
+	if !cacheable(result) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	if err := c.client.Set(ctx, key, data, c.ttl).Err(); err != nil {
+		log.Printf("[cache] store failed: %v", err)
+	}
+}
+
+// cacheable reports whether result may be served again for the same request.
+// Timeouts, memory kills, queue-full, shutdown and infrastructure errors
+// depend on load and are never cached; runtime errors only when the program
+// itself exited or crashed.
+func cacheable(result ExecuteResult) bool {
+	if result.Complexity != nil {
+		return false // timings depend on load
+	}
+	switch result.Status.ID {
+	case StatusAccepted, StatusWrongAnswer:
+		return true
+	case StatusCompilationError:
+		// Compiler timeouts and memory kills depend on load like run ones.
+		return !strings.Contains(result.Message, "timed out") && !strings.Contains(result.Message, "memory")
+	case StatusRuntimeError:
+		return result.Status.Description == "Runtime Error" &&
+			(result.ExitCode != nil || (result.Signal != "" && result.Signal != "SIGKILL"))
+	default:
+		return false
+	}
+}
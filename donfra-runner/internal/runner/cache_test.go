@@ -0,0 +1,237 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestCacheable(t *testing.T) {
+	exit := func(code int) *int { return &code }
+	status := func(id int, desc string) ExecuteStatus { return ExecuteStatus{ID: id, Description: desc} }
+	runtimeError := status(StatusRuntimeError, "Runtime Error")
+
+	tests := []struct {
+		name   string
+		result ExecuteResult
+		want   bool
+	}{
+		{"accepted", ExecuteResult{Status: status(StatusAccepted, "Accepted")}, true},
+		{"wrong answer", ExecuteResult{Status: status(StatusWrongAnswer, "Wrong Answer")}, true},
+		{"complexity report", ExecuteResult{Status: status(StatusAccepted, "Accepted"), Complexity: &ComplexityReport{}}, false},
+		{"compilation error", ExecuteResult{Status: status(StatusCompilationError, "Compilation Error"), Message: "compiler exited with code 1"}, true},
+		{"compilation timeout", ExecuteResult{Status: status(StatusCompilationError, "Compilation Error"), Message: "compilation timed out"}, false},
+		{"compiler out of memory", ExecuteResult{Status: status(StatusCompilationError, "Compilation Error"), Message: "compiler ran out of memory"}, false},
+		{"program exited with an error", ExecuteResult{Status: runtimeError, Usage: Usage{ExitCode: exit(1)}}, true},
+		{"program crashed", ExecuteResult{Status: runtimeError, Usage: Usage{Signal: "SIGSEGV"}}, true},
+		{"program killed", ExecuteResult{Status: runtimeError, Usage: Usage{Signal: "SIGKILL"}}, false},
+		{"runtime error without exit status", ExecuteResult{Status: runtimeError, Message: "execution backend unavailable"}, false},
+		{"queue full", ExecuteResult{Status: status(StatusRuntimeError, "Queue Full")}, false},
+		{"time limit", ExecuteResult{Status: status(StatusTimeLimitExceeded, "Time Limit Exceeded")}, false},
+		{"internal error", ExecuteResult{Status: status(StatusInternalError, "Internal Error")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheable(tt.result); got != tt.want {
+				t.Errorf("cacheable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	r := &Runner{cfg: Config{MaxOutputBytes: 1024, JailMode: JailDirect}}
+	lang, err := GetLanguage(71)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := ExecuteRequest{SourceCode: "print(input())", LanguageID: 71, Stdin: "1"}
+	key := r.cacheKey(lang, base, 1000)
+
+	if again := r.cacheKey(lang, base, 1000); again != key {
+		t.Fatalf("same request gave keys %s and %s", key, again)
+	}
+
+	differs := []struct {
+		name   string
+		r      *Runner
+		lang   func(Language) Language
+		req    func(ExecuteRequest) ExecuteRequest
+		timeMs int
+	}{
+		{name: "source", req: func(q ExecuteRequest) ExecuteRequest { q.SourceCode += " "; return q }},
+		{name: "stdin", req: func(q ExecuteRequest) ExecuteRequest { q.Stdin = "2"; return q }},
+		{name: "files", req: func(q ExecuteRequest) ExecuteRequest { q.Files = []ProjectFile{{Path: "a.py"}}; return q }},
+		{name: "entrypoint", req: func(q ExecuteRequest) ExecuteRequest { q.Entrypoint = "app/main.py"; return q }},
+		{name: "test cases", req: func(q ExecuteRequest) ExecuteRequest { q.TestCases = []TestCase{{Input: "1"}}; return q }},
+		{name: "checker", req: func(q ExecuteRequest) ExecuteRequest { q.Checker = &Checker{SourceCode: "x", LanguageID: 71}; return q }},
+		{name: "mode", req: func(q ExecuteRequest) ExecuteRequest { q.Mode = ModeVisualize; return q }},
+		{name: "test code", req: func(q ExecuteRequest) ExecuteRequest { q.TestCode = "assert True"; return q }},
+		{name: "schema", req: func(q ExecuteRequest) ExecuteRequest { q.Schema = "create table t (x);"; return q }},
+		{name: "language version", lang: func(l Language) Language { l.Version = "3.13"; return l }},
+		{name: "language limits", lang: func(l Language) Language { l.MemoryMB++; return l }},
+		{name: "language image", lang: func(l Language) Language { l.Image = "other"; return l }},
+		{name: "timeout", timeMs: 2000},
+		{name: "output limit", r: &Runner{cfg: Config{MaxOutputBytes: 2048, JailMode: JailDirect}}},
+		{name: "jail mode", r: &Runner{cfg: Config{MaxOutputBytes: 1024, JailMode: JailK8sJob}}},
+	}
+	for _, tt := range differs {
+		t.Run(tt.name, func(t *testing.T) {
+			r, l, q, timeMs := r, lang, base, 1000
+			if tt.r != nil {
+				r = tt.r
+			}
+			if tt.lang != nil {
+				l = tt.lang(l)
+			}
+			if tt.req != nil {
+				q = tt.req(q)
+			}
+			if tt.timeMs != 0 {
+				timeMs = tt.timeMs
+			}
+			if r.cacheKey(l, q, timeMs) == key {
+				t.Errorf("changing the %s kept the key", tt.name)
+			}
+		})
+	}
+
+	// Who runs it and how it is scheduled do not change the result.
+	same := base
+	same.Cache, same.Trusted, same.CallerID, same.Priority = true, true, "user-1", PriorityInterview
+	if r.cacheKey(lang, same, 1000) != key {
+		t.Error("caller and scheduling fields changed the key")
+	}
+}
+
+// startFakeRedis serves GET and SET from memory over the Redis protocol,
+// enough for ResultCache, and answers other commands with OK.
+func startFakeRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	data := make(map[string]string)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for {
+			args, err := readRESPCommand(rd)
+			if err != nil {
+				return
+			}
+			var reply string
+			mu.Lock()
+			switch strings.ToUpper(args[0]) {
+			case "HELLO":
+				reply = "-ERR unknown command 'HELLO'\r\n"
+			case "GET":
+				if v, ok := data[args[1]]; ok {
+					reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+				} else {
+					reply = "$-1\r\n"
+				}
+			case "SET":
+				data[args[1]] = args[2]
+				reply = "+OK\r\n"
+			default:
+				reply = "+OK\r\n"
+			}
+			mu.Unlock()
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// readRESPCommand reads one command, an array of bulk strings.
+func readRESPCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = rd.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("bad argument header %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestCacheHit(t *testing.T) {
+	r, executor := newFakeRunner(FakeScript{Rules: []FakeRule{
+		{Source: "flaky", Outcome: FakeTimeout},
+	}}, 1)
+	r.cache = NewResultCache(startFakeRedis(t), time.Minute)
+	ctx := context.Background()
+	run := func(req ExecuteRequest) ExecuteResult {
+		req.LanguageID = 71
+		return r.Execute(ctx, req)
+	}
+
+	first := run(ExecuteRequest{SourceCode: "print(input())", Stdin: "1\n", Cache: true})
+	if first.Cached || first.Status.ID != StatusAccepted {
+		t.Fatalf("first run: status %v, cached %v", first.Status, first.Cached)
+	}
+	again := run(ExecuteRequest{SourceCode: "print(input())", Stdin: "1\n", Cache: true})
+	if !again.Cached || again.Stdout != first.Stdout {
+		t.Errorf("same request: cached %v, stdout %q; want the first run's result", again.Cached, again.Stdout)
+	}
+	if got := executor.executed(); len(got) != 1 {
+		t.Errorf("executor ran %d times for two identical requests, want 1", len(got))
+	}
+
+	// Other input, requests that do not opt in and results that depend on
+	// load all reach the executor.
+	run(ExecuteRequest{SourceCode: "print(input())", Stdin: "2\n", Cache: true})
+	run(ExecuteRequest{SourceCode: "print(input())", Stdin: "1\n"})
+	for range 2 {
+		if res := run(ExecuteRequest{SourceCode: "flaky()", TimeoutMs: 20, Cache: true}); res.Cached {
+			t.Error("time limit result was served from the cache")
+		}
+	}
+	if got := executor.executed(); len(got) != 5 {
+		t.Errorf("executor ran %d times, want 5", len(got))
+	}
+}
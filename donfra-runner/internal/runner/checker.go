@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Checker is a special judge: a program that decides whether a test case's
+// output is correct, for problems with many valid answers.
+//
+// It runs under the same jail and limits as submissions. It reads three
+// sections from stdin, each a line holding a byte count followed by exactly
+// that many bytes: the test input, the expected output, and the program's
+// output. Exit code 0 accepts the output and 1 rejects it; the first line of
+// its stdout becomes the test case message. Any other outcome (another exit
+// code, a crash, a timeout) is reported as an Internal Error.
+type Checker struct {
+	SourceCode string `json:"source_code"`
+	LanguageID int    `json:"language_id"`
+	TimeoutMs  int    `json:"timeout_ms,omitempty"`
+}
+
+// Checker exit codes.
+const (
+	checkerAccepted    = 0
+	checkerWrongAnswer = 1
+)
+
+// maxCheckerMessage caps the checker message copied into a test case result.
+const maxCheckerMessage = 256
+
+// ValidateChecker checks the checker of a judge request, if any.
+func (r *Runner) ValidateChecker(req ExecuteRequest) error {
+	c := req.Checker
+	if c == nil {
+		return nil
+	}
+	if len(req.TestCases) == 0 {
+		return errors.New("checker requires test_cases")
+	}
+	if c.SourceCode == "" {
+		return errors.New("checker source_code is required")
+	}
+	if _, err := GetLanguage(c.LanguageID); err != nil {
+		return fmt.Errorf("checker: %w", err)
+	}
+	if c.TimeoutMs < 0 {
+		return errors.New("checker timeout_ms must not be negative")
+	}
+	return nil
+}
+
+// checkerInput builds the checker's stdin for one test case.
+func checkerInput(tc TestCase, output string) string {
+	var b strings.Builder
+	for _, section := range []string{tc.Input, tc.ExpectedOutput, output} {
+		b.WriteString(strconv.Itoa(len(section)))
+		b.WriteByte('\n')
+		b.WriteString(section)
+	}
+	return b.String()
+}
+
+// runChecker runs the checker once per stdin.
+func (r *Runner) runChecker(ctx context.Context, c *Checker, trusted bool, stdins []string) []ExecuteResult {
+	lang, err := GetLanguage(c.LanguageID)
+	if err != nil {
+		return repeatResult(errorResult(err.Error()), len(stdins))
+	}
+	req := ExecuteRequest{SourceCode: c.SourceCode, LanguageID: c.LanguageID, Trusted: trusted}
+	return r.runEach(ctx, lang, req, stdins, r.resolveTimeout(lang, c.TimeoutMs), false)
+}
+
+// runEach runs req's source once per stdin, compiling it once. In K8s mode
+// all runs share a single Job. With stopOnFailure, runs after the first one
+// that is not Accepted are skipped where the executor allows it.
+func (r *Runner) runEach(ctx context.Context, lang Language, req ExecuteRequest, stdins []string, timeoutMs int, stopOnFailure bool) []ExecuteResult {
+	return r.executor.ExecuteBatch(ctx, lang, req, stdins, timeoutMs, stopOnFailure)
+}
+
+// checkerVerdict maps a checker run to the status and message of the test case it checked.
+func checkerVerdict(res ExecuteResult) (ExecuteStatus, string) {
+	message, _, _ := strings.Cut(strings.TrimSpace(res.Stdout), "\n")
+	if len(message) > maxCheckerMessage {
+		message = message[:maxCheckerMessage]
+	}
+
+	exitCode := -1
+	if res.ExitCode != nil {
+		exitCode = *res.ExitCode
+	}
+	switch {
+	case res.Status.ID == StatusAccepted && exitCode == checkerAccepted:
+		return ExecuteStatus{ID: StatusAccepted, Description: "Accepted"}, message
+	case res.Status.ID == StatusRuntimeError && exitCode == checkerWrongAnswer:
+		return ExecuteStatus{ID: StatusWrongAnswer, Description: "Wrong Answer"}, message
+	}
+
+	reason := res.Status.Description
+	if res.Status.ID == StatusRuntimeError && res.Message != "" {
+		reason = res.Message
+	}
+	if res.Status.ID == StatusCompilationError {
+		reason = "compilation failed"
+	}
+	return ExecuteStatus{ID: StatusInternalError, Description: "Internal Error"}, "checker failed: " + reason
+}
+
+func repeatResult(res ExecuteResult, n int) []ExecuteResult {
+	results := make([]ExecuteResult, n)
+	for i := range results {
+		results[i] = res
+	}
+	return results
+}
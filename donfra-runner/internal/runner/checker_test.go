@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckerVerdict(t *testing.T) {
+	exit := func(code int) *int { return &code }
+	accepted := ExecuteStatus{ID: StatusAccepted, Description: "Accepted"}
+	runtimeError := ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+
+	tests := []struct {
+		name        string
+		res         ExecuteResult
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "accepted",
+			res:         ExecuteResult{Status: accepted, Usage: Usage{ExitCode: exit(0)}, Stdout: "ok\n"},
+			wantStatus:  StatusAccepted,
+			wantMessage: "ok",
+		},
+		{
+			name:        "wrong answer keeps the first line",
+			res:         ExecuteResult{Status: runtimeError, Usage: Usage{ExitCode: exit(1)}, Stdout: "  expected 3, got 4\nmore detail\n"},
+			wantStatus:  StatusWrongAnswer,
+			wantMessage: "expected 3, got 4",
+		},
+		{
+			name:        "other exit code",
+			res:         ExecuteResult{Status: runtimeError, Usage: Usage{ExitCode: exit(2)}, Message: "Process exited with code 2"},
+			wantStatus:  StatusInternalError,
+			wantMessage: "checker failed: Process exited with code 2",
+		},
+		{
+			name:        "runtime error without message",
+			res:         ExecuteResult{Status: runtimeError, Usage: Usage{ExitCode: exit(3)}},
+			wantStatus:  StatusInternalError,
+			wantMessage: "checker failed: Runtime Error",
+		},
+		{
+			name:        "killed without exit code",
+			res:         ExecuteResult{Status: runtimeError, Usage: Usage{Signal: "SIGSEGV"}},
+			wantStatus:  StatusInternalError,
+			wantMessage: "checker failed: Runtime Error",
+		},
+		{
+			name:        "time limit",
+			res:         ExecuteResult{Status: ExecuteStatus{ID: StatusTimeLimitExceeded, Description: "Time Limit Exceeded"}},
+			wantStatus:  StatusInternalError,
+			wantMessage: "checker failed: Time Limit Exceeded",
+		},
+		{
+			name:        "compilation error",
+			res:         ExecuteResult{Status: ExecuteStatus{ID: StatusCompilationError, Description: "Compilation Error"}, Message: "main.c:1: error"},
+			wantStatus:  StatusInternalError,
+			wantMessage: "checker failed: compilation failed",
+		},
+		{
+			name:        "long message is cut",
+			res:         ExecuteResult{Status: accepted, Usage: Usage{ExitCode: exit(0)}, Stdout: strings.Repeat("x", maxCheckerMessage+10)},
+			wantStatus:  StatusAccepted,
+			wantMessage: strings.Repeat("x", maxCheckerMessage),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, message := checkerVerdict(tt.res)
+			if status.ID != tt.wantStatus || message != tt.wantMessage {
+				t.Errorf("checkerVerdict() = %d %q, want %d %q", status.ID, message, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
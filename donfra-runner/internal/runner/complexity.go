@@ -0,0 +1,282 @@
+package runner
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Generator produces the inputs of a complexity run. It is run once per size
+// with the size on stdin and prints an input of that size for the solution.
+type Generator struct {
+	SourceCode string `json:"source_code"`
+	LanguageID int    `json:"language_id"`
+	Sizes      []int  `json:"sizes,omitempty"` // increasing; defaults to defaultComplexitySizes
+}
+
+// ComplexityReport is the result of a complexity run: the measured points
+// and every candidate growth curve fitted to them, best first.
+type ComplexityReport struct {
+	BestFit string            `json:"best_fit"` // e.g. "O(n log n)"
+	Metric  string            `json:"metric"`   // "cpu" or "wall": which time TimeMs is
+	Fits    []ComplexityFit   `json:"fits"`
+	Points  []ComplexityPoint `json:"points"`
+}
+
+// ComplexityPoint is the fastest of complexityRuns runs at one input size.
+type ComplexityPoint struct {
+	Size     int   `json:"size"`
+	TimeMs   int64 `json:"time_ms"`
+	MemoryKB int64 `json:"memory_kb,omitempty"` // peak over the runs, where measured
+}
+
+// ComplexityFit is the least-squares fit time = InterceptMs + Coefficient*f(size)
+// of one complexity class.
+type ComplexityFit struct {
+	Class       string  `json:"class"`
+	Coefficient float64 `json:"coefficient"`
+	InterceptMs float64 `json:"intercept_ms"`
+	RMSEMs      float64 `json:"rmse_ms"`
+}
+
+// defaultComplexitySizes keep the generated inputs of typical array problems
+// within the default output limit, which also caps generator output.
+var defaultComplexitySizes = []int{100, 200, 500, 1000, 2000, 5000}
+
+const (
+	// complexityRuns is how often the solution runs per size; the fastest
+	// run is kept, as it is the one least disturbed by other load.
+	complexityRuns = 3
+	// minComplexitySizes is the fewest measured sizes a fit is made from.
+	minComplexitySizes = 3
+	maxComplexitySizes = 20
+)
+
+// Times are considered flat, and reported as O(1), unless the spread between
+// the fastest and slowest size exceeds both of these.
+const (
+	constantSpreadMs    = 2
+	constantSpreadRatio = 0.1
+)
+
+// complexityClass is a candidate growth curve.
+type complexityClass struct {
+	name string
+	f    func(n float64) float64
+	// maxSize is the largest size the class is fitted for, if limited.
+	maxSize int
+}
+
+var complexityClasses = []complexityClass{
+	{name: "O(log n)", f: func(n float64) float64 { return math.Log2(n) }},
+	{name: "O(n)", f: func(n float64) float64 { return n }},
+	{name: "O(n log n)", f: func(n float64) float64 { return n * math.Log2(n) }},
+	{name: "O(n^2)", f: func(n float64) float64 { return n * n }},
+	{name: "O(n^3)", f: func(n float64) float64 { return n * n * n }},
+	{name: "O(2^n)", f: func(n float64) float64 { return math.Exp2(n) }, maxSize: 64},
+}
+
+// validateGenerator checks the generator of a complexity request.
+func validateGenerator(g *Generator) error {
+	if g == nil {
+		return errors.New("complexity mode requires a generator")
+	}
+	if g.SourceCode == "" {
+		return errors.New("generator source_code is required")
+	}
+	if _, err := GetLanguage(g.LanguageID); err != nil {
+		return fmt.Errorf("generator: %w", err)
+	}
+	if len(g.Sizes) == 0 {
+		return nil
+	}
+	if len(g.Sizes) < minComplexitySizes || len(g.Sizes) > maxComplexitySizes {
+		return fmt.Errorf("generator needs %d to %d sizes", minComplexitySizes, maxComplexitySizes)
+	}
+	for i, n := range g.Sizes {
+		if n <= 0 || (i > 0 && n <= g.Sizes[i-1]) {
+			return errors.New("generator sizes must be positive and increasing")
+		}
+	}
+	return nil
+}
+
+// complexity runs the generator for every size, runs the solution on each
+// generated input and fits growth curves to the times. Sizes past the first
+// one the solution fails on (e.g. by timing out) are not measured.
+func (r *Runner) complexity(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int) ExecuteResult {
+	g := req.Generator
+	sizes := g.Sizes
+	if len(sizes) == 0 {
+		sizes = defaultComplexitySizes
+	}
+	var total ExecuteResult
+
+	genLang, _ := GetLanguage(g.LanguageID)
+	genReq := ExecuteRequest{SourceCode: g.SourceCode, LanguageID: g.LanguageID, Trusted: req.Trusted}
+	stdins := make([]string, len(sizes))
+	for i, n := range sizes {
+		stdins[i] = strconv.Itoa(n) + "\n"
+	}
+	inputs := make([]string, 0, len(sizes)*complexityRuns)
+	for i, res := range r.runEach(ctx, genLang, genReq, stdins, r.resolveTimeout(genLang, 0), true) {
+		addUsage(&total, res)
+		switch {
+		case res.Status.ID != StatusAccepted:
+			res.Message = fmt.Sprintf("generator failed for size %d: %s", sizes[i], failureReason(res))
+			res.ExecutionTimeMs, res.CPUUserMs, res.CPUSysMs = total.ExecutionTimeMs, total.CPUUserMs, total.CPUSysMs
+			return res
+		case res.StdoutTruncated:
+			return errorResult(fmt.Sprintf("generator output for size %d exceeds %d bytes; use smaller sizes", sizes[i], r.cfg.MaxOutputBytes))
+		}
+		for range complexityRuns {
+			inputs = append(inputs, res.Stdout)
+		}
+	}
+
+	// Measure sizes up to the first one the solution fails on.
+	results := r.runEach(ctx, lang, req, inputs, timeoutMs, true)
+	measured := len(sizes)
+	var failed *ExecuteResult
+	cpu := true
+	for i, res := range results {
+		addUsage(&total, res)
+		if res.Status.ID != StatusAccepted && failed == nil {
+			measured = i / complexityRuns
+			failed = &res
+			failed.Message = fmt.Sprintf("size %d: %s", sizes[measured], failureReason(res))
+		}
+		if i < measured*complexityRuns && res.CPUUserMs+res.CPUSysMs == 0 {
+			cpu = false
+		}
+	}
+
+	if measured < minComplexitySizes {
+		if failed == nil {
+			return errorResult("not enough sizes were measured")
+		}
+		failed.Message = fmt.Sprintf("only %d sizes measured, %d needed; %s", measured, minComplexitySizes, failed.Message)
+		failed.ExecutionTimeMs, failed.CPUUserMs, failed.CPUSysMs = total.ExecutionTimeMs, total.CPUUserMs, total.CPUSysMs
+		return *failed
+	}
+
+	// CPU time is steadier under load, so it is used when every run reports it.
+	metric := "wall"
+	if cpu {
+		metric = "cpu"
+	}
+	points := make([]ComplexityPoint, measured)
+	for i := range points {
+		points[i] = ComplexityPoint{Size: sizes[i], TimeMs: math.MaxInt64}
+		for _, res := range results[i*complexityRuns : (i+1)*complexityRuns] {
+			t := res.ExecutionTimeMs
+			if cpu {
+				t = res.CPUUserMs + res.CPUSysMs
+			}
+			points[i].TimeMs = min(points[i].TimeMs, t)
+			points[i].MemoryKB = max(points[i].MemoryKB, res.MemoryKB)
+		}
+	}
+
+	report := fitComplexity(points)
+	report.Metric = metric
+	total.Status = ExecuteStatus{ID: StatusAccepted, Description: "Accepted"}
+	total.Message = fmt.Sprintf("best fit %s over %d sizes", report.BestFit, len(points))
+	if failed != nil {
+		total.Message += fmt.Sprintf("; stopped at %s", failed.Message)
+	}
+	total.Complexity = &report
+	return total
+}
+
+// fitComplexity fits every complexity class to points and orders the fits by
+// error. Times that stay flat are reported as O(1).
+func fitComplexity(points []ComplexityPoint) ComplexityReport {
+	report := ComplexityReport{Points: points}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	var sum float64
+	for _, p := range points {
+		t := float64(p.TimeMs)
+		lo, hi, sum = math.Min(lo, t), math.Max(hi, t), sum+t
+	}
+	mean := sum / float64(len(points))
+	var sq float64
+	for _, p := range points {
+		sq += (float64(p.TimeMs) - mean) * (float64(p.TimeMs) - mean)
+	}
+	constant := ComplexityFit{Class: "O(1)", InterceptMs: mean, RMSEMs: math.Sqrt(sq / float64(len(points)))}
+
+	for _, class := range complexityClasses {
+		if class.maxSize > 0 && points[len(points)-1].Size > class.maxSize {
+			continue
+		}
+		if fit, ok := fitClass(class, points); ok {
+			report.Fits = append(report.Fits, fit)
+		}
+	}
+	slices.SortStableFunc(report.Fits, func(a, b ComplexityFit) int {
+		return cmp.Compare(a.RMSEMs, b.RMSEMs)
+	})
+
+	if spread := hi - lo; spread <= constantSpreadMs || spread <= constantSpreadRatio*lo || len(report.Fits) == 0 {
+		report.Fits = append([]ComplexityFit{constant}, report.Fits...)
+	} else {
+		report.Fits = append(report.Fits, constant)
+	}
+	report.BestFit = report.Fits[0].Class
+	return report
+}
+
+// fitClass fits time = a + b*f(size) by least squares. It fails when b is
+// not positive, i.e. the class does not describe growing times.
+func fitClass(class complexityClass, points []ComplexityPoint) (ComplexityFit, bool) {
+	n := float64(len(points))
+	var sx, sy float64
+	xs := make([]float64, len(points))
+	for i, p := range points {
+		xs[i] = class.f(float64(p.Size))
+		sx += xs[i]
+		sy += float64(p.TimeMs)
+	}
+	mx, my := sx/n, sy/n
+
+	var sxy, sxx float64
+	for i, p := range points {
+		dx := xs[i] - mx
+		sxy += dx * (float64(p.TimeMs) - my)
+		sxx += dx * dx
+	}
+	if sxx == 0 || sxy <= 0 {
+		return ComplexityFit{}, false
+	}
+	b := sxy / sxx
+	a := my - b*mx
+
+	var rss float64
+	for i, p := range points {
+		d := float64(p.TimeMs) - (a + b*xs[i])
+		rss += d * d
+	}
+	return ComplexityFit{Class: class.name, Coefficient: b, InterceptMs: a, RMSEMs: math.Sqrt(rss / n)}, true
+}
+
+// addUsage adds the time and CPU of res to total.
+func addUsage(total *ExecuteResult, res ExecuteResult) {
+	total.ExecutionTimeMs += res.ExecutionTimeMs
+	total.CPUUserMs += res.CPUUserMs
+	total.CPUSysMs += res.CPUSysMs
+	total.MemoryKB = max(total.MemoryKB, res.MemoryKB)
+}
+
+// failureReason describes how a run failed.
+func failureReason(res ExecuteResult) string {
+	if res.Message != "" {
+		return res.Message
+	}
+	return res.Status.Description
+}
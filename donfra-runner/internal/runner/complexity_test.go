@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// timedPoints measures t(size) ms, rounded as the runner reports it, at sizes.
+func timedPoints(sizes []int, t func(n float64) float64) []ComplexityPoint {
+	points := make([]ComplexityPoint, len(sizes))
+	for i, size := range sizes {
+		points[i] = ComplexityPoint{Size: size, TimeMs: int64(math.Round(t(float64(size))))}
+	}
+	return points
+}
+
+func fixedPoints(sizes []int, times ...int64) []ComplexityPoint {
+	points := make([]ComplexityPoint, len(sizes))
+	for i, size := range sizes {
+		points[i] = ComplexityPoint{Size: size, TimeMs: times[i]}
+	}
+	return points
+}
+
+func TestFitComplexity(t *testing.T) {
+	sizes := defaultComplexitySizes
+	small := []int{10, 12, 14, 16, 18, 20}
+
+	tests := []struct {
+		name   string
+		points []ComplexityPoint
+		want   string
+	}{
+		{"linear", timedPoints(sizes, func(n float64) float64 { return 2 + n/50 }), "O(n)"},
+		{"n log n", timedPoints(sizes, func(n float64) float64 { return n * math.Log2(n) / 100 }), "O(n log n)"},
+		{"quadratic", timedPoints(sizes, func(n float64) float64 { return 1 + n*n/10000 }), "O(n^2)"},
+		{"cubic", timedPoints([]int{1000, 2000, 3000, 4000, 5000}, func(n float64) float64 { return n * n * n / 1e8 }), "O(n^3)"},
+		{"logarithmic", timedPoints(sizes, func(n float64) float64 { return 10 * math.Log2(n) }), "O(log n)"},
+		{"exponential", timedPoints(small, func(n float64) float64 { return math.Exp2(n) / 1000 }), "O(2^n)"},
+		{"flat within a few ms", fixedPoints(sizes, 50, 51, 50, 52, 51, 50), "O(1)"},
+		{"flat within 10%", fixedPoints(sizes, 1000, 1050, 1080, 1020, 1010, 1040), "O(1)"},
+		{"falling times", fixedPoints(sizes, 60, 50, 40, 30, 20, 10), "O(1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := fitComplexity(tt.points)
+			if report.BestFit != tt.want {
+				t.Fatalf("BestFit = %s, want %s (fits %+v)", report.BestFit, tt.want, report.Fits)
+			}
+			if report.Fits[0].Class != report.BestFit {
+				t.Errorf("first fit %s is not the best fit %s", report.Fits[0].Class, report.BestFit)
+			}
+			// Apart from where O(1) is placed, fits are ordered by error.
+			fitted := slices.DeleteFunc(slices.Clone(report.Fits), func(f ComplexityFit) bool { return f.Class == "O(1)" })
+			if !slices.IsSortedFunc(fitted, func(a, b ComplexityFit) int { return cmp.Compare(a.RMSEMs, b.RMSEMs) }) {
+				t.Errorf("fits not ordered by error: %+v", report.Fits)
+			}
+		})
+	}
+}
+
+func TestFitComplexitySkipsExponentialForLargeSizes(t *testing.T) {
+	report := fitComplexity(timedPoints(defaultComplexitySizes, func(n float64) float64 { return n / 10 }))
+	for _, fit := range report.Fits {
+		if fit.Class == "O(2^n)" {
+			t.Fatalf("O(2^n) fitted to sizes up to %d", defaultComplexitySizes[len(defaultComplexitySizes)-1])
+		}
+	}
+}
+
+func TestFitComplexityFallingTimesOnlyFitConstant(t *testing.T) {
+	report := fitComplexity(fixedPoints([]int{100, 200, 300}, 30, 20, 10))
+	if len(report.Fits) != 1 || report.Fits[0].Class != "O(1)" || report.Fits[0].InterceptMs != 20 {
+		t.Errorf("fits = %+v, want only O(1) at 20ms", report.Fits)
+	}
+}
+
+func TestComplexityStopsAtFirstFailure(t *testing.T) {
+	r, executor := newFakeRunner(FakeScript{Rules: []FakeRule{
+		{Source: "solve", Stdin: "4000", ExitCode: 1},
+	}}, 1)
+	sizes := []int{1000, 2000, 3000, 4000, 5000}
+	res := r.Execute(context.Background(), ExecuteRequest{
+		SourceCode: "solve()",
+		LanguageID: 71,
+		Mode:       ModeComplexity,
+		Generator:  &Generator{SourceCode: "print(input())", LanguageID: 71, Sizes: sizes},
+	})
+
+	if res.Status.ID != StatusAccepted || res.Complexity == nil {
+		t.Fatalf("status %v, message %q, want a report", res.Status, res.Message)
+	}
+	if got := len(res.Complexity.Points); got != 3 {
+		t.Errorf("%d sizes measured, want the 3 before the failing one", got)
+	}
+	if !strings.Contains(res.Message, "stopped at size 4000") {
+		t.Errorf("message %q does not name the failing size", res.Message)
+	}
+
+	// The solution's batch ran every size up to the first failing run and
+	// skipped the rest.
+	runs := executor.batches[len(executor.batches)-1]
+	if len(runs) != len(sizes)*complexityRuns {
+		t.Fatalf("%d solution results, want %d", len(runs), len(sizes)*complexityRuns)
+	}
+	failed := 3 * complexityRuns
+	for i, res := range runs {
+		switch {
+		case i < failed && res.Status.ID != StatusAccepted:
+			t.Errorf("run %d: status %v, want Accepted", i, res.Status)
+		case i == failed && res.Status.ID != StatusRuntimeError:
+			t.Errorf("run %d: status %v, want the Runtime Error", i, res.Status)
+		case i > failed && res.Message != "skipped after an earlier run failed":
+			t.Errorf("run %d: status %v, message %q; want skipped", i, res.Status, res.Message)
+		}
+	}
+}
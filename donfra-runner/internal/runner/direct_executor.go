@@ -0,0 +1,333 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"donfra-runner/internal/tracing"
+)
+
+// DirectExecutor runs programs on this node in a private work dir: bare with
+// a context timeout and a CPU ulimit (local dev), or inside the sandbox jail
+// when it has one.
+//
+// Bare runs go through jail-rusage when it is installed, which measures the
+// program's peak memory and kills it past the memory limit, as in the K8s
+// jail. Without it they report no peak memory, and a program over the limit
+// sees its allocations fail under a data-segment ulimit (usually ending in a
+// Runtime Error) rather than getting Memory Limit Exceeded.
+type DirectExecutor struct {
+	cfg     Config
+	sandbox *Sandbox // nil runs programs bare
+	rusage  string   // path of jail-rusage for bare runs; empty runs them under ulimits only
+}
+
+func NewDirectExecutor(cfg Config, sandbox *Sandbox, rusage string) *DirectExecutor {
+	return &DirectExecutor{cfg: cfg, sandbox: sandbox, rusage: rusage}
+}
+
+func (e *DirectExecutor) Execute(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int, out OutputFunc) ExecuteResult {
+	prog, failed := e.prepare(ctx, lang, req.SourceCode, req.Files)
+	if failed != nil {
+		return *failed
+	}
+	defer prog.cleanup()
+
+	return e.run(ctx, prog, req.Stdin, timeoutMs, out)
+}
+
+// ExecuteBatch compiles req's source once and runs it once per stdin.
+func (e *DirectExecutor) ExecuteBatch(ctx context.Context, lang Language, req ExecuteRequest, stdins []string, timeoutMs int, stopOnFailure bool) []ExecuteResult {
+	prog, failed := e.prepare(ctx, lang, req.SourceCode, req.Files)
+	if failed != nil {
+		return repeatResult(*failed, len(stdins))
+	}
+	defer prog.cleanup()
+
+	results := make([]ExecuteResult, len(stdins))
+	for i, stdin := range stdins {
+		results[i] = e.run(ctx, prog, stdin, timeoutMs, nil)
+		if stopOnFailure && results[i].Status.ID != StatusAccepted {
+			copy(results[i+1:], repeatResult(errorResult("skipped after an earlier run failed"), len(stdins)-i-1))
+			break
+		}
+	}
+	return results
+}
+
+// program is source code materialized (and compiled, if needed) in a private work dir.
+type program struct {
+	lang   Language
+	dir    string
+	source string
+	binary string
+}
+
+func (p *program) cleanup() {
+	os.RemoveAll(p.dir)
+}
+
+// prepare writes the source and any project files into a fresh work dir
+// and runs the compile step. A non-nil result is returned when preparation or
+// compilation failed.
+func (e *DirectExecutor) prepare(ctx context.Context, lang Language, sourceCode string, files []ProjectFile) (*program, *ExecuteResult) {
+	dir, err := writeWorkDir(sourceCode, lang.SourceFile, files)
+	if err != nil {
+		log.Printf("failed to prepare work dir: %v", err)
+		res := errorResult("internal error: failed to prepare execution")
+		return nil, &res
+	}
+
+	prog := &program{
+		lang:   lang,
+		dir:    dir,
+		source: filepath.Join(dir, filepath.FromSlash(lang.SourceFile)),
+		binary: filepath.Join(dir, binaryName),
+	}
+
+	if lang.Compiled() {
+		ctx, span := tracing.StartSpan(ctx, "runner.compile", tracing.AttrLanguage.String(lang.Name))
+		res := e.compile(ctx, prog)
+		span.End()
+		if res != nil {
+			prog.cleanup()
+			return nil, res
+		}
+	}
+	return prog, nil
+}
+
+// compile runs the language's compile step within its own time and memory budget.
+// Returns nil on success, or a Compilation Error result carrying the diagnostics.
+func (e *DirectExecutor) compile(ctx context.Context, p *program) *ExecuteResult {
+	timeoutMs := p.lang.CompileTimeoutMs
+	if timeoutMs <= 0 {
+		timeoutMs = e.cfg.MaxTimeoutMs
+	}
+	compileCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+
+	cmd, finish, err := e.command(compileCtx, p, p.lang.CompileCmd, p.lang.CompileMemoryMB, timeoutMs)
+	if err != nil {
+		log.Printf("failed to prepare compile command: %v", err)
+		res := errorResult("internal error: failed to prepare execution")
+		return &res
+	}
+
+	var out bytes.Buffer
+	lw := &limitedWriter{w: &out, limit: e.cfg.MaxOutputBytes}
+	cmd.Stdout = lw
+	cmd.Stderr = lw
+
+	err = cmd.Run()
+	usage := finish()
+	if err == nil {
+		return nil
+	}
+
+	message := "compilation failed"
+	switch {
+	case errors.Is(compileCtx.Err(), context.DeadlineExceeded):
+		message = fmt.Sprintf("compilation timed out after %dms", timeoutMs)
+	case usage.OOMKilled:
+		message = fmt.Sprintf("compiler exceeded the %dMB memory limit", p.lang.CompileMemoryMB)
+	}
+	return &ExecuteResult{
+		Status:        ExecuteStatus{ID: StatusCompilationError, Description: "Compilation Error"},
+		Message:       message,
+		CompileOutput: out.String(),
+	}
+}
+
+// run runs a prepared program, bare with a context timeout or inside the
+// sandbox jail.
+func (e *DirectExecutor) run(ctx context.Context, p *program, stdin string, timeoutMs int, out OutputFunc) ExecuteResult {
+	execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+
+	cmd, finish, err := e.command(execCtx, p, p.lang.RunCmd, p.lang.MemoryMB, timeoutMs)
+	if err != nil {
+		log.Printf("failed to prepare run command: %v", err)
+		return errorResult("internal error: failed to prepare execution")
+	}
+
+	start := time.Now()
+	result := e.runCmd(execCtx, cmd, stdin, out)
+	result.ExecutionTimeMs = time.Since(start).Milliseconds()
+
+	// The cgroup covers every process the program started, so prefer its figures.
+	usage := finish()
+	if usage.MemoryKB > 0 {
+		result.MemoryKB = usage.MemoryKB
+	}
+	if usage.CPUUserMs+usage.CPUSysMs > 0 {
+		result.CPUUserMs, result.CPUSysMs = usage.CPUUserMs, usage.CPUSysMs
+	}
+	if usage.OOMKilled && result.Status.ID != StatusTimeLimitExceeded {
+		result.Status = ExecuteStatus{ID: StatusMemoryLimit, Description: "Memory Limit Exceeded"}
+		result.Message = "process was killed due to memory limit"
+	}
+	return result
+}
+
+// command builds the process for one step (compile or run) of p. With a
+// sandbox it runs inside the jail with the work dir mounted at sandboxWorkDir;
+// otherwise it runs on the host under a CPU-time ulimit (timeoutMs, as in the
+// sandbox) and, through jail-rusage, a memory budget of memoryMB. Without
+// jail-rusage a data-segment ulimit stands in for the budget and finish
+// reports no usage: the process's ru_maxrss would be the runner's own (see
+// processUsage). The returned finish func must be called after the process
+// exits.
+func (e *DirectExecutor) command(ctx context.Context, p *program, tmpl []string, memoryMB, timeoutMs int) (*exec.Cmd, func() resourceUsage, error) {
+	if e.sandbox != nil {
+		argv := expandCmd(tmpl, sandboxWorkDir,
+			path.Join(sandboxWorkDir, p.lang.SourceFile), path.Join(sandboxWorkDir, binaryName))
+		return e.sandbox.Command(ctx, p.dir, argv, memoryMB, p.lang.CPULimitMillis, timeoutMs)
+	}
+
+	argv := expandCmd(tmpl, p.dir, p.source, p.binary)
+	if e.rusage == "" {
+		argv = withLimits(argv, memoryMB, timeoutMs)
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Dir = p.dir
+		return cmd, func() resourceUsage { return resourceUsage{} }, nil
+	}
+
+	// The usage file lives outside the work dir, where the program cannot
+	// overwrite it.
+	f, err := os.CreateTemp("", "rusage-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create usage file: %w", err)
+	}
+	f.Close()
+	usageFile := f.Name()
+
+	// jail-rusage samples resident memory and kills the program past the
+	// budget, so no data-segment ulimit: it would fail allocations first.
+	// The context bounds the wall time, so jail-rusage gets no timeout.
+	argv = append([]string{e.rusage, "-m", strconv.Itoa(memoryMB), usageFile, "0"}, withLimits(argv, 0, timeoutMs)...)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = p.dir
+	killGroupOnCancel(cmd)
+
+	finish := func() resourceUsage {
+		defer os.Remove(usageFile)
+		return readRusageFile(usageFile, memoryMB)
+	}
+	return cmd, finish, nil
+}
+
+// readRusageFile parses the line jail-rusage writes after the program exits:
+//
+//	<user_ms> <sys_ms> <peak_rss_kb> <exit_code|-> <signal|-> <timed_out 0|1>
+//
+// A SIGKILL with peak memory over memoryMB is jail-rusage enforcing the
+// budget. The file is empty when jail-rusage itself was killed.
+func readRusageFile(name string, memoryMB int) resourceUsage {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return resourceUsage{}
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 6 {
+		return resourceUsage{}
+	}
+	var usage resourceUsage
+	usage.CPUUserMs, _ = strconv.ParseInt(fields[0], 10, 64)
+	usage.CPUSysMs, _ = strconv.ParseInt(fields[1], 10, 64)
+	usage.MemoryKB, _ = strconv.ParseInt(fields[2], 10, 64)
+	usage.OOMKilled = fields[4] == "SIGKILL" && memoryMB > 0 && usage.MemoryKB > int64(memoryMB)*1024
+	return usage
+}
+
+// runCmd executes a command and maps the result to ExecuteResult.
+func (e *DirectExecutor) runCmd(ctx context.Context, cmd *exec.Cmd, stdin string, out OutputFunc) ExecuteResult {
+	var stdoutBuf, stderrBuf bytes.Buffer
+	var stdoutW, stderrW io.Writer = &stdoutBuf, &stderrBuf
+	if out != nil {
+		start := time.Now()
+		stdoutW = io.MultiWriter(&stdoutBuf, &streamWriter{stream: StreamStdout, out: out, start: start})
+		stderrW = io.MultiWriter(&stderrBuf, &streamWriter{stream: StreamStderr, out: out, start: start})
+	}
+	stdoutLW := &limitedWriter{w: stdoutW, limit: e.cfg.MaxOutputBytes}
+	stderrLW := &limitedWriter{w: stderrW, limit: e.cfg.MaxOutputBytes}
+	cmd.Stdout = stdoutLW
+	cmd.Stderr = stderrLW
+
+	if stdin != "" {
+		cmd.Stdin = bytes.NewReader([]byte(stdin))
+	}
+
+	err := cmd.Run()
+
+	result := exitResult(ctx, err, processUsage(cmd.ProcessState))
+	result.Stdout = stdoutBuf.String()
+	result.Stderr = stderrBuf.String()
+	result.StdoutTruncated = stdoutLW.truncated
+	result.StderrTruncated = stderrLW.truncated
+	return result
+}
+
+// Session runs req's source with stdin fed from input. lang.RunCmd is the
+// session command; timeoutMs bounds the session's wall time.
+func (e *DirectExecutor) Session(ctx context.Context, lang Language, req SessionRequest, input <-chan []byte, timeoutMs int, out OutputFunc) ExecuteResult {
+	prog, failed := e.prepare(ctx, lang, req.SourceCode, nil)
+	if failed != nil {
+		return *failed
+	}
+	defer prog.cleanup()
+
+	execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+
+	// command turns the step timeout into RLIMIT_CPU, in the sandbox or via
+	// ulimit when bare, so give it the CPU budget; execCtx bounds the wall time.
+	cmd, finish, err := e.command(execCtx, prog, lang.RunCmd, lang.MemoryMB, e.cfg.SessionCPUMs)
+	if err != nil {
+		log.Printf("failed to prepare session command: %v", err)
+		return errorResult("internal error: failed to prepare execution")
+	}
+	pipe, err := cmd.StdinPipe()
+	if err != nil {
+		log.Printf("failed to open session stdin: %v", err)
+		return errorResult("internal error: failed to prepare execution")
+	}
+
+	start := time.Now()
+	cmd.Stdout = &streamWriter{stream: StreamStdout, out: out, start: start}
+	cmd.Stderr = &streamWriter{stream: StreamStderr, out: out, start: start}
+	if err := cmd.Start(); err != nil {
+		finish()
+		log.Printf("failed to start session: %v", err)
+		return errorResult("internal error: failed to start execution")
+	}
+	go feedStdin(execCtx, pipe, input)
+
+	err = cmd.Wait()
+	result := exitResult(execCtx, err, processUsage(cmd.ProcessState))
+	result.ExecutionTimeMs = time.Since(start).Milliseconds()
+
+	usage := finish()
+	if usage.MemoryKB > 0 {
+		result.MemoryKB = usage.MemoryKB
+	}
+	if usage.CPUUserMs+usage.CPUSysMs > 0 {
+		result.CPUUserMs, result.CPUSysMs = usage.CPUUserMs, usage.CPUSysMs
+	}
+	if usage.OOMKilled && result.Status.ID != StatusTimeLimitExceeded {
+		result.Status = ExecuteStatus{ID: StatusMemoryLimit, Description: "Memory Limit Exceeded"}
+		result.Message = "process was killed due to memory limit"
+	}
+	return result
+}
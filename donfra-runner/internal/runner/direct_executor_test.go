@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadRusageFile(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want resourceUsage
+	}{
+		{"exited", "12 3 9000 0 - 0\n", resourceUsage{CPUUserMs: 12, CPUSysMs: 3, MemoryKB: 9000}},
+		{"killed over budget", "40 20 70000 - SIGKILL 0\n", resourceUsage{CPUUserMs: 40, CPUSysMs: 20, MemoryKB: 70000, OOMKilled: true}},
+		{"killed within budget", "40 20 9000 - SIGKILL 0\n", resourceUsage{CPUUserMs: 40, CPUSysMs: 20, MemoryKB: 9000}},
+		{"crashed over budget", "40 20 70000 - SIGSEGV 0\n", resourceUsage{CPUUserMs: 40, CPUSysMs: 20, MemoryKB: 70000}},
+		{"empty", "", resourceUsage{}},
+		{"truncated", "12 3", resourceUsage{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "usage")
+			if err := os.WriteFile(name, []byte(tt.line), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if got := readRusageFile(name, 64); got != tt.want {
+				t.Errorf("readRusageFile(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDirectExecutorRusage runs bare programs through jail-rusage built from
+// donfra-jail.
+func TestDirectExecutorRusage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("jail-rusage reads /proc")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not installed")
+	}
+	rusage := filepath.Join(t.TempDir(), "jail-rusage")
+	if out, err := exec.Command(cc, "-O2", "-o", rusage, "../../../donfra-jail/jail-rusage.c").CombinedOutput(); err != nil {
+		t.Fatalf("build jail-rusage: %v\n%s", err, out)
+	}
+
+	e := NewDirectExecutor(Config{MaxOutputBytes: 1 << 16}, nil, rusage)
+	lang := Language{ID: 71, Name: "Python", SourceFile: "main.py", RunCmd: []string{python, "{source}"}, MemoryMB: 64}
+	ctx := context.Background()
+
+	res := e.Execute(ctx, lang, ExecuteRequest{SourceCode: "print(input())", Stdin: "hi"}, 5000, nil)
+	if res.Status.ID != StatusAccepted || res.Stdout != "hi\n" {
+		t.Fatalf("print: status %v, stdout %q, stderr %q", res.Status, res.Stdout, res.Stderr)
+	}
+	if res.MemoryKB <= 0 || res.MemoryKB > 64*1024 {
+		t.Errorf("print: memory_kb = %d, want the program's own peak", res.MemoryKB)
+	}
+
+	res = e.Execute(ctx, lang, ExecuteRequest{SourceCode: "b = b'x' * (256 << 20)\nprint(len(b))"}, 5000, nil)
+	if res.Status.ID != StatusMemoryLimit {
+		t.Errorf("allocate 256MB: status %v, want Memory Limit Exceeded (stderr %q)", res.Status, res.Stderr)
+	}
+	if res.MemoryKB <= 64*1024 {
+		t.Errorf("allocate 256MB: memory_kb = %d, want over the 64MB budget", res.MemoryKB)
+	}
+
+	// The timeout kills jail-rusage's whole process group, not just jail-rusage.
+	res = e.Execute(ctx, lang, ExecuteRequest{SourceCode: "import time\ntime.sleep(30)"}, 300, nil)
+	if res.Status.ID != StatusTimeLimitExceeded || res.ExecutionTimeMs > 5000 {
+		t.Errorf("sleep: status %v after %dms, want Time Limit Exceeded at the timeout", res.Status, res.ExecutionTimeMs)
+	}
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cancelGrace is how long Drain waits for cancelled executions to clean up
+// (kill processes, delete Jobs and pods) once its deadline has passed.
+const cancelGrace = 10 * time.Second
+
+// drainState tracks in-flight executions so shutdown can wait for them.
+type drainState struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight int
+	drained  chan struct{} // closed once draining with nothing in flight
+
+	// stopCtx is cancelled when Drain gives up waiting; every execution
+	// context is cancelled with it.
+	stopCtx context.Context
+	stop    context.CancelFunc
+}
+
+func newDrainState() *drainState {
+	stopCtx, stop := context.WithCancel(context.Background())
+	return &drainState{drained: make(chan struct{}), stopCtx: stopCtx, stop: stop}
+}
+
+// begin registers an execution. It returns a context that is also cancelled
+// when a drain times out, and a func that must be called when the execution
+// finishes. ok is false once the runner is draining.
+func (d *drainState) begin(ctx context.Context) (context.Context, func(), bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return ctx, nil, false
+	}
+	d.inFlight++
+
+	ctx, cancel := context.WithCancel(ctx)
+	stopCancel := context.AfterFunc(d.stopCtx, cancel)
+	return ctx, func() {
+		stopCancel()
+		cancel()
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.inFlight--
+		if d.draining && d.inFlight == 0 {
+			close(d.drained)
+		}
+	}, true
+}
+
+// Draining reports whether the runner has stopped accepting executions.
+func (r *Runner) Draining() bool {
+	r.drain.mu.Lock()
+	defer r.drain.mu.Unlock()
+	return r.drain.draining
+}
+
+// InFlight returns the number of executions currently running or queued for a slot.
+func (r *Runner) InFlight() int {
+	r.drain.mu.Lock()
+	defer r.drain.mu.Unlock()
+	return r.drain.inFlight
+}
+
+// Drain stops the runner from accepting executions and waits for in-flight
+// ones to finish. When ctx is done first, the remaining executions are
+// cancelled, which kills their processes and deletes their Jobs or pods, and
+// Drain waits up to cancelGrace for that before returning ctx's error.
+func (r *Runner) Drain(ctx context.Context) error {
+	d := r.drain
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		if d.inFlight == 0 {
+			close(d.drained)
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	d.stop()
+	select {
+	case <-d.drained:
+	case <-time.After(cancelGrace):
+	}
+	return ctx.Err()
+}
+
+func drainingResult() ExecuteResult {
+	return ExecuteResult{
+		Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Service Unavailable"},
+		Message: "runner is shutting down, try again later",
+	}
+}
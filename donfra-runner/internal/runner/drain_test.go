@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	r, executor := newFakeRunner(FakeScript{Rules: []FakeRule{
+		{Source: "slow", LatencyMs: 200, Stdout: "done\n"},
+	}}, 2)
+	ctx := context.Background()
+
+	running := make(chan ExecuteResult)
+	go func() { running <- r.Execute(ctx, ExecuteRequest{SourceCode: "slow()", LanguageID: 71}) }()
+	waitFor(t, "the run to start", func() bool { return r.InFlight() == 1 })
+
+	drained := make(chan error)
+	go func() { drained <- r.Drain(ctx) }()
+	waitFor(t, "the drain to start", r.Draining)
+
+	res := r.Execute(ctx, ExecuteRequest{SourceCode: "print(1)", LanguageID: 71})
+	if res.Status.Description != "Service Unavailable" {
+		t.Errorf("run while draining: status %v, want Service Unavailable", res.Status)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned %v with a run in flight", err)
+	default:
+	}
+
+	if res := <-running; res.Status.ID != StatusAccepted || res.Stdout != "done\n" {
+		t.Errorf("in-flight run: status %v, stdout %q", res.Status, res.Stdout)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("Drain() = %v", err)
+	}
+	if got := executor.executed(); len(got) != 1 {
+		t.Errorf("executor ran %q, want only the in-flight run", got)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	r, _ := newFakeRunner(FakeScript{Rules: []FakeRule{{Source: "slow", LatencyMs: 5000}}}, 1)
+
+	running := make(chan ExecuteResult)
+	go func() {
+		running <- r.Execute(context.Background(), ExecuteRequest{SourceCode: "slow()", LanguageID: 71})
+	}()
+	waitFor(t, "the run to start", func() bool { return r.InFlight() == 1 })
+
+	// Past its deadline Drain cancels the run instead of waiting it out.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() = %v, want context.DeadlineExceeded", err)
+	}
+	if res := <-running; res.Status.ID == StatusAccepted {
+		t.Errorf("cancelled run: status %v", res.Status)
+	}
+}
@@ -0,0 +1,21 @@
+package runner
+
+import "context"
+
+// Executor runs programs for a Runner once it has validated a request and
+// taken an execution slot. The jail mode selects it: DirectExecutor for
+// direct and sandbox, K8sExecutor for k8s and FakeExecutor for fake.
+type Executor interface {
+	// Execute runs req once with req.Stdin. When out is non-nil, output is
+	// also delivered to it as it is produced.
+	Execute(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int, out OutputFunc) ExecuteResult
+
+	// ExecuteBatch compiles req's source once and runs it once per stdin,
+	// returning results in stdin order. With stopOnFailure, runs after the
+	// first one that is not Accepted may be skipped.
+	ExecuteBatch(ctx context.Context, lang Language, req ExecuteRequest, stdins []string, timeoutMs int, stopOnFailure bool) []ExecuteResult
+
+	// Session runs req with stdin fed from input as it arrives, until the
+	// program exits or timeoutMs passes. lang.RunCmd is the session command.
+	Session(ctx context.Context, lang Language, req SessionRequest, input <-chan []byte, timeoutMs int, out OutputFunc) ExecuteResult
+}
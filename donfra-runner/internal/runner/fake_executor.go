@@ -0,0 +1,245 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FakeOutcome is how a scripted fake run ends.
+type FakeOutcome string
+
+const (
+	// FakeExit exits with the rule's ExitCode: Accepted for 0, Runtime Error
+	// otherwise.
+	FakeExit FakeOutcome = ""
+	// FakeTimeout runs until the timeout and reports Time Limit Exceeded.
+	FakeTimeout FakeOutcome = "timeout"
+	// FakeOOM is killed for exceeding the language's memory limit.
+	FakeOOM FakeOutcome = "oom"
+	// FakeCompileError fails to compile with the rule's CompileOutput.
+	FakeCompileError FakeOutcome = "compile_error"
+)
+
+// FakeRule scripts the result of the runs it matches. A run matches when its
+// source and stdin contain Source and Stdin and its language is LanguageID;
+// empty or zero fields match anything.
+type FakeRule struct {
+	Source     string `json:"source,omitempty"`
+	Stdin      string `json:"stdin,omitempty"`
+	LanguageID int    `json:"language_id,omitempty"`
+
+	Outcome       FakeOutcome `json:"outcome,omitempty"`
+	Stdout        string      `json:"stdout,omitempty"`
+	Stderr        string      `json:"stderr,omitempty"`
+	CompileOutput string      `json:"compile_output,omitempty"`
+	ExitCode      int         `json:"exit_code,omitempty"`
+
+	// LatencyMs is how long the run takes. Its CPU time is reported as the
+	// same.
+	LatencyMs int   `json:"latency_ms,omitempty"`
+	MemoryKB  int64 `json:"memory_kb,omitempty"`
+}
+
+// FakeScript is the script of a FakeExecutor. The first rule that matches a
+// run decides its result; runs no rule matches are Accepted at once and echo
+// their stdin, like cat.
+type FakeScript struct {
+	Rules []FakeRule `json:"rules"`
+}
+
+// LoadFakeScript reads a FakeScript from a YAML or JSON file.
+func LoadFakeScript(path string) (FakeScript, error) {
+	var script FakeScript
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return script, err
+	}
+	if err := yaml.UnmarshalStrict(data, &script); err != nil {
+		return script, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i, rule := range script.Rules {
+		switch rule.Outcome {
+		case FakeExit, FakeTimeout, FakeOOM, FakeCompileError:
+		default:
+			return script, fmt.Errorf("%s: rule %d: unknown outcome %q", path, i, rule.Outcome)
+		}
+	}
+	return script, nil
+}
+
+// FakeExecutor runs no code: each run's result comes from a FakeScript, so
+// the same request always gets the same result. It lets the runner, and
+// everything in front of it, be tested and developed against without a
+// jail, a cluster or language toolchains.
+type FakeExecutor struct {
+	script FakeScript
+}
+
+func NewFakeExecutor(script FakeScript) *FakeExecutor {
+	return &FakeExecutor{script: script}
+}
+
+func (e *FakeExecutor) Execute(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int, out OutputFunc) ExecuteResult {
+	return e.run(ctx, lang, req.SourceCode, req.Stdin, timeoutMs, out)
+}
+
+func (e *FakeExecutor) ExecuteBatch(ctx context.Context, lang Language, req ExecuteRequest, stdins []string, timeoutMs int, stopOnFailure bool) []ExecuteResult {
+	results := make([]ExecuteResult, len(stdins))
+	for i, stdin := range stdins {
+		results[i] = e.run(ctx, lang, req.SourceCode, stdin, timeoutMs, nil)
+		if results[i].Status.ID == StatusCompilationError {
+			copy(results[i+1:], repeatResult(results[i], len(stdins)-i-1))
+			break
+		}
+		if stopOnFailure && results[i].Status.ID != StatusAccepted {
+			copy(results[i+1:], repeatResult(errorResult("skipped after an earlier run failed"), len(stdins)-i-1))
+			break
+		}
+	}
+	return results
+}
+
+// Session writes the matching rule's output, then echoes each input chunk to
+// stdout until input is closed, and ends like the rule says. The rule is
+// matched against the source only.
+func (e *FakeExecutor) Session(ctx context.Context, lang Language, req SessionRequest, input <-chan []byte, timeoutMs int, out OutputFunc) ExecuteResult {
+	rule := e.match(lang, req.SourceCode, "")
+	if rule.Outcome == FakeCompileError {
+		return fakeCompileError(rule)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	emit := func(stream, data string) {
+		if data != "" {
+			out(OutputChunk{Stream: stream, Data: data, ElapsedMs: time.Since(start).Milliseconds()})
+		}
+	}
+
+	if !fakeSleep(ctx, time.Duration(rule.LatencyMs)*time.Millisecond) {
+		return fakeInterrupted(ctx, start)
+	}
+	emit(StreamStdout, rule.Stdout)
+	emit(StreamStderr, rule.Stderr)
+
+	for {
+		select {
+		case data, ok := <-input:
+			if !ok {
+				if rule.Outcome == FakeTimeout {
+					<-ctx.Done()
+					return fakeInterrupted(ctx, start)
+				}
+				return fakeResult(lang, rule, start)
+			}
+			emit(StreamStdout, string(data))
+		case <-ctx.Done():
+			return fakeInterrupted(ctx, start)
+		}
+	}
+}
+
+// run produces the scripted result of one run, taking the rule's latency.
+func (e *FakeExecutor) run(ctx context.Context, lang Language, source, stdin string, timeoutMs int, out OutputFunc) ExecuteResult {
+	rule := e.match(lang, source, stdin)
+	if rule.Outcome == FakeCompileError {
+		return fakeCompileError(rule)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+
+	if rule.Outcome == FakeTimeout {
+		<-ctx.Done()
+		return fakeInterrupted(ctx, start)
+	}
+	if !fakeSleep(ctx, time.Duration(rule.LatencyMs)*time.Millisecond) {
+		return fakeInterrupted(ctx, start)
+	}
+
+	result := fakeResult(lang, rule, start)
+	result.Stdout, result.Stderr = rule.Stdout, rule.Stderr
+	if out != nil {
+		for _, chunk := range []OutputChunk{
+			{Stream: StreamStdout, Data: result.Stdout},
+			{Stream: StreamStderr, Data: result.Stderr},
+		} {
+			if chunk.Data != "" {
+				chunk.ElapsedMs = result.ExecutionTimeMs
+				out(chunk)
+			}
+		}
+	}
+	return result
+}
+
+// match returns the first rule matching a run, or the echo rule.
+func (e *FakeExecutor) match(lang Language, source, stdin string) FakeRule {
+	for _, rule := range e.script.Rules {
+		if strings.Contains(source, rule.Source) && strings.Contains(stdin, rule.Stdin) &&
+			(rule.LanguageID == 0 || rule.LanguageID == lang.ID) {
+			return rule
+		}
+	}
+	return FakeRule{Stdout: stdin}
+}
+
+// fakeResult is the verdict of a run that ended as rule says; output is
+// left to the caller.
+func fakeResult(lang Language, rule FakeRule, start time.Time) ExecuteResult {
+	elapsed := time.Since(start).Milliseconds()
+	result := ExecuteResult{ExecutionTimeMs: elapsed}
+	result.CPUUserMs, result.MemoryKB = elapsed, rule.MemoryKB
+
+	switch {
+	case rule.Outcome == FakeOOM:
+		result.Status = ExecuteStatus{ID: StatusMemoryLimit, Description: "Memory Limit Exceeded"}
+		result.Message = "process was killed due to memory limit"
+		result.Signal = "SIGKILL"
+		result.MemoryKB = max(result.MemoryKB, int64(lang.MemoryMB)*1024)
+	case rule.ExitCode == 0:
+		result.Status = ExecuteStatus{ID: StatusAccepted, Description: "Accepted"}
+		result.ExitCode = &rule.ExitCode
+	default:
+		result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+		result.Message = fmt.Sprintf("Process exited with code %d", rule.ExitCode)
+		result.ExitCode = &rule.ExitCode
+	}
+	return result
+}
+
+func fakeCompileError(rule FakeRule) ExecuteResult {
+	return ExecuteResult{
+		Status:        ExecuteStatus{ID: StatusCompilationError, Description: "Compilation Error"},
+		Message:       "compilation failed",
+		CompileOutput: rule.CompileOutput,
+	}
+}
+
+// fakeInterrupted is the result of a run whose context ended first: Time
+// Limit Exceeded at its timeout, cancelled otherwise.
+func fakeInterrupted(ctx context.Context, start time.Time) ExecuteResult {
+	result := exitResult(ctx, nil, Usage{})
+	result.ExecutionTimeMs = time.Since(start).Milliseconds()
+	result.CPUUserMs = result.ExecutionTimeMs
+	return result
+}
+
+// fakeSleep waits for d, reporting false if ctx ends first.
+func fakeSleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
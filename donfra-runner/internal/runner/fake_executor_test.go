@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// recordingExecutor passes runs on to an Executor and records what it got.
+type recordingExecutor struct {
+	Executor
+
+	mu      sync.Mutex
+	runs    []ExecuteRequest  // Execute calls, in order
+	batches [][]ExecuteResult // ExecuteBatch results, in order
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int, out OutputFunc) ExecuteResult {
+	e.mu.Lock()
+	e.runs = append(e.runs, req)
+	e.mu.Unlock()
+	return e.Executor.Execute(ctx, lang, req, timeoutMs, out)
+}
+
+func (e *recordingExecutor) ExecuteBatch(ctx context.Context, lang Language, req ExecuteRequest, stdins []string, timeoutMs int, stopOnFailure bool) []ExecuteResult {
+	results := e.Executor.ExecuteBatch(ctx, lang, req, stdins, timeoutMs, stopOnFailure)
+	e.mu.Lock()
+	e.batches = append(e.batches, results)
+	e.mu.Unlock()
+	return results
+}
+
+// executed returns the sources of the Execute calls so far.
+func (e *recordingExecutor) executed() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sources := make([]string, len(e.runs))
+	for i, req := range e.runs {
+		sources[i] = req.SourceCode
+	}
+	return sources
+}
+
+// newFakeRunner returns a Runner whose runs are scripted by script, with
+// maxConcurrent execution slots and no cache.
+func newFakeRunner(script FakeScript, maxConcurrent int) (*Runner, *recordingExecutor) {
+	executor := &recordingExecutor{Executor: NewFakeExecutor(script)}
+	cfg := Config{
+		JailMode:       JailFake,
+		MaxTimeoutMs:   5000,
+		DefaultTimeout: 1000,
+		MaxOutputBytes: 1 << 16,
+		MaxTestCases:   10,
+		QueueTimeoutMs: 5000,
+	}
+	return New(cfg, NewLimiter(maxConcurrent, 0, 0), executor, nil), executor
+}
+
+func TestFakeExecutorScript(t *testing.T) {
+	r, _ := newFakeRunner(FakeScript{Rules: []FakeRule{
+		{Source: "crash", ExitCode: 3, Stderr: "boom\n"},
+		{Source: "hog", Outcome: FakeOOM},
+		{Source: "spin", Outcome: FakeTimeout},
+		{Source: "typo", Outcome: FakeCompileError, CompileOutput: "syntax error"},
+		{Stdin: "hello", Stdout: "world\n", MemoryKB: 2048},
+		{LanguageID: 63, Stdout: "from node\n"},
+	}}, 1)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		req        ExecuteRequest
+		wantStatus int
+		wantStdout string
+	}{
+		{"echo", ExecuteRequest{SourceCode: "print(input())", LanguageID: 71, Stdin: "1\n"}, StatusAccepted, "1\n"},
+		{"stdin rule", ExecuteRequest{SourceCode: "print(input())", LanguageID: 71, Stdin: "say hello"}, StatusAccepted, "world\n"},
+		{"language rule", ExecuteRequest{SourceCode: "console.log(1)", LanguageID: 63}, StatusAccepted, "from node\n"},
+		{"exit code", ExecuteRequest{SourceCode: "crash()", LanguageID: 71}, StatusRuntimeError, ""},
+		{"out of memory", ExecuteRequest{SourceCode: "hog()", LanguageID: 71}, StatusMemoryLimit, ""},
+		{"timeout", ExecuteRequest{SourceCode: "spin()", LanguageID: 71, TimeoutMs: 50}, StatusTimeLimitExceeded, ""},
+		{"compile error", ExecuteRequest{SourceCode: "typo", LanguageID: 71}, StatusCompilationError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := r.Execute(ctx, tt.req)
+			if res.Status.ID != tt.wantStatus || res.Stdout != tt.wantStdout {
+				t.Errorf("status %v, stdout %q; want status %d, stdout %q", res.Status, res.Stdout, tt.wantStatus, tt.wantStdout)
+			}
+		})
+	}
+
+	res := r.Execute(ctx, ExecuteRequest{SourceCode: "crash()", LanguageID: 71})
+	if res.ExitCode == nil || *res.ExitCode != 3 || res.Stderr != "boom\n" {
+		t.Errorf("crash: exit code %v, stderr %q", res.ExitCode, res.Stderr)
+	}
+	if res := r.Execute(ctx, ExecuteRequest{SourceCode: "hog()", LanguageID: 71}); res.MemoryKB < 128*1024 {
+		t.Errorf("out of memory: memory_kb = %d, want at least the language limit", res.MemoryKB)
+	}
+}
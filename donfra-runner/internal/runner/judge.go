@@ -0,0 +1,194 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CompareMode controls how a test case's stdout is matched against its expected output.
+//   - "exact": byte-for-byte equality
+//   - "trimmed": ignores trailing whitespace on each line and trailing blank lines (default)
+//   - "float": token-wise comparison, numeric tokens match within Tolerance
+type CompareMode string
+
+const (
+	CompareExact   CompareMode = "exact"
+	CompareTrimmed CompareMode = "trimmed"
+	CompareFloat   CompareMode = "float"
+)
+
+// defaultFloatTolerance is used by CompareFloat when the test case does not set one.
+const defaultFloatTolerance = 1e-6
+
+type TestCase struct {
+	Input          string      `json:"input"`
+	ExpectedOutput string      `json:"expected_output"`
+	Compare        CompareMode `json:"compare,omitempty"`
+	Tolerance      float64     `json:"tolerance,omitempty"`
+}
+
+type TestCaseResult struct {
+	Index           int           `json:"index"`
+	Status          ExecuteStatus `json:"status"`
+	Stdout          string        `json:"stdout,omitempty"`
+	Stderr          string        `json:"stderr,omitempty"`
+	Message         string        `json:"message,omitempty"`
+	ExecutionTimeMs int64         `json:"execution_time_ms"`
+	Usage
+}
+
+// ValidateTestCases checks the test case count and comparison settings of a judge request.
+func (r *Runner) ValidateTestCases(cases []TestCase) error {
+	if r.cfg.MaxTestCases > 0 && len(cases) > r.cfg.MaxTestCases {
+		return fmt.Errorf("too many test cases: %d (max %d)", len(cases), r.cfg.MaxTestCases)
+	}
+	for i, tc := range cases {
+		switch tc.Compare {
+		case "", CompareExact, CompareTrimmed, CompareFloat:
+		default:
+			return fmt.Errorf("test case %d: unsupported compare mode %q", i, tc.Compare)
+		}
+		if tc.Tolerance < 0 {
+			return fmt.Errorf("test case %d: tolerance must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// judge runs the program once per test case and reports a verdict for each.
+// Outputs are compared with the expected output, or handed to req.Checker
+// once every case has run. The overall status is that of the first failing
+// case, or Accepted if all pass.
+func (r *Runner) judge(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int) ExecuteResult {
+	stdins := make([]string, len(req.TestCases))
+	for i, tc := range req.TestCases {
+		stdins[i] = tc.Input
+	}
+	runs := r.runEach(ctx, lang, req, stdins, timeoutMs, false)
+	if runs[0].Status.ID == StatusCompilationError {
+		return runs[0]
+	}
+
+	results := make([]TestCaseResult, 0, len(req.TestCases))
+	var totalMs, cpuUserMs, cpuSysMs, peakKB int64
+	var toCheck []int // cases that ran cleanly and await the checker
+
+	for i, tc := range req.TestCases {
+		res := runs[i]
+		if res.Status.ID == StatusAccepted {
+			if req.Checker != nil {
+				toCheck = append(toCheck, i)
+			} else if !outputMatches(res.Stdout, tc) {
+				res.Status = ExecuteStatus{ID: StatusWrongAnswer, Description: "Wrong Answer"}
+			}
+		}
+
+		results = append(results, TestCaseResult{
+			Index:           i,
+			Status:          res.Status,
+			Stdout:          res.Stdout,
+			Stderr:          res.Stderr,
+			Message:         res.Message,
+			ExecutionTimeMs: res.ExecutionTimeMs,
+			Usage:           res.Usage,
+		})
+		totalMs += res.ExecutionTimeMs
+		cpuUserMs += res.CPUUserMs
+		cpuSysMs += res.CPUSysMs
+		peakKB = max(peakKB, res.MemoryKB)
+	}
+
+	if len(toCheck) > 0 {
+		stdins := make([]string, len(toCheck))
+		for j, i := range toCheck {
+			stdins[j] = checkerInput(req.TestCases[i], results[i].Stdout)
+		}
+		for j, res := range r.runChecker(ctx, req.Checker, req.Trusted, stdins) {
+			results[toCheck[j]].Status, results[toCheck[j]].Message = checkerVerdict(res)
+		}
+	}
+
+	failedIdx := slices.IndexFunc(results, func(tc TestCaseResult) bool {
+		return tc.Status.ID != StatusAccepted
+	})
+
+	summary := results[len(results)-1]
+	if failedIdx >= 0 {
+		summary = results[failedIdx]
+	}
+
+	result := ExecuteResult{
+		Status:          summary.Status,
+		Stdout:          summary.Stdout,
+		Stderr:          summary.Stderr,
+		ExecutionTimeMs: totalMs,
+		Usage:           summary.Usage,
+		TestResults:     results,
+	}
+	// CPU time adds up across cases; memory is the peak of any one case.
+	result.CPUUserMs, result.CPUSysMs, result.MemoryKB = cpuUserMs, cpuSysMs, peakKB
+	if failedIdx >= 0 {
+		result.Message = fmt.Sprintf("test case %d failed: %s", failedIdx, summary.Status.Description)
+	} else {
+		result.Message = fmt.Sprintf("all %d test cases passed", len(results))
+	}
+	return result
+}
+
+// outputMatches reports whether actual satisfies the expectation of tc.
+func outputMatches(actual string, tc TestCase) bool {
+	switch tc.Compare {
+	case CompareExact:
+		return actual == tc.ExpectedOutput
+	case CompareFloat:
+		tol := tc.Tolerance
+		if tol == 0 {
+			tol = defaultFloatTolerance
+		}
+		return floatTokensMatch(actual, tc.ExpectedOutput, tol)
+	default:
+		return normalizeOutput(actual) == normalizeOutput(tc.ExpectedOutput)
+	}
+}
+
+// normalizeOutput strips trailing whitespace from every line and drops trailing blank lines.
+func normalizeOutput(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// floatTokensMatch compares whitespace-separated tokens; tokens that parse as
+// numbers on both sides match within an absolute or relative tolerance.
+func floatTokensMatch(actual, expected string, tol float64) bool {
+	got := strings.Fields(actual)
+	want := strings.Fields(expected)
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range want {
+		if got[i] == want[i] {
+			continue
+		}
+		a, errA := strconv.ParseFloat(got[i], 64)
+		b, errB := strconv.ParseFloat(want[i], 64)
+		if errA != nil || errB != nil {
+			return false
+		}
+		diff := math.Abs(a - b)
+		if diff > tol && diff > tol*math.Max(math.Abs(a), math.Abs(b)) {
+			return false
+		}
+	}
+	return true
+}
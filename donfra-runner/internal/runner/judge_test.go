@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOutputMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		actual string
+		tc     TestCase
+		want   bool
+	}{
+		{"trimmed equal", "1 2\n", TestCase{ExpectedOutput: "1 2"}, true},
+		{"trimmed trailing spaces", "1 2  \r\n3\t\n\n", TestCase{ExpectedOutput: "1 2\n3"}, true},
+		{"trimmed leading space differs", " 1 2", TestCase{ExpectedOutput: "1 2"}, false},
+		{"trimmed inner blank line kept", "1\n\n2", TestCase{ExpectedOutput: "1\n2"}, false},
+		{"explicit trimmed", "ok \n", TestCase{ExpectedOutput: "ok", Compare: CompareTrimmed}, true},
+		{"exact equal", "ok\n", TestCase{ExpectedOutput: "ok\n", Compare: CompareExact}, true},
+		{"exact trailing newline", "ok\n", TestCase{ExpectedOutput: "ok", Compare: CompareExact}, false},
+		{"float default tolerance", "0.3333333", TestCase{ExpectedOutput: "0.33333333", Compare: CompareFloat}, true},
+		{"float outside tolerance", "0.33", TestCase{ExpectedOutput: "0.3333", Compare: CompareFloat}, false},
+		{"float custom tolerance", "0.33", TestCase{ExpectedOutput: "0.3333", Compare: CompareFloat, Tolerance: 0.01}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outputMatches(tt.actual, tt.tc); got != tt.want {
+				t.Errorf("outputMatches(%q, %+v) = %v, want %v", tt.actual, tt.tc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloatTokensMatch(t *testing.T) {
+	tests := []struct {
+		name             string
+		actual, expected string
+		tol              float64
+		want             bool
+	}{
+		{"identical", "1.5 2.5", "1.5 2.5", 1e-6, true},
+		{"whitespace differs", "1.5\n2.5\n", "1.5 2.5", 1e-6, true},
+		{"within absolute tolerance", "0.1000001", "0.1", 1e-6, true},
+		{"outside absolute tolerance", "0.11", "0.1", 1e-6, false},
+		{"within relative tolerance", "1000000.5", "1000000", 1e-6, true},
+		{"outside relative tolerance", "1000002", "1000000", 1e-6, false},
+		{"integer and float forms", "2", "2.000", 1e-6, true},
+		{"word tokens must be equal", "yes 1.0", "no 1.0", 1e-6, false},
+		{"word against number", "abc", "1", 1e-6, false},
+		{"token count differs", "1 2", "1 2 3", 1e-6, false},
+		{"both empty", "", "\n", 1e-6, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := floatTokensMatch(tt.actual, tt.expected, tt.tol); got != tt.want {
+				t.Errorf("floatTokensMatch(%q, %q, %g) = %v, want %v", tt.actual, tt.expected, tt.tol, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJudgeVerdicts(t *testing.T) {
+	r, _ := newFakeRunner(FakeScript{Rules: []FakeRule{
+		{Source: "typo", Outcome: FakeCompileError, CompileOutput: "syntax error"},
+		{Stdin: "wrong", Stdout: "nope\n", MemoryKB: 4096},
+		{Stdin: "crash", ExitCode: 1, MemoryKB: 1024},
+		{Stdin: "peak", Stdout: "peak\n", MemoryKB: 8192},
+	}}, 1)
+	ctx := context.Background()
+	echo := func(in string) TestCase { return TestCase{Input: in, ExpectedOutput: in} }
+	judge := func(source string, cases ...TestCase) ExecuteResult {
+		return r.Execute(ctx, ExecuteRequest{SourceCode: source, LanguageID: 71, TestCases: cases})
+	}
+
+	res := judge("print(input())", echo("1"), echo("peak"), echo("3"))
+	if res.Status.ID != StatusAccepted || res.Message != "all 3 test cases passed" {
+		t.Errorf("all pass: status %v, message %q", res.Status, res.Message)
+	}
+	if res.MemoryKB != 8192 {
+		t.Errorf("all pass: memory_kb = %d, want the peak case's 8192", res.MemoryKB)
+	}
+
+	// The first failing case decides the verdict; later cases still run.
+	res = judge("print(input())", echo("1"), echo("wrong"), echo("crash"), echo("4"))
+	want := []int{StatusAccepted, StatusWrongAnswer, StatusRuntimeError, StatusAccepted}
+	if len(res.TestResults) != len(want) {
+		t.Fatalf("mixed: %d test results, want %d", len(res.TestResults), len(want))
+	}
+	for i, tc := range res.TestResults {
+		if tc.Index != i || tc.Status.ID != want[i] {
+			t.Errorf("mixed: case %d has index %d, status %v; want status %d", i, tc.Index, tc.Status, want[i])
+		}
+	}
+	if res.Status.ID != StatusWrongAnswer || res.Message != "test case 1 failed: Wrong Answer" || res.Stdout != "nope\n" {
+		t.Errorf("mixed: status %v, message %q, stdout %q", res.Status, res.Message, res.Stdout)
+	}
+	if res.MemoryKB != 4096 {
+		t.Errorf("mixed: memory_kb = %d, want the peak over all cases", res.MemoryKB)
+	}
+
+	res = judge("typo", echo("1"), echo("2"))
+	if res.Status.ID != StatusCompilationError || res.CompileOutput != "syntax error" || res.TestResults != nil {
+		t.Errorf("compile error: status %v, compile output %q, %d test results", res.Status, res.CompileOutput, len(res.TestResults))
+	}
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Jail pods log in to Redis as users that may only touch their own keys and
+// channel, so user code that gets hold of a pod's credentials still cannot
+// read or disturb other executions. The runner creates a user for each
+// execution and each warm pool pod and deletes it when the execution ends or
+// the pod is deleted; collectRedisUsers removes any a crashed runner left.
+
+const (
+	execUserPrefix = "jail-exec:"
+	poolUserPrefix = "jail-pool:"
+)
+
+func execRedisUser(execID string) string {
+	return execUserPrefix + execID
+}
+
+func poolRedisUser(podName string) string {
+	return poolUserPrefix + podName
+}
+
+func stdinKey(execID string) string {
+	return "stdin:" + execID
+}
+
+// allowExecution creates the Redis user of one execution, which may read its
+// payload and stdin list and publish on its result channel.
+func (e *K8sExecutor) allowExecution(ctx context.Context, execID, password string) error {
+	return e.setRedisUser(ctx, execRedisUser(execID), password,
+		"~"+payloadKey(execID), "~"+stdinKey(execID), "&exec:"+execID,
+		"+get", "+brpop", "+publish", "+ping")
+}
+
+// allowPoolPod creates the Redis user of a warm pool pod, which may advertise
+// itself in its language's idle set and wait on its own queue.
+func (p *warmPool) allowPoolPod(ctx context.Context, langID int, podName string) error {
+	return p.e.setRedisUser(ctx, poolRedisUser(podName), p.podRedisPassword(podName),
+		"~"+poolIdleKey(langID), "~"+poolQueueKey(podName),
+		"+sadd", "+srem", "+brpop", "+ping")
+}
+
+// podRedisPassword is a pool pod's Redis password. Like podKey it is derived
+// from the signing secret, so any replica can recreate the pod's user.
+func (p *warmPool) podRedisPassword(podName string) string {
+	return macHex(p.e.signingSecret, "pool-redis:"+podName)
+}
+
+// setRedisUser creates or replaces the Redis user name, enabled with password
+// and nothing but rules.
+func (e *K8sExecutor) setRedisUser(ctx context.Context, name, password string, rules ...string) error {
+	args := []any{"ACL", "SETUSER", name, "reset", "on", ">" + password}
+	for _, r := range rules {
+		args = append(args, r)
+	}
+	return e.redisClient.Do(ctx, args...).Err()
+}
+
+func (e *K8sExecutor) deleteRedisUser(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := e.redisClient.Do(ctx, "ACL", "DELUSER", name).Err(); err != nil {
+		log.Printf("failed to delete redis user %s: %v", name, err)
+	}
+}
+
+// collectRedisUsers deletes jail users no pod can still be using: those of
+// executions whose payload is gone, which happens when the execution ends or
+// its deadline passes, and those of pool pods that no longer exist. Users are
+// listed before pods, and pod users are created after their pods, so a pod
+// being created is never mistaken for a deleted one.
+func (e *K8sExecutor) collectRedisUsers(ctx context.Context) {
+	users, err := e.redisClient.Do(ctx, "ACL", "USERS").StringSlice()
+	if err != nil {
+		log.Printf("[gc] list redis users failed: %v", err)
+		return
+	}
+	pods, err := e.kubeClient.CoreV1().Pods(e.namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=donfra-jail,pool-language"})
+	if err != nil {
+		log.Printf("[gc] list pool pods failed: %v", err)
+		return
+	}
+	alive := make(map[string]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		alive[pod.Name] = true
+	}
+
+	deleted := 0
+	for _, user := range users {
+		stale := false
+		if execID, ok := strings.CutPrefix(user, execUserPrefix); ok {
+			n, err := e.redisClient.Exists(ctx, payloadKey(execID)).Result()
+			stale = err == nil && n == 0
+		} else if podName, ok := strings.CutPrefix(user, poolUserPrefix); ok {
+			stale = !alive[podName]
+		}
+		if stale {
+			e.deleteRedisUser(user)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		log.Printf("[gc] deleted %d stale jail redis users", deleted)
+	}
+}
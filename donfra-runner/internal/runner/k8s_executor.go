@@ -1,12 +1,14 @@
 package runner
 
 import (
+	"cmp"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math"
+	"net"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,42 +18,132 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"donfra-runner/internal/metrics"
+	"donfra-runner/internal/tracing"
 )
 
 // K8sExecutor creates K8s Jobs for code execution and collects results via Redis pub/sub.
+// With a warm pool started, executions go to idle pre-created pods when available.
 type K8sExecutor struct {
-	kubeClient   kubernetes.Interface
-	redisClient  *redis.Client
-	namespace    string
-	jailImage string
-	cfg          Config
+	kubeClient  kubernetes.Interface
+	redisClient *redis.Client
+	namespace   string
+	jailImage   string
+	cfg         Config
+	pool        *warmPool
+
+	// redisHost and redisPort are where jail pods reach redisClient's server.
+	redisHost string
+	redisPort string
+
+	// signingSecret derives the keys that authenticate warm pool jobs.
+	signingSecret string
+
+	// instanceID labels the objects this process creates (runner-instance),
+	// so the orphan collector can tell them from other replicas'.
+	instanceID string
+}
+
+// jailChunk is an output chunk the jail publishes before its result when
+// STREAM_OUTPUT is enabled. Result messages have an empty Type.
+type jailChunk struct {
+	Type      string `json:"type"`
+	Stream    string `json:"stream"`
+	DataB64   string `json:"data_b64"`
+	ElapsedMs int64  `json:"elapsed_ms"`
 }
 
 // jailResult is the JSON structure published by the jail entrypoint.
 type jailResult struct {
+	// Index identifies the run in a batch Job; it is absent for single runs.
+	Index           *int   `json:"index"`
 	ExecutionID     string `json:"execution_id"`
 	StatusID        int    `json:"status_id"`
 	StatusDesc      string `json:"status_desc"`
 	StdoutB64       string `json:"stdout_b64"`
 	StderrB64       string `json:"stderr_b64"`
+	CompileOutB64   string `json:"compile_output_b64"`
 	Message         string `json:"message"`
-	ExitCode        int    `json:"exit_code"`
+	ExitCode        *int   `json:"exit_code"`
+	Signal          string `json:"signal"`
 	ExecutionTimeMs int64  `json:"execution_time_ms"`
+	CPUUserMs       int64  `json:"cpu_user_ms"`
+	CPUSysMs        int64  `json:"cpu_sys_ms"`
+	MemoryKB        int64  `json:"memory_kb"`
+	StdoutTruncated bool   `json:"stdout_truncated"`
+	StderrTruncated bool   `json:"stderr_truncated"`
+}
+
+// jailFile is a project file as the jail entrypoint receives it.
+type jailFile struct {
+	Path       string `json:"path"`
+	ContentB64 string `json:"content_b64"`
 }
 
-func NewK8sExecutor(kubeClient kubernetes.Interface, redisClient *redis.Client, namespace, jailImage string, cfg Config) *K8sExecutor {
+// NewK8sExecutor returns an executor whose jail pods exchange messages with
+// the runner through redisClient's server, logging in as the per-execution
+// and per-pod users the executor creates there.
+func NewK8sExecutor(kubeClient kubernetes.Interface, redisClient *redis.Client, namespace, jailImage, signingSecret string, cfg Config) *K8sExecutor {
+	redisHost, redisPort, err := net.SplitHostPort(redisClient.Options().Addr)
+	if err != nil {
+		redisHost, redisPort = "redis", "6379"
+	}
 	return &K8sExecutor{
-		kubeClient:   kubeClient,
-		redisClient:  redisClient,
-		namespace:    namespace,
-		jailImage: jailImage,
-		cfg:          cfg,
+		kubeClient:    kubeClient,
+		redisClient:   redisClient,
+		namespace:     namespace,
+		redisHost:     redisHost,
+		redisPort:     redisPort,
+		jailImage:     jailImage,
+		cfg:           cfg,
+		signingSecret: signingSecret,
+		instanceID:    uuid.New().String()[:8],
 	}
 }
 
-func (e *K8sExecutor) Execute(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int) ExecuteResult {
+func (e *K8sExecutor) Execute(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int, out OutputFunc) ExecuteResult {
+	return e.execute(ctx, lang, req, nil, nil, timeoutMs, out)[0]
+}
+
+// Session runs req's source with stdin fed from input in a Job or warm pod
+// of its own. lang.RunCmd is the session command; timeoutMs bounds the
+// session's wall time.
+func (e *K8sExecutor) Session(ctx context.Context, lang Language, req SessionRequest, input <-chan []byte, timeoutMs int, out OutputFunc) ExecuteResult {
+	execReq := ExecuteRequest{SourceCode: req.SourceCode, LanguageID: req.LanguageID}
+	return e.execute(ctx, lang, execReq, nil, input, timeoutMs, out)[0]
+}
+
+// ExecuteBatch runs req's source once per stdin inside a single Job, so the
+// pod is scheduled and the source compiled only once. Results are in stdin
+// order. The jail runs every stdin, so stopOnFailure has no effect.
+func (e *K8sExecutor) ExecuteBatch(ctx context.Context, lang Language, req ExecuteRequest, stdins []string, timeoutMs int, stopOnFailure bool) []ExecuteResult {
+	return e.execute(ctx, lang, req, stdins, nil, timeoutMs, nil)
+}
+
+// execute runs one execution in a warm pod or a new Job. With stdins set, the jail runs the program once per
+// entry and publishes an indexed result for each; with input set, it reads stdin from input as it arrives;
+// otherwise it runs req.Stdin.
+func (e *K8sExecutor) execute(ctx context.Context, lang Language, req ExecuteRequest, stdins []string, input <-chan []byte, timeoutMs int, out OutputFunc) []ExecuteResult {
+	runs := max(len(stdins), 1)
+	results := make([]ExecuteResult, runs)
+	pending := runs
+
+	// fill stores res for every run that has not reported yet.
+	fill := func(res ExecuteResult) []ExecuteResult {
+		for i := range results {
+			if results[i].Status.ID == 0 {
+				results[i] = res
+			}
+		}
+		return results
+	}
+
 	execID := uuid.New().String()
+	execKey := newExecKey()
+	redisPassword := newExecKey()
 	channel := "exec:" + execID
+	env := e.jailEnv(execID, execKey, redisPassword, lang, req, timeoutMs, out != nil, input != nil)
 
 	// Subscribe to Redis BEFORE creating the Job to avoid race conditions.
 	sub := e.redisClient.Subscribe(ctx, channel)
@@ -61,71 +153,182 @@ func (e *K8sExecutor) Execute(ctx context.Context, lang Language, req ExecuteReq
 	_, err := sub.Receive(ctx)
 	if err != nil {
 		log.Printf("redis subscribe failed: %v", err)
-		return ExecuteResult{
-			Token:   "ws-exec",
+		return fill(ExecuteResult{
 			Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
 			Message: "result channel unavailable",
-		}
+		})
 	}
 
-	// Create the K8s Job.
-	jobName := fmt.Sprintf("exec-%s", execID[:8])
-	job := e.buildJobSpec(jobName, execID, lang, req, timeoutMs)
+	// cleanup removes the Job or warm pod after a timeout; timeoutPods finds its pod.
+	var cleanup func()
+	var timeoutPods metav1.ListOptions
+	startupBuffer := 8 * time.Second // Job scheduling + pod startup
 
-	_, err = e.kubeClient.BatchV1().Jobs(e.namespace).Create(ctx, job, metav1.CreateOptions{})
-	if err != nil {
-		log.Printf("k8s job create failed: %v", err)
-		return ExecuteResult{
-			Token:   "ws-exec",
+	// The inputs go to Redis rather than into the Job spec or pool message,
+	// which limit their size and show them to anyone who can read the Job.
+	payloadTTL := time.Duration(lang.CompileTimeoutMs+runs*timeoutMs)*time.Millisecond + startupBuffer
+	if err := e.putPayload(ctx, execID, execKey, req, stdins, payloadTTL); err != nil {
+		log.Printf("failed to store payload for %s: %v", execID, err)
+		return fill(ExecuteResult{
+			Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
+			Message: "execution backend unavailable",
+		})
+	}
+	defer e.redisClient.Del(context.Background(), payloadKey(execID))
+
+	// The jail logs in to Redis as a user limited to this execution's keys.
+	if err := e.allowExecution(ctx, execID, redisPassword); err != nil {
+		log.Printf("failed to create redis user for %s: %v", execID, err)
+		return fill(ExecuteResult{
 			Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
 			Message: "execution backend unavailable",
+		})
+	}
+	defer e.deleteRedisUser(execRedisUser(execID))
+
+	// Prefer an idle warm pod; fall back to creating a Job when there is none.
+	podName, warm := e.pool.dispatch(ctx, lang.ID, req.Trusted, env)
+
+	if warm {
+		cleanup = func() { e.deletePod(podName) }
+		timeoutPods = metav1.ListOptions{FieldSelector: "metadata.name=" + podName}
+		startupBuffer = 2 * time.Second
+	} else {
+		jobName := fmt.Sprintf("exec-%s", execID[:8])
+		job := e.buildJobSpec(jobName, execID, lang, env, runs, timeoutMs)
+
+		jobCtx, span := tracing.StartSpan(ctx, "k8s.job", tracing.AttrJobName.String(jobName))
+		createStart := time.Now()
+		_, err = e.kubeClient.BatchV1().Jobs(e.namespace).Create(jobCtx, job, metav1.CreateOptions{})
+		metrics.K8sJobCreateDuration.Observe(time.Since(createStart).Seconds())
+		tracing.RecordError(span, err)
+		span.End()
+		if err != nil {
+			log.Printf("k8s job create failed: %v", err)
+			return fill(ExecuteResult{
+				Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
+				Message: "execution backend unavailable",
+			})
 		}
+
+		cleanup = func() { e.deleteJob(jobName) }
+		timeoutPods = metav1.ListOptions{LabelSelector: fmt.Sprintf("exec-id=%s", execID[:8])}
 	}
 
 	// Wait for result from Redis with timeout.
-	// Total wait = execution timeout + 8s buffer for Job scheduling + pod startup.
-	waitTimeout := time.Duration(timeoutMs)*time.Millisecond + 8*time.Second
+	// Total wait = compile + execution timeout per run + startup buffer.
+	waitTimeout := time.Duration(lang.CompileTimeoutMs+runs*timeoutMs)*time.Millisecond + startupBuffer
 	waitCtx, waitCancel := context.WithTimeout(ctx, waitTimeout)
 	defer waitCancel()
 
+	if input != nil {
+		go e.feedStdin(waitCtx, execID, execKey, input, waitTimeout)
+	}
+
 	msgCh := sub.Channel()
-	select {
-	case msg := <-msgCh:
-		return e.parseResult(msg.Payload)
-	case <-waitCtx.Done():
-		// Timeout — try to determine if OOM or generic timeout.
-		result := e.handleTimeout(execID)
-		// Background cleanup.
-		go e.deleteJob(jobName)
-		return result
+	for {
+		select {
+		case msg := <-msgCh:
+			// Anything not signed with this execution's key is forged or corrupt.
+			payload, ok := openMessage(execKey, msg.Payload)
+			if !ok {
+				log.Printf("dropping unauthenticated message on %s", channel)
+				metrics.JailMessagesRejectedTotal.Inc()
+				continue
+			}
+			if chunk, ok := parseChunk(payload); ok {
+				if out != nil {
+					out(chunk)
+				}
+				continue
+			}
+			res, index := e.parseResult(payload)
+			// Unindexed results (single runs, compile and setup errors) end the Job.
+			if index < 0 || index >= runs {
+				return fill(res)
+			}
+			if results[index].Status.ID == 0 {
+				results[index] = res
+				pending--
+			}
+			if pending == 0 {
+				return results
+			}
+		case <-waitCtx.Done():
+			// Cancelled by the caller or a runner shutdown: remove the Job or
+			// pod before returning so it does not outlive the runner.
+			if ctx.Err() != nil {
+				cleanup()
+				return fill(cancelledResult())
+			}
+			// Timeout — try to determine if OOM or generic timeout.
+			result := e.handleTimeout(timeoutPods)
+			// Background cleanup.
+			go cleanup()
+			return fill(result)
+		}
 	}
 }
 
-func (e *K8sExecutor) buildJobSpec(jobName, execID string, lang Language, req ExecuteRequest, timeoutMs int) *batchv1.Job {
-	// activeDeadlineSeconds = execution timeout + 5s buffer for startup.
-	deadlineSec := int64(math.Ceil(float64(timeoutMs)/1000.0)) + 5
-	ttlSec := int32(60)
-	backoffLimit := int32(0)
-
-	sourceB64 := base64.StdEncoding.EncodeToString([]byte(req.SourceCode))
-	stdinB64 := ""
-	if req.Stdin != "" {
-		stdinB64 = base64.StdEncoding.EncodeToString([]byte(req.Stdin))
+// parseChunk decodes a streamed output message; ok is false for anything else.
+func parseChunk(payload string) (OutputChunk, bool) {
+	var c jailChunk
+	if err := json.Unmarshal([]byte(payload), &c); err != nil || c.Type != "chunk" {
+		return OutputChunk{}, false
 	}
+	data, _ := base64.StdEncoding.DecodeString(c.DataB64)
+	return OutputChunk{Stream: c.Stream, Data: string(data), ElapsedMs: c.ElapsedMs}, true
+}
 
-	// Resource limits per language.
-	memLimit := "128Mi"
-	if lang.ID == 63 { // JavaScript/Node needs more memory
-		memLimit = "256Mi"
+// feedStdin pushes each value received from input to the jail's stdin list
+// as a signed "<seq> <base64>" message, and "<seq> -" once input is closed.
+// The sequence number keeps a replayed message from being read twice. The
+// list is deleted when ctx is done.
+func (e *K8sExecutor) feedStdin(ctx context.Context, execID, execKey string, input <-chan []byte, ttl time.Duration) {
+	key := stdinKey(execID)
+	defer e.redisClient.Del(context.Background(), key)
+
+	for seq := 0; ; seq++ {
+		var data []byte
+		var ok bool
+		select {
+		case data, ok = <-input:
+		case <-ctx.Done():
+			return
+		}
+		body := fmt.Sprintf("%d -", seq)
+		if ok {
+			body = fmt.Sprintf("%d %s", seq, base64.StdEncoding.EncodeToString(data))
+		}
+		pipe := e.redisClient.TxPipeline()
+		pipe.LPush(ctx, key, signMessage(execKey, body))
+		pipe.Expire(ctx, key, ttl)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("failed to send session input for %s: %v", execID, err)
+			return
+		}
+		if !ok {
+			// Keep the list until the jail has read the end of input.
+			<-ctx.Done()
+			return
+		}
 	}
+}
+
+func (e *K8sExecutor) buildJobSpec(jobName, execID string, lang Language, env []corev1.EnvVar, runs, timeoutMs int) *batchv1.Job {
+	// activeDeadlineSeconds = compile + execution timeout per run + 5s buffer for startup.
+	deadlineSec := int64(math.Ceil(float64(lang.CompileTimeoutMs+runs*timeoutMs)/1000.0)) + 5
+	ttlSec := int32(60)
+	backoffLimit := int32(0)
 
 	return &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      jobName,
 			Namespace: e.namespace,
 			Labels: map[string]string{
-				"app":     "donfra-jail",
-				"exec-id": execID[:8],
+				"app":             "donfra-jail",
+				"exec-id":         execID[:8],
+				"runner-instance": e.instanceID,
 			},
 		},
 		Spec: batchv1.JobSpec{
@@ -135,37 +338,15 @@ func (e *K8sExecutor) buildJobSpec(jobName, execID string, lang Language, req Ex
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
-						"app":     "donfra-jail",
-						"exec-id": execID[:8],
+						"app":             "donfra-jail",
+						"exec-id":         execID[:8],
+						"runner-instance": e.instanceID,
 					},
 				},
 				Spec: corev1.PodSpec{
 					RestartPolicy: corev1.RestartPolicyNever,
 					Containers: []corev1.Container{
-						{
-							Name:  "codejail",
-							Image: e.jailImage,
-							Env: []corev1.EnvVar{
-								{Name: "EXEC_ID", Value: execID},
-								{Name: "SOURCE_CODE", Value: sourceB64},
-								{Name: "LANGUAGE_ID", Value: fmt.Sprintf("%d", req.LanguageID)},
-								{Name: "STDIN_DATA", Value: stdinB64},
-								{Name: "REDIS_HOST", Value: "redis"},
-								{Name: "REDIS_PORT", Value: "6379"},
-								{Name: "TIMEOUT_MS", Value: fmt.Sprintf("%d", timeoutMs)},
-								{Name: "MAX_OUTPUT_BYTES", Value: fmt.Sprintf("%d", e.cfg.MaxOutputBytes)},
-							},
-							Resources: corev1.ResourceRequirements{
-								Requests: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse("100m"),
-									corev1.ResourceMemory: resource.MustParse("64Mi"),
-								},
-								Limits: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse("500m"),
-									corev1.ResourceMemory: resource.MustParse(memLimit),
-								},
-							},
-						},
+						e.jailContainer(lang, env),
 					},
 				},
 			},
@@ -173,44 +354,198 @@ func (e *K8sExecutor) buildJobSpec(jobName, execID string, lang Language, req Ex
 	}
 }
 
-func (e *K8sExecutor) parseResult(payload string) ExecuteResult {
+// jailEnv is the environment describing one execution to the jail entrypoint.
+// Job pods receive it as container env; warm pool pods receive it over Redis.
+// execKey signs the messages the jail publishes back, its payload, and in
+// interactive sessions the stdin messages it reads. redisPassword logs the
+// jail in as the execution's Redis user (see allowExecution).
+func (e *K8sExecutor) jailEnv(execID, execKey, redisPassword string, lang Language, req ExecuteRequest, timeoutMs int, stream, interactive bool) []corev1.EnvVar {
+	// Command templates are passed as JSON arrays; the jail expands the placeholders.
+	compileCmd, _ := json.Marshal(lang.CompileCmd)
+	runCmd, _ := json.Marshal(lang.RunCmd)
+
+	maxOutput := e.cfg.MaxOutputBytes
+	if interactive {
+		maxOutput = e.cfg.SessionMaxOutputBytes
+	}
+
+	// The container is sized for the compile step (see jailContainer); the
+	// jail holds the run step to MemoryMB itself.
+	runMemoryMB := 0
+	if lang.CompileMemoryMB > lang.MemoryMB {
+		runMemoryMB = lang.MemoryMB
+	}
+
+	return []corev1.EnvVar{
+		{Name: "EXEC_ID", Value: execID},
+		{Name: "RESULT_KEY", Value: execKey},
+		{Name: "PAYLOAD_KEY", Value: payloadKey(execID)},
+		{Name: "LANGUAGE_ID", Value: fmt.Sprintf("%d", req.LanguageID)},
+		{Name: "SOURCE_FILE", Value: lang.SourceFile},
+		{Name: "COMPILE_CMD", Value: string(compileCmd)},
+		{Name: "RUN_CMD", Value: string(runCmd)},
+		{Name: "COMPILE_TIMEOUT_MS", Value: fmt.Sprintf("%d", lang.CompileTimeoutMs)},
+		{Name: "RUN_MEMORY_MB", Value: fmt.Sprintf("%d", runMemoryMB)},
+		{Name: "REDIS_HOST", Value: e.redisHost},
+		{Name: "REDIS_PORT", Value: e.redisPort},
+		{Name: "REDIS_USER", Value: execRedisUser(execID)},
+		{Name: "REDIS_PASSWORD", Value: redisPassword},
+		{Name: "TIMEOUT_MS", Value: fmt.Sprintf("%d", timeoutMs)},
+		{Name: "MAX_OUTPUT_BYTES", Value: fmt.Sprintf("%d", maxOutput)},
+		{Name: "STREAM_OUTPUT", Value: fmt.Sprintf("%t", stream)},
+		{Name: "INTERACTIVE", Value: fmt.Sprintf("%t", interactive)},
+	}
+}
+
+func payloadKey(execID string) string {
+	return "payload:" + execID
+}
+
+// putPayload stores the inputs of an execution for the jail to fetch: a JSON
+// object with the variables SOURCE_CODE, STDIN_DATA, STDIN_BATCH and FILES
+// that older jails read from their environment, encrypted and signed with
+// execKey. It expires after ttl in case the runner goes away before deleting
+// it.
+func (e *K8sExecutor) putPayload(ctx context.Context, execID, execKey string, req ExecuteRequest, stdins []string, ttl time.Duration) error {
+	stdinB64 := ""
+	if req.Stdin != "" {
+		stdinB64 = base64.StdEncoding.EncodeToString([]byte(req.Stdin))
+	}
+
+	// Batch stdins are passed as a JSON array of base64 strings.
+	stdinBatch := ""
+	if len(stdins) > 0 {
+		encoded := make([]string, len(stdins))
+		for i, s := range stdins {
+			encoded[i] = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+		data, _ := json.Marshal(encoded)
+		stdinBatch = string(data)
+	}
+
+	// Project files are passed as a JSON array of {path, content_b64}.
+	files := ""
+	if len(req.Files) > 0 {
+		encoded := make([]jailFile, len(req.Files))
+		for i, f := range req.Files {
+			encoded[i] = jailFile{Path: f.Path, ContentB64: base64.StdEncoding.EncodeToString([]byte(f.Content))}
+		}
+		data, _ := json.Marshal(encoded)
+		files = string(data)
+	}
+
+	payload, _ := json.Marshal(map[string]string{
+		"SOURCE_CODE": base64.StdEncoding.EncodeToString([]byte(req.SourceCode)),
+		"STDIN_DATA":  stdinB64,
+		"STDIN_BATCH": stdinBatch,
+		"FILES":       files,
+	})
+	return e.redisClient.Set(ctx, payloadKey(execID), signMessage(execKey, sealMessage(execKey, string(payload))), ttl).Err()
+}
+
+// Jail CPU sizing for languages that do not set their own, in millicores.
+const (
+	defaultJailCPURequestMillis = 100
+	defaultJailCPULimitMillis   = 500
+)
+
+// jailContainer is the jail container for lang, sized for its compile and run budgets.
+func (e *K8sExecutor) jailContainer(lang Language, env []corev1.EnvVar) corev1.Container {
+	cpuLimit := cmp.Or(lang.CPULimitMillis, defaultJailCPULimitMillis)
+	cpuRequest := min(cmp.Or(lang.CPURequestMillis, defaultJailCPURequestMillis), cpuLimit)
+
+	limits := corev1.ResourceList{
+		corev1.ResourceCPU: *resource.NewMilliQuantity(int64(cpuLimit), resource.DecimalSI),
+	}
+	// The pod runs both the compile and run steps, so size it for the larger
+	// budget. A run step with a smaller one is held to it by the jail's
+	// RUN_MEMORY_MB.
+	if mb := max(lang.MemoryMB, lang.CompileMemoryMB); mb > 0 {
+		limits[corev1.ResourceMemory] = *resource.NewQuantity(int64(mb)<<20, resource.BinarySI)
+	}
+
+	// The entrypoint runs as the jail user and hands user code to the
+	// sandbox user through the setuid jail-rusage, which needs privilege
+	// escalation and only the capabilities to switch uid and signal.
+	jailUID := int64(1000)
+	escalate := true
+	security := &corev1.SecurityContext{
+		RunAsUser:                &jailUID,
+		AllowPrivilegeEscalation: &escalate,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+			Add:  []corev1.Capability{"SETUID", "SETGID", "KILL"},
+		},
+	}
+
+	return corev1.Container{
+		Name:            "codejail",
+		Image:           cmp.Or(lang.Image, e.jailImage),
+		Env:             env,
+		SecurityContext: security,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    *resource.NewMilliQuantity(int64(cpuRequest), resource.DecimalSI),
+				corev1.ResourceMemory: resource.MustParse("64Mi"),
+			},
+			Limits: limits,
+		},
+	}
+}
+
+// parseResult decodes a jail result and the batch index it belongs to, or -1
+// when it is not indexed.
+func (e *K8sExecutor) parseResult(payload string) (ExecuteResult, int) {
 	var sr jailResult
 	if err := json.Unmarshal([]byte(payload), &sr); err != nil {
 		log.Printf("failed to parse jail result: %v", err)
 		return ExecuteResult{
-			Token:   "ws-exec",
 			Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
 			Message: "failed to parse execution result",
-		}
+		}, -1
+	}
+
+	index := -1
+	if sr.Index != nil {
+		index = *sr.Index
 	}
 
 	stdout, _ := base64.StdEncoding.DecodeString(sr.StdoutB64)
 	stderr, _ := base64.StdEncoding.DecodeString(sr.StderrB64)
+	compileOutput, _ := base64.StdEncoding.DecodeString(sr.CompileOutB64)
 
 	return ExecuteResult{
-		Token:           "ws-exec",
 		Status:          ExecuteStatus{ID: sr.StatusID, Description: sr.StatusDesc},
 		Stdout:          string(stdout),
 		Stderr:          string(stderr),
 		Message:         sr.Message,
+		CompileOutput:   string(compileOutput),
 		ExecutionTimeMs: sr.ExecutionTimeMs,
-	}
+		Usage: Usage{
+			CPUUserMs:       sr.CPUUserMs,
+			CPUSysMs:        sr.CPUSysMs,
+			MemoryKB:        sr.MemoryKB,
+			ExitCode:        sr.ExitCode,
+			Signal:          sr.Signal,
+			StdoutTruncated: sr.StdoutTruncated,
+			StderrTruncated: sr.StderrTruncated,
+		},
+	}, index
 }
 
-// handleTimeout checks the Job/Pod status to distinguish OOM from generic timeout.
-func (e *K8sExecutor) handleTimeout(execID string) ExecuteResult {
+// handleTimeout checks the status of the pod selected by opts to distinguish
+// OOM from generic timeout.
+func (e *K8sExecutor) handleTimeout(opts metav1.ListOptions) ExecuteResult {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	// Check pod status for OOM kill.
-	pods, err := e.kubeClient.CoreV1().Pods(e.namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("exec-id=%s", execID[:8]),
-	})
+	pods, err := e.kubeClient.CoreV1().Pods(e.namespace).List(ctx, opts)
 	if err == nil && len(pods.Items) > 0 {
 		for _, cs := range pods.Items[0].Status.ContainerStatuses {
 			if cs.State.Terminated != nil && cs.State.Terminated.Reason == "OOMKilled" {
+				metrics.RecordK8sJobTimeout(true)
 				return ExecuteResult{
-					Token:   "ws-exec",
 					Status:  ExecuteStatus{ID: StatusMemoryLimit, Description: "Memory Limit Exceeded"},
 					Message: "process was killed due to memory limit",
 				}
@@ -218,8 +553,8 @@ func (e *K8sExecutor) handleTimeout(execID string) ExecuteResult {
 		}
 	}
 
+	metrics.RecordK8sJobTimeout(false)
 	return ExecuteResult{
-		Token:   "ws-exec",
 		Status:  ExecuteStatus{ID: StatusTimeLimitExceeded, Description: "Time Limit Exceeded"},
 		Message: "execution timed out",
 	}
@@ -237,3 +572,14 @@ func (e *K8sExecutor) deleteJob(jobName string) {
 		log.Printf("failed to delete job %s: %v", jobName, err)
 	}
 }
+
+func (e *K8sExecutor) deletePod(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := e.kubeClient.CoreV1().Pods(e.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		log.Printf("failed to delete pod %s: %v", name, err)
+	}
+	e.deleteRedisUser(poolRedisUser(name))
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// orphanGrace is added to a Job's active deadline before it counts as
+// orphaned, covering the runner's own startup buffer.
+const orphanGrace = 30 * time.Second
+
+// StartGC removes orphaned jail Jobs and pods now and then every interval
+// until ctx is cancelled. A non-positive interval only runs the startup pass.
+func (e *K8sExecutor) StartGC(ctx context.Context, interval time.Duration) {
+	go func() {
+		e.collectOrphans(ctx)
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.collectOrphans(ctx)
+			}
+		}
+	}()
+}
+
+// collectOrphans deletes app=donfra-jail objects no runner can still be
+// waiting on: Jobs past their active deadline (left behind by a runner that
+// crashed or was killed before it could clean up), finished pool pods, and
+// this instance's unclaimed pool pods of a language it keeps no pool for.
+// Live executions of other replicas are never past their deadline, and their
+// pool pods are left to them; pods of a crashed replica exit once idle and
+// are then collected as finished.
+func (e *K8sExecutor) collectOrphans(ctx context.Context) {
+	now := time.Now()
+
+	jobs, err := e.kubeClient.BatchV1().Jobs(e.namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=donfra-jail"})
+	if err != nil {
+		log.Printf("[gc] list jail jobs failed: %v", err)
+		return
+	}
+	deletedJobs := 0
+	for _, job := range jobs.Items {
+		deadline := orphanGrace
+		if job.Spec.ActiveDeadlineSeconds != nil {
+			deadline += time.Duration(*job.Spec.ActiveDeadlineSeconds) * time.Second
+		}
+		if job.DeletionTimestamp == nil && now.Sub(job.CreationTimestamp.Time) > deadline {
+			e.deleteJob(job.Name)
+			deletedJobs++
+		}
+	}
+
+	pods, err := e.kubeClient.CoreV1().Pods(e.namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=donfra-jail,pool-language"})
+	if err != nil {
+		log.Printf("[gc] list pool pods failed: %v", err)
+		return
+	}
+	deletedPods := 0
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		finished := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+		langID, _ := strconv.Atoi(pod.Labels["pool-language"])
+		unmanaged := pod.Labels["runner-instance"] == e.instanceID &&
+			(e.pool == nil || e.pool.cfg.Sizes[langID] <= 0) &&
+			!e.poolPodClaimed(ctx, &pod, langID)
+		if finished || unmanaged {
+			e.deletePod(pod.Name)
+			deletedPods++
+		}
+	}
+
+	if deletedJobs+deletedPods > 0 {
+		log.Printf("[gc] deleted %d orphaned jail jobs and %d pool pods", deletedJobs, deletedPods)
+	}
+	e.collectRedisUsers(ctx)
+}
+
+// poolPodClaimed reports whether pod may be serving an execution: it is
+// Running but no longer in its language's idle set, because dispatch claimed
+// it and queued a job for it. Lookup failures count as claimed.
+func (e *K8sExecutor) poolPodClaimed(ctx context.Context, pod *corev1.Pod, langID int) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	queued, err := e.redisClient.Exists(ctx, poolQueueKey(pod.Name)).Result()
+	if err != nil || queued > 0 {
+		return true
+	}
+	idle, err := e.redisClient.SIsMember(ctx, poolIdleKey(langID), pod.Name).Result()
+	return err != nil || !idle
+}
@@ -0,0 +1,232 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"donfra-runner/internal/metrics"
+)
+
+// poolReplenishInterval is how often each language's pool is topped up.
+const poolReplenishInterval = 2 * time.Second
+
+// poolQueueTTL bounds how long an undelivered job stays queued for a pod that died.
+const poolQueueTTL = time.Minute
+
+// PoolConfig configures the warm pod pool of the K8s executor.
+type PoolConfig struct {
+	// Sizes is the number of pool pods kept alive per language ID. Languages
+	// without an entry always run as one Job per execution.
+	Sizes map[int]int
+	// TrustedMaxExecutions is how many executions a pod may serve before it is
+	// recycled, as long as every one of them is trusted. Untrusted code retires
+	// the pod after its run.
+	TrustedMaxExecutions int
+	// IdleTimeoutSec is how long an idle pod waits for work before exiting.
+	IdleTimeoutSec int
+}
+
+// warmPool keeps pre-created jail pods waiting for work. Each idle pod
+// registers itself in a per-language Redis set and blocks on its own queue;
+// dispatching pops a pod from the set and pushes the signed execution env to it.
+type warmPool struct {
+	e   *K8sExecutor
+	cfg PoolConfig
+}
+
+func poolIdleKey(langID int) string {
+	return fmt.Sprintf("jail:pool:idle:%d", langID)
+}
+
+func poolQueueKey(podName string) string {
+	return "jail:pool:queue:" + podName
+}
+
+// StartPool enables the warm pool and replenishes it in the background until
+// ctx is cancelled. It must be called before the executor is used.
+func (e *K8sExecutor) StartPool(ctx context.Context, cfg PoolConfig) {
+	if cfg.TrustedMaxExecutions < 1 {
+		cfg.TrustedMaxExecutions = 1
+	}
+	if cfg.IdleTimeoutSec <= 0 {
+		cfg.IdleTimeoutSec = 600
+	}
+	p := &warmPool{e: e, cfg: cfg}
+	e.pool = p
+
+	for langID, size := range cfg.Sizes {
+		lang, err := GetLanguage(langID)
+		if err != nil || size <= 0 {
+			log.Printf("[pool] ignoring pool size for language %d", langID)
+			continue
+		}
+		go p.run(ctx, lang, size)
+	}
+}
+
+// dispatch hands env to an idle pod for langID and returns its name. ok is
+// false when the pool is disabled, has no idle pod, or the hand-off failed;
+// the caller then falls back to a Job.
+func (p *warmPool) dispatch(ctx context.Context, langID int, trusted bool, env []corev1.EnvVar) (podName string, ok bool) {
+	if p == nil || p.cfg.Sizes[langID] <= 0 {
+		return "", false
+	}
+
+	podName, err := p.e.redisClient.SPop(ctx, poolIdleKey(langID)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("[pool] claim failed: %v", err)
+		}
+		metrics.PoolDispatchTotal.WithLabelValues("fallback").Inc()
+		return "", false
+	}
+
+	job := make(map[string]string, len(env)+1)
+	for _, v := range env {
+		job[v.Name] = v.Value
+	}
+	job["POOL_RETIRE"] = strconv.FormatBool(!trusted)
+	payload, _ := json.Marshal(job)
+
+	queue := poolQueueKey(podName)
+	pipe := p.e.redisClient.TxPipeline()
+	pipe.LPush(ctx, queue, signMessage(p.podKey(podName), string(payload)))
+	pipe.Expire(ctx, queue, poolQueueTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[pool] dispatch to %s failed: %v", podName, err)
+		go p.e.deletePod(podName)
+		metrics.PoolDispatchTotal.WithLabelValues("fallback").Inc()
+		return "", false
+	}
+
+	metrics.PoolDispatchTotal.WithLabelValues("warm").Inc()
+	return podName, true
+}
+
+// run keeps size pods alive for lang until ctx is cancelled.
+func (p *warmPool) run(ctx context.Context, lang Language, size int) {
+	log.Printf("[pool] keeping %d warm pods for %s", size, lang.Name)
+
+	ticker := time.NewTicker(poolReplenishInterval)
+	defer ticker.Stop()
+
+	for {
+		// Pick up catalog reloads; pods already running keep their old spec
+		// until they are recycled.
+		if l, err := GetLanguage(lang.ID); err == nil {
+			lang = l
+		}
+		if err := p.replenish(ctx, lang, size); err != nil && ctx.Err() == nil {
+			log.Printf("[pool] replenish %s failed: %v", lang.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replenish deletes finished pool pods, drops idle entries for pods that no
+// longer exist and creates pods until size are alive.
+func (p *warmPool) replenish(ctx context.Context, lang Language, size int) error {
+	pods, err := p.e.kubeClient.CoreV1().Pods(p.e.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=donfra-jail,pool-language=%d", lang.ID),
+	})
+	if err != nil {
+		return err
+	}
+
+	alive := make(map[string]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		switch {
+		case pod.DeletionTimestamp != nil:
+		case pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed:
+			go p.e.deletePod(pod.Name)
+		case pod.Labels["signing-key-id"] != p.keyID():
+			// Created under a different signing secret; it would reject our jobs.
+			go p.e.deletePod(pod.Name)
+		default:
+			alive[pod.Name] = true
+		}
+	}
+
+	idle, err := p.e.redisClient.SMembers(ctx, poolIdleKey(lang.ID)).Result()
+	if err != nil {
+		return err
+	}
+	for _, name := range idle {
+		if !alive[name] {
+			p.e.redisClient.SRem(ctx, poolIdleKey(lang.ID), name)
+		}
+	}
+
+	for i := len(alive); i < size; i++ {
+		pod, err := p.e.kubeClient.CoreV1().Pods(p.e.namespace).Create(ctx, p.buildPodSpec(lang), metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		// The user is created after the pod; see collectRedisUsers.
+		if err := p.allowPoolPod(ctx, lang.ID, pod.Name); err != nil {
+			go p.e.deletePod(pod.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *warmPool) buildPodSpec(lang Language) *corev1.Pod {
+	name := fmt.Sprintf("jail-pool-%d-%s", lang.ID, uuid.New().String()[:8])
+
+	env := []corev1.EnvVar{
+		{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+		}},
+		{Name: "POOL_IDLE_SET", Value: poolIdleKey(lang.ID)},
+		{Name: "POOL_QUEUE", Value: poolQueueKey(name)},
+		{Name: "POOL_KEY", Value: p.podKey(name)},
+		{Name: "POOL_MAX_EXECUTIONS", Value: strconv.Itoa(p.cfg.TrustedMaxExecutions)},
+		{Name: "POOL_IDLE_TIMEOUT_SEC", Value: strconv.Itoa(p.cfg.IdleTimeoutSec)},
+		{Name: "REDIS_HOST", Value: p.e.redisHost},
+		{Name: "REDIS_PORT", Value: p.e.redisPort},
+		{Name: "REDIS_USER", Value: poolRedisUser(name)},
+		{Name: "REDIS_PASSWORD", Value: p.podRedisPassword(name)},
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: p.e.namespace,
+			Labels: map[string]string{
+				"app":             "donfra-jail",
+				"pool-language":   strconv.Itoa(lang.ID),
+				"signing-key-id":  p.keyID(),
+				"runner-instance": p.e.instanceID,
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{p.e.jailContainer(lang, env)},
+		},
+	}
+}
+
+// podKey is the key a pool pod uses to verify the jobs pushed to it.
+func (p *warmPool) podKey(podName string) string {
+	return macHex(p.e.signingSecret, "pool-pod:"+podName)
+}
+
+// keyID identifies the signing secret without revealing it.
+func (p *warmPool) keyID() string {
+	return macHex(p.e.signingSecret, "key-id")[:16]
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// languageFile is the layout of a languages file (YAML or JSON):
+//
+//	languages:
+//	  - id: 71
+//	    name: Python
+//	    source_file: main.py
+//	    run_cmd: ["/usr/bin/python3", "{source}"]
+//	    memory_mb: 128
+type languageFile struct {
+	Languages []Language `json:"languages"`
+}
+
+// LoadLanguagesFile reads the catalog at path and makes it active. On error
+// the current catalog is kept.
+func LoadLanguagesFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return loadLanguages(path, data)
+}
+
+func loadLanguages(path string, data []byte) error {
+	var f languageFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := SetLanguages(f.Languages); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// WatchLanguagesFile reloads the catalog at path whenever its content
+// changes, checking every interval until ctx is cancelled. Polling the content
+// rather than the mtime also catches ConfigMap updates, which swap a symlink.
+// An invalid file is logged and the previous catalog stays active.
+func WatchLanguagesFile(ctx context.Context, path string, interval time.Duration) {
+	last, _ := os.ReadFile(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[runner] languages reload failed: %v", err)
+			continue
+		}
+		if bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		if err := loadLanguages(path, data); err != nil {
+			log.Printf("[runner] languages reload failed, keeping previous catalog: %v", err)
+			continue
+		}
+		log.Printf("[runner] languages reloaded from %s (%d languages)", path, len(Languages()))
+	}
+}
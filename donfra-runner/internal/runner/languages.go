@@ -1,31 +1,287 @@
 package runner
 
-import "fmt"
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
 
+// Language describes how to build and run a program.
+//
+// CompileCmd and RunCmd are argv templates. The placeholders {dir}, {source}
+// and {binary} are replaced with the working directory, the source file path
+// and the compiled output path. Languages without a CompileCmd are interpreted.
+//
+// Zero limits fall back to the runner-wide defaults: DEFAULT_TIMEOUT_MS and
+// MAX_TIMEOUT_MS for timeouts, the jail mode's CPU share, and JAIL_IMAGE.
 type Language struct {
-	ID          int
-	Name        string
-	Interpreter string
-	Extension   string
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	Version    string   `json:"version,omitempty"`
+	SourceFile string   `json:"source_file"`
+	Extension  string   `json:"extension,omitempty"` // defaults to SourceFile's
+	CompileCmd []string `json:"compile_cmd,omitempty"`
+	RunCmd     []string `json:"run_cmd"`
+
+	// ReplCmd starts the language's interactive interpreter with {source}
+	// loaded, for REPL sessions. Languages without one only run programs.
+	ReplCmd []string `json:"repl_cmd,omitempty"`
+
+	DefaultTimeoutMs int `json:"default_timeout_ms,omitempty"`
+	MaxTimeoutMs     int `json:"max_timeout_ms,omitempty"`
+
+	// CompileTimeoutMs and CompileMemoryMB budget the compile step separately
+	// from the run step, which uses the request timeout and MemoryMB.
+	CompileTimeoutMs int `json:"compile_timeout_ms,omitempty"`
+	CompileMemoryMB  int `json:"compile_memory_mb,omitempty"`
+	MemoryMB         int `json:"memory_mb,omitempty"`
+
+	// CPURequestMillis and CPULimitMillis size the jail in millicores. The
+	// sandbox only applies the limit.
+	CPURequestMillis int `json:"cpu_request_millis,omitempty"`
+	CPULimitMillis   int `json:"cpu_limit_millis,omitempty"`
+
+	// Image overrides the K8s jail image for this language.
+	Image string `json:"image,omitempty"`
+
+	// Visualize marks a CPython 3 language, which can run under the step
+	// tracer in visualize mode.
+	Visualize bool `json:"visualize,omitempty"`
+
+	// TestHarness names the harness that runs unit_tests mode: "python"
+	// (unittest and pytest-style tests) or "node" (node:test-style tests).
+	TestHarness string `json:"test_harness,omitempty"`
+
+	// Database marks a SQL language and names the engine its programs run
+	// against: "sqlite" (in-memory SQLite through Python's sqlite3 module,
+	// so RunCmd must be CPython 3).
+	Database string `json:"database,omitempty"`
 }
 
-var languages = map[int]Language{
-	71: {ID: 71, Name: "Python", Interpreter: "/usr/bin/python3", Extension: ".py"},
-	63: {ID: 63, Name: "JavaScript", Interpreter: "/usr/bin/node", Extension: ".js"},
+// defaultLanguages is the catalog used when no languages file is configured.
+// It matches languages.yaml and the toolchains in the jail image.
+var defaultLanguages = []Language{
+	{
+		ID: 71, Name: "Python", Version: "3.12", SourceFile: "main.py",
+		RunCmd:   []string{"/usr/bin/python3", "{source}"},
+		ReplCmd:  []string{"/usr/bin/python3", "-i", "-q", "{source}"},
+		MemoryMB: 128, Visualize: true, TestHarness: "python",
+	},
+	{
+		ID: 63, Name: "JavaScript", Version: "Node.js 20", SourceFile: "main.js",
+		RunCmd:   []string{"/usr/bin/node", "{source}"},
+		ReplCmd:  []string{"/usr/bin/node", "-i", "-r", "{source}"},
+		MemoryMB: 256, TestHarness: "node",
+	},
+	{
+		ID: 60, Name: "Go", Version: "1.22", SourceFile: "main.go",
+		CompileCmd:       []string{"go", "build", "-o", "{binary}", "{source}"},
+		RunCmd:           []string{"{binary}"},
+		CompileTimeoutMs: 15000, CompileMemoryMB: 1024, MemoryMB: 256,
+	},
+	{
+		ID: 50, Name: "C", Version: "GCC 13, C17", SourceFile: "main.c",
+		CompileCmd:       []string{"gcc", "-O2", "-std=c17", "-o", "{binary}", "{source}", "-lm"},
+		RunCmd:           []string{"{binary}"},
+		CompileTimeoutMs: 10000, CompileMemoryMB: 512, MemoryMB: 128,
+	},
+	{
+		ID: 54, Name: "C++", Version: "GCC 13, C++17", SourceFile: "main.cpp",
+		CompileCmd:       []string{"g++", "-O2", "-std=c++17", "-o", "{binary}", "{source}"},
+		RunCmd:           []string{"{binary}"},
+		CompileTimeoutMs: 15000, CompileMemoryMB: 1024, MemoryMB: 128,
+	},
+	{
+		ID: 62, Name: "Java", Version: "OpenJDK 17", SourceFile: "Main.java",
+		CompileCmd:       []string{"javac", "-d", "{dir}", "{source}"},
+		RunCmd:           []string{"java", "-Xmx256m", "-Xss64m", "-cp", "{dir}", "Main"},
+		CompileTimeoutMs: 15000, CompileMemoryMB: 1024, MemoryMB: 512,
+	},
+	{
+		ID: 73, Name: "Rust", Version: "1.78", SourceFile: "main.rs",
+		CompileCmd:       []string{"rustc", "-O", "-o", "{binary}", "{source}"},
+		RunCmd:           []string{"{binary}"},
+		CompileTimeoutMs: 20000, CompileMemoryMB: 1024, MemoryMB: 128,
+	},
+	{
+		ID: 82, Name: "SQL", Version: "SQLite 3", SourceFile: "main.sql",
+		RunCmd:   []string{"/usr/bin/python3", "{source}"},
+		MemoryMB: 128, Database: "sqlite",
+	},
 }
 
+// languages is the active catalog. It is replaced wholesale on reload so
+// readers always see a consistent set.
+var languages atomic.Pointer[map[int]Language]
+
+func init() {
+	if err := SetLanguages(defaultLanguages); err != nil {
+		panic(err)
+	}
+}
+
+// SetLanguages validates langs and makes them the active catalog.
+func SetLanguages(langs []Language) error {
+	catalog := make(map[int]Language, len(langs))
+	for _, lang := range langs {
+		lang, err := lang.normalize()
+		if err != nil {
+			return err
+		}
+		if _, dup := catalog[lang.ID]; dup {
+			return fmt.Errorf("language %d is defined twice", lang.ID)
+		}
+		catalog[lang.ID] = lang
+	}
+	if len(catalog) == 0 {
+		return errors.New("no languages defined")
+	}
+	languages.Store(&catalog)
+	return nil
+}
+
+// normalize checks a language definition and fills in derived defaults.
+func (l Language) normalize() (Language, error) {
+	switch {
+	case l.ID <= 0:
+		return l, fmt.Errorf("language %q: id must be positive", l.Name)
+	case l.Name == "":
+		return l, fmt.Errorf("language %d: name is required", l.ID)
+	case l.SourceFile == "" || l.SourceFile != filepath.Base(l.SourceFile):
+		return l, fmt.Errorf("language %d: source_file must be a plain file name", l.ID)
+	case len(l.RunCmd) == 0:
+		return l, fmt.Errorf("language %d: run_cmd is required", l.ID)
+	case l.MemoryMB < 0 || l.CompileMemoryMB < 0 || l.CPURequestMillis < 0 || l.CPULimitMillis < 0:
+		return l, fmt.Errorf("language %d: limits must not be negative", l.ID)
+	case l.DefaultTimeoutMs < 0 || l.MaxTimeoutMs < 0 || l.CompileTimeoutMs < 0:
+		return l, fmt.Errorf("language %d: timeouts must not be negative", l.ID)
+	case l.MaxTimeoutMs > 0 && l.DefaultTimeoutMs > l.MaxTimeoutMs:
+		return l, fmt.Errorf("language %d: default_timeout_ms exceeds max_timeout_ms", l.ID)
+	case l.CPULimitMillis > 0 && l.CPURequestMillis > l.CPULimitMillis:
+		return l, fmt.Errorf("language %d: cpu_request_millis exceeds cpu_limit_millis", l.ID)
+	case l.TestHarness != "" && testHarnesses[l.TestHarness] == "":
+		return l, fmt.Errorf("language %d: unknown test_harness %q", l.ID, l.TestHarness)
+	case l.Database != "" && sqlHarnesses[l.Database] == "":
+		return l, fmt.Errorf("language %d: unknown database %q", l.ID, l.Database)
+	}
+
+	if l.Extension == "" {
+		l.Extension = filepath.Ext(l.SourceFile)
+	}
+	if l.Compiled() {
+		l.CompileTimeoutMs = cmp.Or(l.CompileTimeoutMs, defaultCompileTimeoutMs)
+	}
+	return l, nil
+}
+
+// defaultCompileTimeoutMs budgets compile steps that do not set their own.
+const defaultCompileTimeoutMs = 10000
+
 func GetLanguage(id int) (Language, error) {
-	lang, ok := languages[id]
+	lang, ok := (*languages.Load())[id]
 	if !ok {
 		return Language{}, fmt.Errorf("unsupported language_id: %d", id)
 	}
 	return lang, nil
 }
 
+// Languages returns the active catalog ordered by ID.
+func Languages() []Language {
+	catalog := *languages.Load()
+	langs := make([]Language, 0, len(catalog))
+	for _, lang := range catalog {
+		langs = append(langs, lang)
+	}
+	slices.SortFunc(langs, func(a, b Language) int { return a.ID - b.ID })
+	return langs
+}
+
 func SupportedLanguageIDs() []int {
-	ids := make([]int, 0, len(languages))
-	for id := range languages {
-		ids = append(ids, id)
+	langs := Languages()
+	ids := make([]int, len(langs))
+	for i, lang := range langs {
+		ids[i] = lang.ID
 	}
 	return ids
 }
+
+// Compiled reports whether the language has a compile step.
+func (l Language) Compiled() bool {
+	return len(l.CompileCmd) > 0
+}
+
+// expandCmd substitutes the {dir}, {source} and {binary} placeholders in an argv template.
+func expandCmd(tmpl []string, dir, source, binary string) []string {
+	r := strings.NewReplacer("{dir}", dir, "{source}", source, "{binary}", binary)
+	argv := make([]string, len(tmpl))
+	for i, arg := range tmpl {
+		argv[i] = r.Replace(arg)
+	}
+	return argv
+}
+
+// withLimits wraps argv in a shell that caps the process data segment at mb
+// megabytes and its CPU time at cpuMs (rounded up to whole seconds, plus one
+// as in the sandbox). Zero leaves a limit unset.
+func withLimits(argv []string, mb, cpuMs int) []string {
+	var script string
+	if mb > 0 {
+		script += "ulimit -d " + strconv.Itoa(mb*1024) + " && "
+	}
+	if cpuMs > 0 {
+		script += "ulimit -t " + strconv.Itoa((cpuMs+999)/1000+1) + " && "
+	}
+	if script == "" {
+		return argv
+	}
+	script += `exec "$@"`
+	return append([]string{"/bin/sh", "-c", script, "sh"}, argv...)
+}
+
+// LanguageInfo is the public description of a language, with the runner-wide
+// defaults applied.
+type LanguageInfo struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	Version          string `json:"version,omitempty"`
+	SourceFile       string `json:"source_file"`
+	Extension        string `json:"extension"`
+	Compiled         bool   `json:"compiled"`
+	DefaultTimeoutMs int    `json:"default_timeout_ms"`
+	MaxTimeoutMs     int    `json:"max_timeout_ms"`
+	MemoryMB         int    `json:"memory_mb,omitempty"`
+	Visualize        bool   `json:"visualize,omitempty"`
+	TestHarness      string `json:"test_harness,omitempty"`
+	Database         string `json:"database,omitempty"`
+	REPL             bool   `json:"repl,omitempty"`
+}
+
+// Languages describes the active catalog ordered by ID.
+func (r *Runner) Languages() []LanguageInfo {
+	langs := Languages()
+	infos := make([]LanguageInfo, len(langs))
+	for i, lang := range langs {
+		maxTimeout := cmp.Or(lang.MaxTimeoutMs, r.cfg.MaxTimeoutMs)
+		infos[i] = LanguageInfo{
+			ID:               lang.ID,
+			Name:             lang.Name,
+			Version:          lang.Version,
+			SourceFile:       lang.SourceFile,
+			Extension:        lang.Extension,
+			Compiled:         lang.Compiled(),
+			DefaultTimeoutMs: min(cmp.Or(lang.DefaultTimeoutMs, r.cfg.DefaultTimeout), maxTimeout),
+			MaxTimeoutMs:     maxTimeout,
+			MemoryMB:         lang.MemoryMB,
+			Visualize:        lang.Visualize,
+			TestHarness:      lang.TestHarness,
+			Database:         lang.Database,
+			REPL:             len(lang.ReplCmd) > 0,
+		}
+	}
+	return infos
+}
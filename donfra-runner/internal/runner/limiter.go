@@ -2,49 +2,360 @@ package runner
 
 import (
 	"context"
-	"sync/atomic"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
-// Limiter controls concurrent execution slots using a semaphore pattern.
+// Priority is the scheduling class of an execution. Every waiting execution
+// of a higher class is started before any of a lower one.
+type Priority string
+
+const (
+	// PriorityPractice is the default class, for lessons and the code pad.
+	PriorityPractice Priority = ""
+	// PriorityInterview is for runs in interview rooms, where both sides wait
+	// on the result.
+	PriorityInterview Priority = "interview"
+)
+
+// priorities lists the classes from highest to lowest.
+var priorities = []Priority{PriorityInterview, PriorityPractice}
+
+// level is the index of p in priorities.
+func (p Priority) level() (int, error) {
+	for i, q := range priorities {
+		if p == q {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown priority: %q", p)
+}
+
+// ValidatePriority checks that p is a known priority.
+func ValidatePriority(p Priority) error {
+	_, err := p.level()
+	return err
+}
+
+// Caller is who an execution runs for. Waiting executions of one class take
+// turns between callers, so a burst from one caller does not hold up others.
+type Caller struct {
+	ID       string
+	Priority Priority
+}
+
+// QueueStatus is where a waiting execution stands.
+type QueueStatus struct {
+	// Position is 1 for the next execution to start. It is 0 once the
+	// execution holds a slot.
+	Position        int   `json:"position"`
+	EstimatedWaitMs int64 `json:"estimated_wait_ms"`
+}
+
+// ErrQueueFull is returned by Acquire when the queue, or the caller's share
+// of it, is full.
+var ErrQueueFull = errors.New("queue full")
+
+type queueReporterKey struct{}
+
+// WithQueueReporter returns a context under which executions report their
+// queue status to fn as they wait for a slot (see Limiter.Acquire).
+func WithQueueReporter(ctx context.Context, fn func(QueueStatus)) context.Context {
+	return context.WithValue(ctx, queueReporterKey{}, fn)
+}
+
+func queueReporter(ctx context.Context) func(QueueStatus) {
+	fn, _ := ctx.Value(queueReporterKey{}).(func(QueueStatus))
+	return fn
+}
+
+// initialSlotHold is the assumed time an execution holds a slot until the
+// limiter has seen some, for wait estimates.
+const initialSlotHold = time.Second
+
+// Limiter hands out execution slots. When all are in use, executions wait in
+// a queue per caller and class: slots go to the highest class with waiters,
+// and within it to its callers in turn. The queue holds at most maxQueue
+// executions and maxPerCaller of any one caller; more are rejected at once.
 type Limiter struct {
-	sem     chan struct{}
-	max     int
-	queued  atomic.Int64
+	max          int
+	maxQueue     int // 0 means unbounded
+	maxPerCaller int // 0 means unbounded
+
+	mu      sync.Mutex
+	inUse   int
+	queued  int
+	levels  [][]*callerQueue // per class, callers in turn order
+	callers map[Caller]*callerQueue
+
+	// turn estimates the time between slots freeing up while executions
+	// wait, from releases since busySince.
+	turn        time.Duration
+	busySince   time.Time
+	lastRelease time.Time
+}
+
+// callerQueue holds the waiting executions of one caller and class.
+type callerQueue struct {
+	caller  Caller
+	level   int
+	waiters []*waiter
 }
 
-func NewLimiter(maxConcurrent int) *Limiter {
+type waiter struct {
+	cq      *callerQueue
+	granted bool
+	ready   chan struct{} // closed when the slot is granted
+	moved   chan struct{} // signalled when the position may have changed
+}
+
+func NewLimiter(maxConcurrent, maxQueue, maxPerCaller int) *Limiter {
 	return &Limiter{
-		sem: make(chan struct{}, maxConcurrent),
-		max: maxConcurrent,
+		max:          maxConcurrent,
+		maxQueue:     maxQueue,
+		maxPerCaller: maxPerCaller,
+		levels:       make([][]*callerQueue, len(priorities)),
+		callers:      make(map[Caller]*callerQueue),
+		turn:         initialSlotHold / time.Duration(max(maxConcurrent, 1)),
 	}
 }
 
-// Acquire blocks until a slot is available or ctx is cancelled.
-// Returns nil on success, ctx.Err() on timeout/cancel.
-func (l *Limiter) Acquire(ctx context.Context) error {
-	l.queued.Add(1)
-	defer l.queued.Add(-1)
+// Acquire takes a slot for caller, waiting in the queue until one is free or
+// ctx is done. It returns ErrQueueFull without waiting when the queue is
+// full, and ctx.Err() when ctx ends first. report, if non-nil, receives the
+// execution's queue status whenever it changes while it waits, and a zero
+// status once it holds the slot.
+func (l *Limiter) Acquire(ctx context.Context, caller Caller, report func(QueueStatus)) error {
+	level, err := caller.Priority.level()
+	if err != nil {
+		return err
+	}
 
-	select {
-	case l.sem <- struct{}{}:
+	l.mu.Lock()
+	if l.inUse < l.max && l.queued == 0 {
+		l.inUse++
+		l.mu.Unlock()
+		if report != nil {
+			report(QueueStatus{})
+		}
 		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+	}
+	if l.maxQueue > 0 && l.queued >= l.maxQueue {
+		l.mu.Unlock()
+		return ErrQueueFull
+	}
+	if cq := l.callers[caller]; cq != nil && l.maxPerCaller > 0 && len(cq.waiters) >= l.maxPerCaller {
+		l.mu.Unlock()
+		return ErrQueueFull
+	}
+	w := l.enqueueLocked(caller, level)
+	l.notifyLocked()
+	l.mu.Unlock()
+
+	last := 0
+	for {
+		if report != nil {
+			if status, waiting := l.status(w); waiting && status.Position != last {
+				last = status.Position
+				report(status)
+			}
+		}
+		select {
+		case <-w.ready:
+			if report != nil {
+				report(QueueStatus{})
+			}
+			return nil
+		case <-w.moved:
+		case <-ctx.Done():
+			l.mu.Lock()
+			if w.granted {
+				// The slot arrived as the wait ended; pass it on.
+				l.mu.Unlock()
+				l.Release()
+				return ctx.Err()
+			}
+			l.removeLocked(w)
+			l.notifyLocked()
+			l.mu.Unlock()
+			return ctx.Err()
+		}
 	}
 }
 
+// Release frees a slot, handing it straight to the next waiting execution if
+// there is one.
 func (l *Limiter) Release() {
-	<-l.sem
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.queued > 0 {
+		since := l.busySince
+		if l.lastRelease.After(since) {
+			since = l.lastRelease
+		}
+		// Exponentially weighted, so the estimate follows the current load.
+		l.turn = (3*l.turn + now.Sub(since)) / 4
+	}
+	l.lastRelease = now
+
+	if w := l.nextLocked(); w != nil {
+		w.granted = true
+		close(w.ready)
+		l.notifyLocked()
+		return
+	}
+	l.inUse--
+}
+
+// enqueueLocked adds a waiter for caller, whose class is level, at the back
+// of the caller's queue.
+func (l *Limiter) enqueueLocked(caller Caller, level int) *waiter {
+	cq := l.callers[caller]
+	if cq == nil {
+		cq = &callerQueue{caller: caller, level: level}
+		l.callers[caller] = cq
+		l.levels[level] = append(l.levels[level], cq)
+	}
+	w := &waiter{cq: cq, ready: make(chan struct{}), moved: make(chan struct{}, 1)}
+	cq.waiters = append(cq.waiters, w)
+	if l.queued == 0 {
+		l.busySince = time.Now()
+	}
+	l.queued++
+	return w
+}
+
+// nextLocked removes and returns the waiter whose turn it is, or nil.
+func (l *Limiter) nextLocked() *waiter {
+	for level, ring := range l.levels {
+		if len(ring) == 0 {
+			continue
+		}
+		cq := ring[0]
+		w := cq.waiters[0]
+		cq.waiters = cq.waiters[1:]
+		ring = ring[1:]
+		if len(cq.waiters) > 0 {
+			ring = append(ring, cq)
+		} else {
+			delete(l.callers, cq.caller)
+		}
+		l.levels[level] = ring
+		l.queued--
+		return w
+	}
+	return nil
+}
+
+// removeLocked takes a waiter that gave up out of the queue.
+func (l *Limiter) removeLocked(w *waiter) {
+	cq := w.cq
+	for i, x := range cq.waiters {
+		if x == w {
+			cq.waiters = append(cq.waiters[:i], cq.waiters[i+1:]...)
+			break
+		}
+	}
+	if len(cq.waiters) == 0 {
+		ring := l.levels[cq.level]
+		for i, x := range ring {
+			if x == cq {
+				l.levels[cq.level] = append(ring[:i], ring[i+1:]...)
+				break
+			}
+		}
+		delete(l.callers, cq.caller)
+	}
+	l.queued--
+}
+
+// notifyLocked tells every waiter that its position may have changed.
+func (l *Limiter) notifyLocked() {
+	for _, ring := range l.levels {
+		for _, cq := range ring {
+			for _, w := range cq.waiters {
+				select {
+				case w.moved <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// status reports w's position, counting the executions that will start
+// before it if no more arrive, and false once it is no longer waiting.
+func (l *Limiter) status(w *waiter) (QueueStatus, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		return QueueStatus{}, false
+	}
+
+	k := -1
+	for i, x := range w.cq.waiters {
+		if x == w {
+			k = i
+		}
+	}
+	if k < 0 {
+		return QueueStatus{}, false
+	}
+
+	// Higher classes go first. In w's class, callers take one turn each per
+	// round: w starts in round k, after the callers ahead of its own.
+	ahead := k
+	for level, ring := range l.levels {
+		if level > w.cq.level {
+			break
+		}
+		before := true
+		for _, cq := range ring {
+			switch {
+			case level < w.cq.level:
+				ahead += len(cq.waiters)
+			case cq == w.cq:
+				before = false
+			case before:
+				ahead += min(len(cq.waiters), k+1)
+			default:
+				ahead += min(len(cq.waiters), k)
+			}
+		}
+	}
+
+	position := ahead + 1
+	return QueueStatus{
+		Position:        position,
+		EstimatedWaitMs: (time.Duration(position) * l.turn).Milliseconds(),
+	}, true
 }
 
 func (l *Limiter) InUse() int {
-	return len(l.sem)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse
 }
 
 func (l *Limiter) Queued() int {
-	return int(l.queued.Load())
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queued
 }
 
 func (l *Limiter) Max() int {
 	return l.max
 }
+
+// MaxPerCaller is how many executions one caller may have waiting at once,
+// at most Max.
+func (l *Limiter) MaxPerCaller() int {
+	if l.maxPerCaller > 0 {
+		return min(l.maxPerCaller, l.max)
+	}
+	return l.max
+}
@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// limiterCases queue executions behind a busy slot; want lists the order,
+// by arrival index, in which they get it.
+var limiterCases = []struct {
+	name    string
+	arrives []Caller
+	want    []int
+}{
+	{
+		name:    "one caller is first come first served",
+		arrives: []Caller{{ID: "a"}, {ID: "a"}, {ID: "a"}},
+		want:    []int{0, 1, 2},
+	},
+	{
+		name:    "callers take turns",
+		arrives: []Caller{{ID: "a"}, {ID: "a"}, {ID: "a"}, {ID: "b"}, {ID: "c"}},
+		want:    []int{0, 3, 4, 1, 2},
+	},
+	{
+		name:    "a caller with more waiting goes again after the others",
+		arrives: []Caller{{ID: "a"}, {ID: "b"}, {ID: "b"}, {ID: "c"}},
+		want:    []int{0, 1, 3, 2},
+	},
+	{
+		name:    "interview before practice",
+		arrives: []Caller{{ID: "a"}, {ID: "b", Priority: PriorityInterview}},
+		want:    []int{1, 0},
+	},
+	{
+		name: "interview callers take turns ahead of practice",
+		arrives: []Caller{
+			{ID: "a"},
+			{ID: "b", Priority: PriorityInterview},
+			{ID: "b", Priority: PriorityInterview},
+			{ID: "c", Priority: PriorityInterview},
+			{ID: "a"},
+		},
+		want: []int{1, 3, 2, 0, 4},
+	},
+	{
+		name:    "same caller in two classes queues separately",
+		arrives: []Caller{{ID: "a"}, {ID: "a", Priority: PriorityInterview}, {ID: "b"}},
+		want:    []int{1, 0, 2},
+	},
+}
+
+// queueBehindBusySlot returns a limiter with its only slot in use and a
+// waiter for each of arrives, in order.
+func queueBehindBusySlot(t *testing.T, arrives []Caller) (*Limiter, []*waiter) {
+	t.Helper()
+	l := NewLimiter(1, 0, 0)
+	l.inUse = 1
+	waiters := make([]*waiter, len(arrives))
+	for i, c := range arrives {
+		level, err := c.Priority.level()
+		if err != nil {
+			t.Fatal(err)
+		}
+		waiters[i] = l.enqueueLocked(c, level)
+	}
+	return l, waiters
+}
+
+func TestLimiterNextLocked(t *testing.T) {
+	for _, tt := range limiterCases {
+		t.Run(tt.name, func(t *testing.T) {
+			l, waiters := queueBehindBusySlot(t, tt.arrives)
+			for turn, want := range tt.want {
+				got := l.nextLocked()
+				if got != waiters[want] {
+					t.Fatalf("turn %d: got waiter %d, want %d", turn, indexOf(waiters, got), want)
+				}
+			}
+			if w := l.nextLocked(); w != nil {
+				t.Errorf("got waiter %d after the queue emptied", indexOf(waiters, w))
+			}
+			if l.queued != 0 || len(l.callers) != 0 {
+				t.Errorf("queued = %d, callers = %d after the queue emptied", l.queued, len(l.callers))
+			}
+		})
+	}
+}
+
+func TestLimiterStatus(t *testing.T) {
+	for _, tt := range limiterCases {
+		t.Run(tt.name, func(t *testing.T) {
+			l, waiters := queueBehindBusySlot(t, tt.arrives)
+			l.turn = 100 * time.Millisecond
+			for turn, i := range tt.want {
+				status, waiting := l.status(waiters[i])
+				if !waiting || status.Position != turn+1 {
+					t.Errorf("waiter %d: position %d (waiting %v), want %d", i, status.Position, waiting, turn+1)
+				}
+				if want := int64(100 * (turn + 1)); status.EstimatedWaitMs != want {
+					t.Errorf("waiter %d: estimated wait %dms, want %dms", i, status.EstimatedWaitMs, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterStatusAfterLeaving(t *testing.T) {
+	l, waiters := queueBehindBusySlot(t, []Caller{{ID: "a"}, {ID: "b"}, {ID: "c"}})
+
+	l.Release()
+	if _, waiting := l.status(waiters[0]); waiting {
+		t.Error("granted waiter still reported as waiting")
+	}
+	l.removeLocked(waiters[1])
+	if _, waiting := l.status(waiters[1]); waiting {
+		t.Error("removed waiter still reported as waiting")
+	}
+	if status, _ := l.status(waiters[2]); status.Position != 1 {
+		t.Errorf("last waiter at position %d, want 1", status.Position)
+	}
+}
+
+func TestLimiterAcquireQueueFull(t *testing.T) {
+	tests := []struct {
+		name                   string
+		maxQueue, maxPerCaller int
+		queued                 []Caller
+		caller                 Caller
+	}{
+		{"queue full", 2, 0, []Caller{{ID: "a"}, {ID: "b"}}, Caller{ID: "c"}},
+		{"caller's share full", 0, 2, []Caller{{ID: "a"}, {ID: "a"}, {ID: "b"}}, Caller{ID: "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(1, tt.maxQueue, tt.maxPerCaller)
+			l.inUse = 1
+			for _, c := range tt.queued {
+				level, _ := c.Priority.level()
+				l.enqueueLocked(c, level)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := l.Acquire(ctx, tt.caller, nil); !errors.Is(err, ErrQueueFull) {
+				t.Errorf("Acquire() = %v, want ErrQueueFull", err)
+			}
+		})
+	}
+}
+
+func indexOf(waiters []*waiter, w *waiter) int {
+	for i, x := range waiters {
+		if x == w {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRunnerPriority(t *testing.T) {
+	r, executor := newFakeRunner(FakeScript{Rules: []FakeRule{{Source: "hold", LatencyMs: 100}}}, 1)
+	ctx := context.Background()
+
+	// Practice runs queue behind a busy slot before an interview run does.
+	arrivals := []ExecuteRequest{
+		{SourceCode: "hold", CallerID: "a"},
+		{SourceCode: "practice 1", CallerID: "b"},
+		{SourceCode: "practice 2", CallerID: "c"},
+		{SourceCode: "interview", CallerID: "d", Priority: PriorityInterview},
+	}
+	done := make(chan struct{}, len(arrivals))
+	for i, req := range arrivals {
+		req.LanguageID = 71
+		go func() {
+			r.Execute(ctx, req)
+			done <- struct{}{}
+		}()
+		if i == 0 {
+			waitFor(t, "the slot to be taken", func() bool { return r.limiter.InUse() == 1 })
+		} else {
+			waitFor(t, "the run to queue", func() bool { return r.limiter.Queued() == i })
+		}
+	}
+	for range arrivals {
+		<-done
+	}
+
+	want := []string{"hold", "interview", "practice 1", "practice 2"}
+	if got := executor.executed(); !slices.Equal(got, want) {
+		t.Errorf("executor ran %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectFile is one more file of a multi-file program, such as a helper
+// module, a data file or part of a package. Path is relative to the work dir
+// and uses forward slashes.
+type ProjectFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ValidateProject checks the files and entrypoint of a request: at most
+// MaxProjectFiles files of at most MaxProjectBytes in all (source included),
+// with clean relative paths that stay inside the work dir and do not collide.
+func (r *Runner) ValidateProject(req ExecuteRequest) error {
+	if len(req.Files) == 0 && req.Entrypoint == "" {
+		return nil
+	}
+	if r.cfg.MaxProjectFiles > 0 && len(req.Files) > r.cfg.MaxProjectFiles {
+		return fmt.Errorf("too many files: %d (max %d)", len(req.Files), r.cfg.MaxProjectFiles)
+	}
+
+	lang, err := GetLanguage(req.LanguageID)
+	if err != nil {
+		return err
+	}
+	if lang.Database != "" {
+		return errors.New("files and entrypoint are not supported for SQL")
+	}
+	if req.Entrypoint != "" {
+		if err := validateProjectPath(req.Entrypoint); err != nil {
+			return fmt.Errorf("entrypoint: %w", err)
+		}
+	}
+	entrypoint := cmp.Or(req.Entrypoint, lang.SourceFile)
+
+	size := len(req.SourceCode)
+	paths := map[string]bool{entrypoint: true}
+	for i, f := range req.Files {
+		if err := validateProjectPath(f.Path); err != nil {
+			return fmt.Errorf("file %d: %w", i, err)
+		}
+		switch {
+		case f.Path == entrypoint:
+			return fmt.Errorf("file %d: %s is the entrypoint", i, f.Path)
+		case paths[f.Path]:
+			return fmt.Errorf("file %d: %s is given twice", i, f.Path)
+		}
+		paths[f.Path] = true
+		size += len(f.Content)
+	}
+	if r.cfg.MaxProjectBytes > 0 && size > r.cfg.MaxProjectBytes {
+		return fmt.Errorf("project too large: %d bytes (max %d)", size, r.cfg.MaxProjectBytes)
+	}
+
+	// A file cannot also be the directory of another.
+	for p := range paths {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if paths[dir] {
+				return fmt.Errorf("%s is both a file and a directory", dir)
+			}
+		}
+	}
+	return nil
+}
+
+// validateProjectPath rejects paths that could leave the work dir or that
+// the compile step writes to.
+func validateProjectPath(p string) error {
+	switch {
+	case !fs.ValidPath(p) || p == "." || strings.Contains(p, `\`):
+		return fmt.Errorf("invalid path %q: must be relative, without . or .. elements", p)
+	case p == binaryName:
+		return fmt.Errorf("invalid path %q: reserved for the compiled program", p)
+	}
+	return nil
+}
+
+// withEntrypoint returns lang with its source written to and run from
+// entrypoint, when one is given.
+func (lang Language) withEntrypoint(entrypoint string) Language {
+	if entrypoint != "" {
+		lang.SourceFile = entrypoint
+	}
+	return lang
+}
+
+// writeProjectFiles writes files into dir, creating their directories.
+func writeProjectFiles(dir string, files []ProjectFile) error {
+	for _, f := range files {
+		name := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, []byte(f.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projectKey identifies the files and entrypoint of a request, so batch
+// items only share a compiled program when their whole project matches.
+func projectKey(req ExecuteRequest) string {
+	if len(req.Files) == 0 && req.Entrypoint == "" {
+		return ""
+	}
+	data, _ := json.Marshal(struct {
+		Entrypoint string        `json:"entrypoint"`
+		Files      []ProjectFile `json:"files"`
+	}{req.Entrypoint, req.Files})
+	return string(data)
+}
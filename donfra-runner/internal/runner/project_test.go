@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateProject(t *testing.T) {
+	r := &Runner{cfg: Config{MaxProjectFiles: 3, MaxProjectBytes: 100}}
+	py := func(entrypoint string, files ...ProjectFile) ExecuteRequest {
+		return ExecuteRequest{SourceCode: "print(1)", LanguageID: 71, Entrypoint: entrypoint, Files: files}
+	}
+	file := func(p string) ProjectFile { return ProjectFile{Path: p, Content: "x"} }
+
+	tests := []struct {
+		name    string
+		req     ExecuteRequest
+		wantErr string // substring; empty means valid
+	}{
+		{"no project", py(""), ""},
+		{"helper module", py("", file("util.py")), ""},
+		{"nested entrypoint and package", py("app/main.py", file("app/util.py"), file("lib/__init__.py")), ""},
+		{"entrypoint only", py("src/main.py"), ""},
+		{"too many files", py("", file("a"), file("b"), file("c"), file("d")), "too many files"},
+		{"too large with source", py("", ProjectFile{Path: "data.txt", Content: strings.Repeat("x", 93)}), "project too large"},
+		{"at the size limit", py("", ProjectFile{Path: "data.txt", Content: strings.Repeat("x", 92)}), ""},
+		{"absolute path", py("", file("/etc/passwd")), "invalid path"},
+		{"parent element", py("", file("../x.py")), "invalid path"},
+		{"inner parent element", py("", file("a/../../x.py")), "invalid path"},
+		{"dot element", py("", file("./x.py")), "invalid path"},
+		{"dot", py("", file(".")), "invalid path"},
+		{"empty path", py("", file("")), "invalid path"},
+		{"backslash", py("", file(`a\b.py`)), "invalid path"},
+		{"trailing slash", py("", file("a/")), "invalid path"},
+		{"compiled program name", py("", file("main")), "reserved"},
+		{"invalid entrypoint", py("../main.py"), "entrypoint: invalid path"},
+		{"entrypoint named main", py("main"), "entrypoint: invalid path"},
+		{"file is the default source", py("", file("main.py")), "is the entrypoint"},
+		{"file is the entrypoint", py("app/run.py", file("app/run.py")), "is the entrypoint"},
+		{"default source free with entrypoint", py("app/run.py", file("main.py")), ""},
+		{"duplicate file", py("", file("util.py"), file("util.py")), "given twice"},
+		{"file is another's directory", py("", file("lib"), file("lib/x.py")), "both a file and a directory"},
+		{"entrypoint is a file's directory", py("app", file("app/x.py")), "both a file and a directory"},
+		{"file inside the source", py("", file("main.py/x")), "both a file and a directory"},
+		{"unknown language", ExecuteRequest{LanguageID: 9999, Files: []ProjectFile{file("a")}}, "9999"},
+		{"sql", ExecuteRequest{SourceCode: "select 1;", LanguageID: 82, Files: []ProjectFile{file("a.sql")}}, "not supported for SQL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.ValidateProject(tt.req)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("ValidateProject() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("ValidateProject() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
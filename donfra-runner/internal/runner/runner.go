@@ -1,46 +1,142 @@
 package runner
 
 import (
-	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
+	"sync/atomic"
 	"time"
+
+	"donfra-runner/internal/metrics"
+	"donfra-runner/internal/tracing"
 )
 
 const (
+	StatusInQueue           = 1
+	StatusProcessing        = 2
 	StatusAccepted          = 3
+	StatusWrongAnswer       = 4
 	StatusTimeLimitExceeded = 5
+	StatusCompilationError  = 6
 	StatusMemoryLimit       = 7
 	StatusRuntimeError      = 11
+	StatusInternalError     = 13
 )
 
 // JailMode controls how code is executed.
 //   - "direct": timeout command + context cancel (local dev)
+//   - "sandbox": Linux namespaces + cgroups v2 + seccomp on this node (single node)
 //   - "k8s": K8s Job per execution (production)
+//   - "fake": no code runs; results come from a FakeScript (tests, UI work)
 type JailMode string
 
 const (
-	JailDirect JailMode = "direct"
-	JailK8sJob JailMode = "k8s"
+	JailDirect  JailMode = "direct"
+	JailSandbox JailMode = "sandbox"
+	JailK8sJob  JailMode = "k8s"
+	JailFake    JailMode = "fake"
+)
+
+// ExecuteMode selects what an execution produces.
+type ExecuteMode string
+
+const (
+	// ModeRun runs the program and returns its output.
+	ModeRun ExecuteMode = ""
+	// ModeVisualize runs the program under a step tracer and also returns a
+	// Trace for the lesson page to animate. Only languages marked Visualize
+	// support it.
+	ModeVisualize ExecuteMode = "visualize"
+	// ModeComplexity runs the program on inputs of increasing size from a
+	// Generator and returns a ComplexityReport.
+	ModeComplexity ExecuteMode = "complexity"
+	// ModeUnitTests runs TestCode, a unit-test file, against the program and
+	// returns a result per test. Only languages with a TestHarness support it.
+	ModeUnitTests ExecuteMode = "unit_tests"
 )
 
+// binaryName is the compile output file name inside the work dir.
+const binaryName = "main"
+
 type Config struct {
 	JailMode       JailMode
 	MaxTimeoutMs   int
 	DefaultTimeout int
 	MaxOutputBytes int
+	MaxTestCases   int
+	MaxBatchSize   int
+	MaxTraceSteps  int
+
+	// QueueTimeoutMs is how long an execution waits in the queue for a free
+	// slot before it is rejected as Queue Full.
+	QueueTimeoutMs int
+
+	// Multi-file programs: at most MaxProjectFiles files beside the source,
+	// and MaxProjectBytes of content in all.
+	MaxProjectFiles int
+	MaxProjectBytes int
+
+	// Interactive sessions: at most MaxSessions at once, each ended after
+	// SessionIdleMs without input or output, SessionMaxMs in total or
+	// SessionCPUMs of CPU time, with output capped per stream.
+	MaxSessions           int
+	SessionIdleMs         int
+	SessionMaxMs          int
+	SessionCPUMs          int
+	SessionMaxOutputBytes int
 }
 
 type ExecuteRequest struct {
-	SourceCode string `json:"source_code"`
-	LanguageID int    `json:"language_id"`
-	Stdin      string `json:"stdin"`
-	TimeoutMs  int    `json:"timeout_ms"`
+	SourceCode string     `json:"source_code"`
+	LanguageID int        `json:"language_id"`
+	Stdin      string     `json:"stdin"`
+	TimeoutMs  int        `json:"timeout_ms"`
+	TestCases  []TestCase `json:"test_cases,omitempty"`
+
+	// Files are more files of the program, written into the work dir beside
+	// the source. Entrypoint is the path the source is written to and run
+	// from, lang's SourceFile by default.
+	Files      []ProjectFile `json:"files,omitempty"`
+	Entrypoint string        `json:"entrypoint,omitempty"`
+
+	// Mode selects what the execution produces; see ExecuteMode.
+	Mode ExecuteMode `json:"mode,omitempty"`
+
+	// Checker, when set, decides each test case's verdict instead of
+	// comparing stdout with the expected output.
+	Checker *Checker `json:"checker,omitempty"`
+
+	// Generator produces the inputs of a complexity run.
+	Generator *Generator `json:"generator,omitempty"`
+
+	// TestCode is the unit-test file of a unit_tests run. It imports the
+	// program as the module "solution".
+	TestCode string `json:"test_code,omitempty"`
+
+	// Schema is the SQL script that creates and seeds the database a SQL
+	// program runs against. ExpectedResult, when set, grades the program's
+	// result set.
+	Schema         string          `json:"schema,omitempty"`
+	ExpectedResult *ExpectedResult `json:"expected_result,omitempty"`
+
+	// Cache opts in to the result cache: an identical earlier request's
+	// result is returned instead of running again. Only set it for code
+	// whose output depends on nothing but its input.
+	Cache bool `json:"cache,omitempty"`
+
+	// Trusted marks staff-authored code (e.g. lesson reference solutions). In
+	// K8s mode a warm pod may serve several trusted executions before it is
+	// recycled; untrusted code always gets a pod of its own.
+	Trusted bool `json:"trusted,omitempty"`
+
+	// CallerID and Priority place the execution in the queue when every slot
+	// is in use; see Limiter. Both are set by the API, not by end users.
+	CallerID string   `json:"caller_id,omitempty"`
+	Priority Priority `json:"priority,omitempty"`
 }
 
 type ExecuteStatus struct {
@@ -48,26 +144,76 @@ type ExecuteStatus struct {
 	Description string `json:"description"`
 }
 
+// Usage is what the jail measured about the run step of a program. Fields the
+// jail mode cannot measure are left zero.
+type Usage struct {
+	CPUUserMs int64 `json:"cpu_user_ms,omitempty"`
+	CPUSysMs  int64 `json:"cpu_sys_ms,omitempty"`
+	MemoryKB  int64 `json:"memory_kb,omitempty"` // peak resident set size
+
+	// ExitCode is set when the process exited on its own; Signal names the
+	// signal that killed it otherwise (e.g. "SIGSEGV").
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Signal   string `json:"signal,omitempty"`
+
+	// StdoutTruncated and StderrTruncated report output cut at MaxOutputBytes.
+	StdoutTruncated bool `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool `json:"stderr_truncated,omitempty"`
+}
+
 type ExecuteResult struct {
-	Token           string        `json:"token"`
+	Token           string        `json:"token,omitempty"` // submission token; empty for synchronous runs
 	Status          ExecuteStatus `json:"status"`
 	Stdout          string        `json:"stdout,omitempty"`
 	Stderr          string        `json:"stderr,omitempty"`
 	Message         string        `json:"message,omitempty"`
+	CompileOutput   string        `json:"compile_output,omitempty"`
 	ExecutionTimeMs int64         `json:"execution_time_ms"`
+	Usage
+
+	// Cached is set when the result was served from the result cache; usage
+	// figures are those of the original run.
+	Cached bool `json:"cached,omitempty"`
+
+	// Queue is where a submission stands while it is In Queue.
+	Queue *QueueStatus `json:"queue,omitempty"`
+
+	// TestResults holds per-case verdicts when the request was judged.
+	TestResults []TestCaseResult `json:"test_results,omitempty"`
+
+	// Trace holds the recorded steps of a visualize run.
+	Trace *Trace `json:"trace,omitempty"`
+
+	// Complexity holds the measurements and fits of a complexity run.
+	Complexity *ComplexityReport `json:"complexity,omitempty"`
+
+	// UnitTests holds per-test results of a unit_tests run, in run order.
+	UnitTests []UnitTestResult `json:"unit_tests,omitempty"`
+
+	// ResultSet holds the rows returned by a SQL program.
+	ResultSet *ResultSet `json:"result_set,omitempty"`
 }
 
 type Runner struct {
-	cfg         Config
-	limiter     *Limiter
-	k8sExecutor *K8sExecutor
+	cfg      Config
+	limiter  *Limiter
+	executor Executor
+	cache    *ResultCache // nil disables caching
+	drain    *drainState
+	sessions atomic.Int64 // running interactive sessions
 }
 
-func New(cfg Config, limiter *Limiter, k8sExecutor *K8sExecutor) *Runner {
-	return &Runner{cfg: cfg, limiter: limiter, k8sExecutor: k8sExecutor}
+func New(cfg Config, limiter *Limiter, executor Executor, cache *ResultCache) *Runner {
+	return &Runner{cfg: cfg, limiter: limiter, executor: executor, cache: cache, drain: newDrainState()}
 }
 
 func (r *Runner) Execute(ctx context.Context, req ExecuteRequest) ExecuteResult {
+	return r.execute(ctx, req, nil)
+}
+
+// execute validates req, takes an execution slot and runs it. When out is
+// non-nil, program output is also delivered to it as it is produced.
+func (r *Runner) execute(ctx context.Context, req ExecuteRequest, out OutputFunc) ExecuteResult {
 	if req.SourceCode == "" {
 		return errorResult("source_code is required")
 	}
@@ -77,169 +223,271 @@ func (r *Runner) Execute(ctx context.Context, req ExecuteRequest) ExecuteResult
 		return errorResult(err.Error())
 	}
 
-	// Resolve timeout
-	timeout := r.cfg.DefaultTimeout
-	if req.TimeoutMs > 0 {
-		timeout = req.TimeoutMs
+	if err := r.ValidateTestCases(req.TestCases); err != nil {
+		return errorResult(err.Error())
 	}
-	if timeout > r.cfg.MaxTimeoutMs {
-		timeout = r.cfg.MaxTimeoutMs
+
+	if err := validateMode(lang, req); err != nil {
+		return errorResult(err.Error())
 	}
 
-	// Acquire execution slot
-	acquireCtx, acquireCancel := context.WithTimeout(ctx, 5*time.Second)
-	defer acquireCancel()
+	if err := validateSQL(lang, req); err != nil {
+		return errorResult(err.Error())
+	}
 
-	if err := r.limiter.Acquire(acquireCtx); err != nil {
-		return ExecuteResult{
-			Token:   "ws-exec",
-			Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Queue Full"},
-			Message: "too many concurrent executions, try again later",
-		}
+	if err := r.ValidateChecker(req); err != nil {
+		return errorResult(err.Error())
 	}
-	defer r.limiter.Release()
 
-	// Write source code to temp file
-	tmpFile, err := writeTempFile(req.SourceCode, lang.Extension)
-	if err != nil {
-		log.Printf("failed to write temp file: %v", err)
-		return errorResult("internal error: failed to prepare execution")
+	if err := r.ValidateProject(req); err != nil {
+		return errorResult(err.Error())
 	}
-	defer os.Remove(tmpFile)
 
-	// K8s mode skips temp file — source code is passed via env var.
-	if r.cfg.JailMode == JailK8sJob {
-		if r.k8sExecutor == nil {
-			return errorResult("k8s executor not initialized")
+	if err := ValidatePriority(req.Priority); err != nil {
+		return errorResult(err.Error())
+	}
+
+	timeout := r.resolveTimeout(lang, req.TimeoutMs)
+	lang = lang.withEntrypoint(req.Entrypoint)
+
+	ctx, done, ok := r.drain.begin(ctx)
+	if !ok {
+		return drainingResult()
+	}
+	defer done()
+
+	ctx, span := tracing.StartSpan(ctx, "runner.execute",
+		tracing.AttrLanguage.String(lang.Name),
+		tracing.AttrJailMode.String(string(r.cfg.JailMode)),
+		tracing.AttrTestCases.Int(len(req.TestCases)),
+	)
+	defer span.End()
+
+	// Streamed runs are never cached: a hit would have no output to stream.
+	var cacheKey string
+	if req.Cache && r.cache != nil && out == nil {
+		cacheKey = r.cacheKey(lang, req, timeout)
+		if result, ok := r.cache.get(ctx, cacheKey); ok {
+			span.SetAttributes(tracing.AttrStatus.String(result.Status.Description), tracing.AttrCached.Bool(true))
+			return result
 		}
-		os.Remove(tmpFile)
-		return r.k8sExecutor.Execute(ctx, lang, req, timeout)
 	}
 
+	// Acquire execution slot
+	if err := r.acquire(ctx, Caller{ID: req.CallerID, Priority: req.Priority}); err != nil {
+		result := queueFullResult(err)
+		span.SetAttributes(tracing.AttrStatus.String(result.Status.Description))
+		metrics.ExecutionsTotal.WithLabelValues(lang.Name, result.Status.Description).Inc()
+		return result
+	}
+	defer r.limiter.Release()
+
 	start := time.Now()
-	result := r.executeDirect(ctx, lang, tmpFile, req.Stdin, timeout)
-	result.ExecutionTimeMs = time.Since(start).Milliseconds()
+	var result ExecuteResult
+	switch {
+	case lang.Database != "":
+		result = r.runSQL(ctx, lang, req, timeout)
+	case len(req.TestCases) > 0:
+		result = r.judge(ctx, lang, req, timeout)
+	case req.Mode == ModeVisualize:
+		result = r.visualize(ctx, lang, req, timeout)
+	case req.Mode == ModeComplexity:
+		result = r.complexity(ctx, lang, req, timeout)
+	case req.Mode == ModeUnitTests:
+		result = r.unitTests(ctx, lang, req, timeout)
+	default:
+		result = r.run(ctx, lang, req, timeout, out)
+	}
+
+	span.SetAttributes(tracing.AttrStatus.String(result.Status.Description))
+	metrics.RecordExecution(lang.Name, result.Status.Description, time.Since(start).Seconds())
+
+	if cacheKey != "" {
+		r.cache.put(ctx, cacheKey, result)
+	}
 	return result
 }
 
-// executeDirect runs code with context timeout only (local dev).
-func (r *Runner) executeDirect(ctx context.Context, lang Language, codePath string, stdin string, timeoutMs int) ExecuteResult {
-	execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
-	defer cancel()
+// validateMode checks that req's mode can be used with lang.
+func validateMode(lang Language, req ExecuteRequest) error {
+	if req.Mode != ModeComplexity && req.Generator != nil {
+		return errors.New("generator requires complexity mode")
+	}
+	if req.Mode != ModeUnitTests && req.TestCode != "" {
+		return errors.New("test_code requires unit_tests mode")
+	}
+	switch req.Mode {
+	case ModeRun:
+		return nil
+	case ModeVisualize:
+		if !lang.Visualize {
+			return fmt.Errorf("visualize mode is not supported for language %d", lang.ID)
+		}
+		if len(req.TestCases) > 0 {
+			return errors.New("test_cases are not supported in visualize mode")
+		}
+		return nil
+	case ModeComplexity:
+		if len(req.TestCases) > 0 {
+			return errors.New("test_cases are not supported in complexity mode")
+		}
+		return validateGenerator(req.Generator)
+	case ModeUnitTests:
+		if lang.TestHarness == "" {
+			return fmt.Errorf("unit_tests mode is not supported for language %d", lang.ID)
+		}
+		if req.TestCode == "" {
+			return errors.New("test_code is required")
+		}
+		if len(req.TestCases) > 0 {
+			return errors.New("test_cases are not supported in unit_tests mode")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode: %q", req.Mode)
+	}
+}
 
-	cmd := exec.CommandContext(execCtx, lang.Interpreter, codePath)
-	return r.runCmd(execCtx, cmd, stdin)
+// resolveTimeout applies lang's default and maximum run timeouts, or the
+// runner-wide ones, to a requested one.
+func (r *Runner) resolveTimeout(lang Language, requestedMs int) int {
+	timeout := cmp.Or(lang.DefaultTimeoutMs, r.cfg.DefaultTimeout)
+	if requestedMs > 0 {
+		timeout = requestedMs
+	}
+	return min(timeout, cmp.Or(lang.MaxTimeoutMs, r.cfg.MaxTimeoutMs))
 }
 
-// runCmd executes a command and maps the result to ExecuteResult.
-func (r *Runner) runCmd(ctx context.Context, cmd *exec.Cmd, stdin string) ExecuteResult {
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &limitedWriter{w: &stdoutBuf, limit: r.cfg.MaxOutputBytes}
-	cmd.Stderr = &limitedWriter{w: &stderrBuf, limit: r.cfg.MaxOutputBytes}
+// acquire takes an execution slot for caller, giving up after QueueTimeoutMs.
+// Queue status goes to the context's queue reporter, if any. The caller must
+// release the slot when it returns nil.
+func (r *Runner) acquire(ctx context.Context, caller Caller) error {
+	acquireCtx, acquireCancel := context.WithTimeout(ctx, time.Duration(r.cfg.QueueTimeoutMs)*time.Millisecond)
+	defer acquireCancel()
 
-	if stdin != "" {
-		cmd.Stdin = bytes.NewReader([]byte(stdin))
+	start := time.Now()
+	err := r.limiter.Acquire(acquireCtx, caller, queueReporter(ctx))
+	metrics.QueueWait.Observe(time.Since(start).Seconds())
+	return err
+}
+
+// queueFullResult is the result of an execution that did not get a slot.
+func queueFullResult(err error) ExecuteResult {
+	message := "too many concurrent executions, try again later"
+	if errors.Is(err, ErrQueueFull) {
+		message = "too many queued executions, try again later"
+	}
+	return ExecuteResult{
+		Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Queue Full"},
+		Message: message,
 	}
+}
 
-	err := cmd.Run()
+// run performs a single execution of req on the executor.
+func (r *Runner) run(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int, out OutputFunc) ExecuteResult {
+	return r.executor.Execute(ctx, lang, req, timeoutMs, out)
+}
 
-	stdout := stdoutBuf.String()
-	stderr := stderrBuf.String()
+// exitResult maps how a finished command ended to a verdict.
+func exitResult(ctx context.Context, err error, usage Usage) ExecuteResult {
+	result := ExecuteResult{Usage: usage}
 
 	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-		return ExecuteResult{
-			Token:  "ws-exec",
-			Status: ExecuteStatus{ID: StatusTimeLimitExceeded, Description: "Time Limit Exceeded"},
-			Stdout: stdout,
-			Stderr: stderr,
-		}
+		result.Status = ExecuteStatus{ID: StatusTimeLimitExceeded, Description: "Time Limit Exceeded"}
+		return result
 	}
-
-	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			exitCode := exitErr.ExitCode()
-
-			// OOM kill: exit code 137 (128 + SIGKILL)
-			if exitCode == 137 {
-				return ExecuteResult{
-					Token:  "ws-exec",
-					Status: ExecuteStatus{ID: StatusMemoryLimit, Description: "Memory Limit Exceeded"},
-					Stdout: stdout,
-					Stderr: stderr,
-				}
-			}
-
-			return ExecuteResult{
-				Token:   "ws-exec",
-				Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
-				Stdout:  stdout,
-				Stderr:  stderr,
-				Message: fmt.Sprintf("Process exited with code %d", exitCode),
-			}
-		}
-
-		return ExecuteResult{
-			Token:   "ws-exec",
-			Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
-			Message: "execution failed",
-		}
+	if ctx.Err() != nil {
+		cancelled := cancelledResult()
+		result.Status, result.Message = cancelled.Status, cancelled.Message
+		return result
 	}
 
-	return ExecuteResult{
-		Token:  "ws-exec",
-		Status: ExecuteStatus{ID: StatusAccepted, Description: "Accepted"},
-		Stdout: stdout,
-		Stderr: stderr,
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.Status = ExecuteStatus{ID: StatusAccepted, Description: "Accepted"}
+	case !errors.As(err, &exitErr):
+		result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+		result.Message = "execution failed"
+	// OOM kill: exit code 137 (128 + SIGKILL) from the memory-limit shell
+	case exitErr.ExitCode() == 137:
+		result.Status = ExecuteStatus{ID: StatusMemoryLimit, Description: "Memory Limit Exceeded"}
+	case usage.Signal != "":
+		result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+		result.Message = fmt.Sprintf("Process was killed by %s", usage.Signal)
+	default:
+		result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+		result.Message = fmt.Sprintf("Process exited with code %d", exitErr.ExitCode())
 	}
+	return result
 }
 
-func writeTempFile(content string, ext string) (string, error) {
-	f, err := os.CreateTemp("", "runner-*"+ext)
+// writeWorkDir creates a private work dir containing the source file and any
+// project files.
+func writeWorkDir(content string, sourceFile string, files []ProjectFile) (string, error) {
+	dir, err := os.MkdirTemp("", "runner-*")
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
 
-	if _, err := f.WriteString(content); err != nil {
-		os.Remove(f.Name())
+	files = append([]ProjectFile{{Path: sourceFile, Content: content}}, files...)
+	if err := writeProjectFiles(dir, files); err != nil {
+		os.RemoveAll(dir)
 		return "", err
 	}
 
-	if err := os.Chmod(f.Name(), 0644); err != nil {
-		os.Remove(f.Name())
+	if err := os.Chmod(dir, 0755); err != nil {
+		os.RemoveAll(dir)
 		return "", err
 	}
 
-	return f.Name(), nil
+	return dir, nil
+}
+
+// cancelledResult reports an execution abandoned by its caller or by shutdown.
+func cancelledResult() ExecuteResult {
+	return ExecuteResult{
+		Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
+		Message: "execution was cancelled",
+	}
 }
 
 func errorResult(msg string) ExecuteResult {
 	return ExecuteResult{
-		Token:   "ws-exec",
 		Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"},
 		Message: msg,
 	}
 }
 
-// limitedWriter wraps a writer and stops writing after limit bytes.
+// limitedWriter wraps a writer and discards everything past limit bytes. It
+// always reports the whole buffer as written: a short write would make the
+// copy from the process's pipe stop, leaving the program blocked on a full
+// pipe until it times out.
 type limitedWriter struct {
-	w       io.Writer
-	limit   int
-	written int
+	w         io.Writer
+	limit     int
+	written   int
+	truncated bool
 }
 
 func (lw *limitedWriter) Write(p []byte) (int, error) {
+	n := len(p)
 	if lw.written >= lw.limit {
-		return len(p), nil
+		if n > 0 {
+			lw.truncated = true
+		}
+		return n, nil
 	}
 
-	remaining := lw.limit - lw.written
-	if len(p) > remaining {
+	if remaining := lw.limit - lw.written; len(p) > remaining {
 		p = p[:remaining]
+		lw.truncated = true
 	}
 
-	n, err := lw.w.Write(p)
-	lw.written += n
-	return n, err
+	written, err := lw.w.Write(p)
+	lw.written += written
+	if err != nil {
+		return written, err
+	}
+	return n, nil
 }
@@ -0,0 +1,42 @@
+//go:build linux
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// processUsage reads CPU time and how the process ended from its wait status.
+// Peak RSS is left to the cgroup: ru_maxrss keeps the high-water mark of the
+// runner itself, whose address space the child shares until exec.
+func processUsage(state *os.ProcessState) Usage {
+	var u Usage
+	if state == nil {
+		return u
+	}
+
+	u.CPUUserMs = state.UserTime().Milliseconds()
+	u.CPUSysMs = state.SystemTime().Milliseconds()
+
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		u.Signal = unix.SignalName(ws.Signal())
+		return u
+	}
+	code := state.ExitCode()
+	u.ExitCode = &code
+	return u
+}
+
+// killGroupOnCancel starts cmd in its own process group and kills the whole
+// group when its context is done, so a program outlives neither a killed
+// wrapper nor the shell that started it.
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
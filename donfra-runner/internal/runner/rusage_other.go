@@ -0,0 +1,28 @@
+//go:build !linux
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+)
+
+// processUsage reads CPU time and the exit code of a finished process. The
+// killing signal is only reported on Linux.
+func processUsage(state *os.ProcessState) Usage {
+	var u Usage
+	if state == nil {
+		return u
+	}
+
+	u.CPUUserMs = state.UserTime().Milliseconds()
+	u.CPUSysMs = state.SystemTime().Milliseconds()
+	if code := state.ExitCode(); code >= 0 {
+		u.ExitCode = &code
+	}
+	return u
+}
+
+// killGroupOnCancel leaves cmd's cancellation as is: only the Linux build
+// kills the process group.
+func killGroupOnCancel(cmd *exec.Cmd) {}
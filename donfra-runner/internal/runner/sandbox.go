@@ -0,0 +1,39 @@
+package runner
+
+// SandboxInitCommand is the argument the runner binary is re-executed with to
+// set up the sandbox from inside the new namespaces before exec'ing user code.
+const SandboxInitCommand = "sandbox-init"
+
+// sandboxWorkDir is where the program's work dir is mounted inside the sandbox.
+const sandboxWorkDir = "/box"
+
+// SandboxConfig controls the namespace/cgroup jail used in JailSandbox mode.
+type SandboxConfig struct {
+	// CgroupRoot is a cgroup v2 directory the runner may create children in.
+	// The memory, cpu and pids controllers must be available to it.
+	CgroupRoot string
+	// ReadOnlyPaths are host paths bind-mounted read-only into the sandbox root.
+	ReadOnlyPaths []string
+	// UID and GID the program runs as when the runner itself runs as root.
+	UID int
+	GID int
+	// PidsMax caps the number of tasks (fork bomb protection).
+	PidsMax int
+	// CPUMillis caps CPU bandwidth in millicores (1000 = one core).
+	CPUMillis int
+	// TmpfsMB sizes the private writable /tmp.
+	TmpfsMB int
+	// FileSizeMB caps the size of any file the program writes.
+	FileSizeMB int
+}
+
+// DefaultSandboxReadOnlyPaths are the host paths exposed to sandboxed programs.
+var DefaultSandboxReadOnlyPaths = []string{"/bin", "/sbin", "/usr", "/lib", "/lib64", "/etc"}
+
+// resourceUsage is what the jail could measure about a finished process.
+type resourceUsage struct {
+	MemoryKB  int64
+	CPUUserMs int64
+	CPUSysMs  int64
+	OOMKilled bool
+}
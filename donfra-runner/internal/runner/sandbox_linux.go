@@ -0,0 +1,465 @@
+//go:build linux
+
+package runner
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+)
+
+// sandboxSpecEnv carries the sandboxSpec from the runner to the re-executed helper.
+const sandboxSpecEnv = "DONFRA_SANDBOX_SPEC"
+
+// sandboxPath is the PATH programs see inside the sandbox.
+const sandboxPath = "/usr/local/sbin:/usr/local/bin:/usr/local/go/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// sandboxSpec is everything the helper needs to build the jail for one execution.
+type sandboxSpec struct {
+	Rootfs        string   `json:"rootfs"`
+	WorkDir       string   `json:"work_dir"`
+	Argv          []string `json:"argv"`
+	ReadOnlyPaths []string `json:"read_only_paths"`
+	DropPrivs     bool     `json:"drop_privs"`
+	UID           int      `json:"uid"`
+	GID           int      `json:"gid"`
+	TmpfsMB       int      `json:"tmpfs_mb"`
+	FileSizeMB    int      `json:"file_size_mb"`
+	CPUTimeSec    int      `json:"cpu_time_sec"`
+}
+
+// Sandbox runs programs in fresh mount, PID, network, IPC and UTS namespaces
+// under a per-execution cgroup v2 with memory, CPU and pids limits.
+type Sandbox struct {
+	cfg SandboxConfig
+}
+
+func NewSandbox(cfg SandboxConfig) (*Sandbox, error) {
+	if cfg.CgroupRoot == "" {
+		return nil, errors.New("sandbox: cgroup root is required")
+	}
+	if len(cfg.ReadOnlyPaths) == 0 {
+		cfg.ReadOnlyPaths = DefaultSandboxReadOnlyPaths
+	}
+	if cfg.UID == 0 {
+		cfg.UID = 65534
+	}
+	if cfg.GID == 0 {
+		cfg.GID = 65534
+	}
+	cfg.PidsMax = cmp.Or(cfg.PidsMax, 64)
+	cfg.CPUMillis = cmp.Or(cfg.CPUMillis, 1000)
+	cfg.TmpfsMB = cmp.Or(cfg.TmpfsMB, 64)
+	cfg.FileSizeMB = cmp.Or(cfg.FileSizeMB, 16)
+	if err := initCgroupRoot(cfg.CgroupRoot); err != nil {
+		return nil, fmt.Errorf("sandbox: cgroup root %s: %w", cfg.CgroupRoot, err)
+	}
+	return &Sandbox{cfg: cfg}, nil
+}
+
+// Command builds a command that runs argv inside the sandbox with workDir
+// mounted at sandboxWorkDir. argv must already refer to sandbox paths.
+// cpuMillis overrides the configured CPU share when positive. The returned
+// finish func must be called once the command has exited (or failed to
+// start); it reports resource usage and tears down the cgroup.
+func (s *Sandbox) Command(ctx context.Context, workDir string, argv []string, memoryMB, cpuMillis, timeoutMs int) (*exec.Cmd, func() resourceUsage, error) {
+	cgDir := filepath.Join(s.cfg.CgroupRoot, "exec-"+uuid.New().String())
+	if err := os.Mkdir(cgDir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("create cgroup: %w", err)
+	}
+
+	limits := map[string]string{
+		"pids.max": strconv.Itoa(s.cfg.PidsMax),
+		"cpu.max":  fmt.Sprintf("%d 100000", cmp.Or(cpuMillis, s.cfg.CPUMillis)*100),
+	}
+	if memoryMB > 0 {
+		limits["memory.max"] = strconv.Itoa(memoryMB * 1024 * 1024)
+	}
+	for file, value := range limits {
+		if err := os.WriteFile(filepath.Join(cgDir, file), []byte(value), 0); err != nil {
+			removeCgroup(cgDir)
+			return nil, nil, fmt.Errorf("set %s: %w", file, err)
+		}
+	}
+	// Without swap accounting the file is absent; memory.max still applies.
+	_ = os.WriteFile(filepath.Join(cgDir, "memory.swap.max"), []byte("0"), 0)
+
+	cgFD, err := os.Open(cgDir)
+	if err != nil {
+		removeCgroup(cgDir)
+		return nil, nil, fmt.Errorf("open cgroup: %w", err)
+	}
+
+	rootfs, err := os.MkdirTemp("", "sandbox-root-*")
+	if err != nil {
+		cgFD.Close()
+		removeCgroup(cgDir)
+		return nil, nil, fmt.Errorf("create rootfs: %w", err)
+	}
+
+	isRoot := os.Getuid() == 0
+	if isRoot {
+		if err := chownTree(workDir, s.cfg.UID, s.cfg.GID); err != nil {
+			cgFD.Close()
+			removeCgroup(cgDir)
+			os.RemoveAll(rootfs)
+			return nil, nil, fmt.Errorf("chown work dir: %w", err)
+		}
+	}
+
+	spec, _ := json.Marshal(sandboxSpec{
+		Rootfs:        rootfs,
+		WorkDir:       workDir,
+		Argv:          argv,
+		ReadOnlyPaths: s.cfg.ReadOnlyPaths,
+		DropPrivs:     isRoot,
+		UID:           s.cfg.UID,
+		GID:           s.cfg.GID,
+		TmpfsMB:       s.cfg.TmpfsMB,
+		FileSizeMB:    s.cfg.FileSizeMB,
+		CPUTimeSec:    int(math.Ceil(float64(timeoutMs)/1000.0)) + 1,
+	})
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", SandboxInitCommand)
+	cmd.Env = []string{sandboxSpecEnv + "=" + string(spec)}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET |
+			syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		Pdeathsig:   syscall.SIGKILL,
+		UseCgroupFD: true,
+		CgroupFD:    int(cgFD.Fd()),
+	}
+	if !isRoot {
+		// Rootless: a user namespace maps the runner's uid to root inside the
+		// sandbox, which is enough to build the mount namespace.
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	}
+
+	finish := func() resourceUsage {
+		usage := resourceUsage{
+			MemoryKB:  readCgroupInt(filepath.Join(cgDir, "memory.peak")) / 1024,
+			OOMKilled: cgroupOOMKills(cgDir) > 0,
+		}
+		usage.CPUUserMs, usage.CPUSysMs = cgroupCPUTime(cgDir)
+		cgFD.Close()
+		removeCgroup(cgDir)
+		os.RemoveAll(rootfs)
+		return usage
+	}
+
+	return cmd, finish, nil
+}
+
+// RunSandboxInit is the entry point of the re-executed helper. It runs as PID 1
+// of the new namespaces, builds the jail and replaces itself with the program.
+// It never returns.
+func RunSandboxInit() {
+	// Seccomp filters and no_new_privs are per-thread; keep setup and exec on one thread.
+	runtime.LockOSThread()
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Getenv(sandboxSpecEnv)), &spec); err != nil {
+		sandboxFail(fmt.Errorf("decode spec: %w", err))
+	}
+	if len(spec.Argv) == 0 {
+		sandboxFail(errors.New("empty argv"))
+	}
+
+	if err := setupSandboxRoot(spec); err != nil {
+		sandboxFail(err)
+	}
+	if err := setSandboxRlimits(spec); err != nil {
+		sandboxFail(err)
+	}
+	if spec.DropPrivs {
+		if err := unix.Setgroups(nil); err != nil {
+			sandboxFail(fmt.Errorf("setgroups: %w", err))
+		}
+		if err := unix.Setresgid(spec.GID, spec.GID, spec.GID); err != nil {
+			sandboxFail(fmt.Errorf("setgid: %w", err))
+		}
+		if err := unix.Setresuid(spec.UID, spec.UID, spec.UID); err != nil {
+			sandboxFail(fmt.Errorf("setuid: %w", err))
+		}
+	}
+
+	env := []string{
+		"PATH=" + sandboxPath,
+		"HOME=/tmp",
+		"TMPDIR=/tmp",
+		"LANG=C.UTF-8",
+	}
+	os.Setenv("PATH", sandboxPath)
+	path, err := exec.LookPath(spec.Argv[0])
+	if err != nil {
+		sandboxFail(err)
+	}
+
+	if err := installSeccomp(); err != nil {
+		sandboxFail(err)
+	}
+	if err := unix.Exec(path, spec.Argv, env); err != nil {
+		sandboxFail(fmt.Errorf("exec %s: %w", path, err))
+	}
+}
+
+// setupSandboxRoot assembles a read-only root from the allowed host paths plus
+// a private /tmp, the work dir and minimal /dev, then pivots into it.
+func setupSandboxRoot(spec sandboxSpec) error {
+	// Keep every mount below from propagating back to the host.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make / private: %w", err)
+	}
+
+	root := spec.Rootfs
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=1m,mode=755"); err != nil {
+		return fmt.Errorf("mount root tmpfs: %w", err)
+	}
+
+	for _, p := range spec.ReadOnlyPaths {
+		if err := bindReadOnly(p, filepath.Join(root, p)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "dev"), 0755); err != nil {
+		return err
+	}
+	for _, dev := range []string{"null", "zero", "random", "urandom"} {
+		dst := filepath.Join(root, "dev", dev)
+		if err := os.WriteFile(dst, nil, 0644); err != nil {
+			return err
+		}
+		if err := unix.Mount("/dev/"+dev, dst, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind /dev/%s: %w", dev, err)
+		}
+	}
+
+	tmp := filepath.Join(root, "tmp")
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return err
+	}
+	tmpOpts := fmt.Sprintf("size=%dm,mode=1777", spec.TmpfsMB)
+	if err := unix.Mount("tmpfs", tmp, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, tmpOpts); err != nil {
+		return fmt.Errorf("mount /tmp: %w", err)
+	}
+
+	box := filepath.Join(root, sandboxWorkDir)
+	if err := os.MkdirAll(box, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount(spec.WorkDir, box, "", unix.MS_BIND|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("bind work dir: %w", err)
+	}
+
+	// /proc for the new PID namespace. Some container runtimes forbid mounting
+	// it from a nested namespace; programs run fine without it.
+	procDir := filepath.Join(root, "proc")
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		return err
+	}
+	_ = unix.Mount("proc", procDir, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.MkdirAll(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return err
+	}
+	if err := unix.Unmount("/.oldroot", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return err
+	}
+
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root read-only: %w", err)
+	}
+	if err := unix.Sethostname([]byte("sandbox")); err != nil {
+		return fmt.Errorf("sethostname: %w", err)
+	}
+	return unix.Chdir(sandboxWorkDir)
+}
+
+// bindReadOnly mirrors a host path into the sandbox root. Symlinks (e.g. /bin
+// on merged-/usr systems) are recreated rather than bind-mounted.
+func bindReadOnly(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount(src, dst, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", src, err)
+	}
+
+	// A read-only remount must keep flags the kernel has locked on the source.
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV)
+	var st unix.Statfs_t
+	if err := unix.Statfs(src, &st); err == nil {
+		if st.Flags&unix.ST_NOEXEC != 0 {
+			flags |= unix.MS_NOEXEC
+		}
+		if st.Flags&unix.ST_NOATIME != 0 {
+			flags |= unix.MS_NOATIME
+		}
+		if st.Flags&unix.ST_RELATIME != 0 {
+			flags |= unix.MS_RELATIME
+		}
+	}
+	if err := unix.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", src, err)
+	}
+	return nil
+}
+
+func setSandboxRlimits(spec sandboxSpec) error {
+	limits := map[int]uint64{
+		unix.RLIMIT_CORE:  0,
+		unix.RLIMIT_CPU:   uint64(spec.CPUTimeSec),
+		unix.RLIMIT_FSIZE: uint64(spec.FileSizeMB) * 1024 * 1024,
+	}
+	for resource, value := range limits {
+		if err := unix.Setrlimit(resource, &unix.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", resource, err)
+		}
+	}
+	return nil
+}
+
+func sandboxFail(err error) {
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(125)
+}
+
+// initCgroupRoot creates the runner's cgroup and delegates the memory, cpu and
+// pids controllers to its children.
+func initCgroupRoot(root string) error {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+
+	parent := filepath.Dir(root)
+	if err := enableCgroupControllers(parent); err != nil {
+		// cgroup v2 refuses to delegate controllers from a cgroup that has
+		// processes in it. In a container the runner usually sits in the
+		// parent itself, so move it into a leaf and try again.
+		leaf := filepath.Join(parent, "runner")
+		if mkErr := os.MkdirAll(leaf, 0755); mkErr != nil {
+			return err
+		}
+		if mvErr := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0); mvErr != nil {
+			return err
+		}
+		if err := enableCgroupControllers(parent); err != nil {
+			return err
+		}
+	}
+	return enableCgroupControllers(root)
+}
+
+func enableCgroupControllers(dir string) error {
+	return os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+cpu +memory +pids"), 0)
+}
+
+// removeCgroup kills anything left in the cgroup and removes it.
+func removeCgroup(dir string) {
+	_ = os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0)
+	for range 20 {
+		if err := os.Remove(dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readCgroupInt(path string) int64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	return n
+}
+
+// cgroupOOMKills returns the oom_kill counter from memory.events.
+func cgroupOOMKills(dir string) int64 {
+	b, err := os.ReadFile(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if v, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// cgroupCPUTime returns the user and system CPU time from cpu.stat in milliseconds.
+func cgroupCPUTime(dir string) (userMs, sysMs int64) {
+	b, err := os.ReadFile(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if v, ok := strings.CutPrefix(line, "user_usec "); ok {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			userMs = n / 1000
+		}
+		if v, ok := strings.CutPrefix(line, "system_usec "); ok {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			sysMs = n / 1000
+		}
+	}
+	return userMs, sysMs
+}
+
+func chownTree(dir string, uid, gid int) error {
+	return filepath.Walk(dir, func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errSandboxUnsupported = errors.New("sandbox jail mode requires Linux")
+
+// Sandbox is only available on Linux.
+type Sandbox struct{}
+
+func NewSandbox(cfg SandboxConfig) (*Sandbox, error) {
+	return nil, errSandboxUnsupported
+}
+
+func (s *Sandbox) Command(ctx context.Context, workDir string, argv []string, memoryMB, cpuMillis, timeoutMs int) (*exec.Cmd, func() resourceUsage, error) {
+	return nil, nil, errSandboxUnsupported
+}
+
+func RunSandboxInit() {
+	os.Stderr.WriteString("sandbox: " + errSandboxUnsupported.Error() + "\n")
+	os.Exit(125)
+}
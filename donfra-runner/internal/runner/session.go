@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"donfra-runner/internal/metrics"
+	"donfra-runner/internal/tracing"
+)
+
+// SessionRequest starts an interactive session: a program that reads stdin
+// as the client sends it and whose output is delivered as it is written.
+type SessionRequest struct {
+	SourceCode string `json:"source_code"`
+	LanguageID int    `json:"language_id"`
+
+	// REPL starts the language's interactive interpreter (ReplCmd) with
+	// SourceCode loaded instead of running it. SourceCode may then be empty.
+	REPL bool `json:"repl,omitempty"`
+
+	// CallerID and Priority place the session in the queue, as for
+	// ExecuteRequest.
+	CallerID string   `json:"caller_id,omitempty"`
+	Priority Priority `json:"priority,omitempty"`
+}
+
+// errSessionIdle cancels a session that went SessionIdleMs without input or output.
+var errSessionIdle = errors.New("session idle")
+
+// Session runs req until the program exits, input is closed and drained, or
+// one of the session limits ends it: SessionIdleMs without input or output,
+// SessionMaxMs in total or SessionCPUMs of CPU time. Each value received from
+// input is written to the program's stdin; closing input closes stdin. Output
+// goes to out only, up to SessionMaxOutputBytes per stream, so the result
+// carries the verdict and usage but no stdout or stderr.
+func (r *Runner) Session(ctx context.Context, req SessionRequest, input <-chan []byte, out OutputFunc) ExecuteResult {
+	lang, err := GetLanguage(req.LanguageID)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	switch {
+	case lang.Database != "":
+		return errorResult("SQL is not supported for sessions")
+	case req.REPL && len(lang.ReplCmd) == 0:
+		return errorResult(fmt.Sprintf("repl is not supported for language %d", lang.ID))
+	case !req.REPL && req.SourceCode == "":
+		return errorResult("source_code is required")
+	}
+	if err := ValidatePriority(req.Priority); err != nil {
+		return errorResult(err.Error())
+	}
+
+	if n := r.sessions.Add(1); n > int64(r.cfg.MaxSessions) {
+		r.sessions.Add(-1)
+		return ExecuteResult{
+			Status:  ExecuteStatus{ID: StatusRuntimeError, Description: "Queue Full"},
+			Message: "too many interactive sessions, try again later",
+		}
+	}
+	defer r.sessions.Add(-1)
+
+	ctx, done, ok := r.drain.begin(ctx)
+	if !ok {
+		return drainingResult()
+	}
+	defer done()
+
+	ctx, span := tracing.StartSpan(ctx, "runner.session",
+		tracing.AttrLanguage.String(lang.Name),
+		tracing.AttrJailMode.String(string(r.cfg.JailMode)),
+	)
+	defer span.End()
+
+	if err := r.acquire(ctx, Caller{ID: req.CallerID, Priority: req.Priority}); err != nil {
+		result := queueFullResult(err)
+		span.SetAttributes(tracing.AttrStatus.String(result.Status.Description))
+		metrics.ExecutionsTotal.WithLabelValues(lang.Name, result.Status.Description).Inc()
+		return result
+	}
+	defer r.limiter.Release()
+
+	metrics.SessionsActive.Inc()
+	defer metrics.SessionsActive.Dec()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	idle := time.Duration(r.cfg.SessionIdleMs) * time.Millisecond
+	var idleMu sync.Mutex
+	idleTimer := time.AfterFunc(idle, func() { cancel(errSessionIdle) })
+	defer idleTimer.Stop()
+	touch := func() {
+		idleMu.Lock()
+		defer idleMu.Unlock()
+		idleTimer.Reset(idle)
+	}
+
+	// stdin carries input on to the jail; it is closed once input is.
+	stdin := make(chan []byte)
+	go func() {
+		for {
+			select {
+			case data, ok := <-input:
+				if !ok {
+					close(stdin)
+					return
+				}
+				touch()
+				select {
+				case stdin <- data:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var outMu sync.Mutex
+	sent := map[string]int{}
+	truncated := map[string]bool{}
+	limited := func(chunk OutputChunk) {
+		touch()
+		outMu.Lock()
+		if remaining := r.cfg.SessionMaxOutputBytes - sent[chunk.Stream]; len(chunk.Data) > remaining {
+			chunk.Data = chunk.Data[:max(remaining, 0)]
+			truncated[chunk.Stream] = true
+		}
+		sent[chunk.Stream] += len(chunk.Data)
+		outMu.Unlock()
+		if chunk.Data != "" {
+			out(chunk)
+		}
+	}
+
+	if req.REPL {
+		lang.RunCmd = lang.ReplCmd
+	}
+	lang.RunCmd = sessionCmd(lang.RunCmd, r.cfg.SessionCPUMs)
+
+	start := time.Now()
+	result := r.executor.Session(ctx, lang, req, stdin, r.cfg.SessionMaxMs, limited)
+
+	result.Stdout, result.Stderr = "", ""
+	outMu.Lock()
+	result.StdoutTruncated = result.StdoutTruncated || truncated[StreamStdout]
+	result.StderrTruncated = result.StderrTruncated || truncated[StreamStderr]
+	outMu.Unlock()
+
+	tle := ExecuteStatus{ID: StatusTimeLimitExceeded, Description: "Time Limit Exceeded"}
+	switch {
+	case errors.Is(context.Cause(ctx), errSessionIdle):
+		result.Status = tle
+		result.Message = fmt.Sprintf("session closed after %ds without input or output", r.cfg.SessionIdleMs/1000)
+	case result.Signal == "SIGXCPU" || result.CPUUserMs+result.CPUSysMs >= int64(r.cfg.SessionCPUMs):
+		result.Status = tle
+		result.Message = fmt.Sprintf("session used its %ds of CPU time", cpuSeconds(r.cfg.SessionCPUMs))
+	case result.Status.ID == StatusTimeLimitExceeded:
+		result.Message = fmt.Sprintf("session exceeded its %ds time limit", r.cfg.SessionMaxMs/1000)
+	}
+
+	span.SetAttributes(tracing.AttrStatus.String(result.Status.Description))
+	metrics.RecordExecution(lang.Name, result.Status.Description, time.Since(start).Seconds())
+	return result
+}
+
+// feedStdin copies stdin into w until stdin is closed, a write fails or ctx
+// is done, then closes w.
+func feedStdin(ctx context.Context, w io.WriteCloser, stdin <-chan []byte) {
+	defer w.Close()
+	for {
+		select {
+		case data, ok := <-stdin:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sessionCmd wraps a run template in a shell that caps the program's CPU
+// time at cpuMs, and keeps Python from buffering output written to a pipe.
+// The soft limit sends SIGXCPU; the hard limit a second later kills
+// programs that ignore it, such as PID 1 of the sandbox's namespace.
+func sessionCmd(tmpl []string, cpuMs int) []string {
+	sec := cpuSeconds(cpuMs)
+	script := fmt.Sprintf(`ulimit -S -t %d && ulimit -H -t %d && exec "$@"`, sec, sec+1)
+	return append([]string{"/bin/sh", "-c", script, "sh", "/usr/bin/env", "PYTHONUNBUFFERED=1"}, tmpl...)
+}
+
+// cpuSeconds rounds a CPU time budget up to whole seconds, RLIMIT_CPU's unit.
+func cpuSeconds(ms int) int {
+	return int(math.Ceil(float64(ms) / 1000))
+}
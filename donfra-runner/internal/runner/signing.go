@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Messages between the runner and jail pods travel over Redis, which other
+// workloads can reach. Each message is sent as "<hex HMAC-SHA256> <body>":
+// results and output chunks are signed with a random per-execution key handed
+// to the jail, and warm pool jobs with a per-pod key derived from the runner's
+// signing secret. Payloads, which carry the user's code and input, are also
+// encrypted with the execution's key.
+
+// newExecKey returns a random key for signing one execution's messages.
+func newExecKey() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// signMessage returns body prefixed with its signature under key.
+func signMessage(key, body string) string {
+	return macHex(key, body) + " " + body
+}
+
+// openMessage verifies a signed message and returns its body.
+func openMessage(key, msg string) (string, bool) {
+	sig, body, ok := strings.Cut(msg, " ")
+	if !ok {
+		return "", false
+	}
+	want := macHex(key, body)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return "", false
+	}
+	return body, true
+}
+
+func macHex(key, body string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sealIterations is the PBKDF2 iteration count of sealMessage, openssl enc's
+// default.
+const sealIterations = 10000
+
+// sealMessage encrypts body with AES-256-CTR under a key and IV derived from
+// key by PBKDF2-SHA256, in the base64 "Salted__" format that
+// "openssl enc -d -aes-256-ctr -pbkdf2 -a -A" decrypts.
+func sealMessage(key, body string) string {
+	salt := make([]byte, 8)
+	rand.Read(salt)
+	derived, _ := pbkdf2.Key(sha256.New, key, salt, sealIterations, 32+aes.BlockSize)
+	block, _ := aes.NewCipher(derived[:32])
+
+	out := make([]byte, 16+len(body))
+	copy(out, "Salted__")
+	copy(out[8:], salt)
+	cipher.NewCTR(block, derived[32:]).XORKeyStream(out[16:], []byte(body))
+	return base64.StdEncoding.EncodeToString(out)
+}
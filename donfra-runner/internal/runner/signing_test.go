@@ -0,0 +1,127 @@
+package runner
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestOpenMessage(t *testing.T) {
+	key := newExecKey()
+	body := `{"status":"ok","stdout":"a b\n"}`
+	msg := signMessage(key, body)
+	sig, _, _ := strings.Cut(msg, " ")
+
+	tests := []struct {
+		name   string
+		key    string
+		msg    string
+		wantOK bool
+	}{
+		{"signed", key, msg, true},
+		{"empty body", key, signMessage(key, ""), true},
+		{"tampered body", key, sig + " " + strings.Replace(body, "ok", "no", 1), false},
+		{"extra byte", key, msg + " ", false},
+		{"tampered signature", key, strings.Repeat("0", len(sig)) + " " + body, false},
+		{"short signature", key, sig[:len(sig)-2] + " " + body, false},
+		{"other key", newExecKey(), msg, false},
+		{"no signature", key, body, false},
+		{"no separator", key, sig, false},
+		{"empty", key, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := openMessage(tt.key, tt.msg)
+			if ok != tt.wantOK {
+				t.Fatalf("openMessage() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got != strings.SplitN(tt.msg, " ", 2)[1] {
+				t.Errorf("openMessage() = %q", got)
+			}
+			if !ok && got != "" {
+				t.Errorf("rejected message returned body %q", got)
+			}
+		})
+	}
+}
+
+func TestNewExecKey(t *testing.T) {
+	a, b := newExecKey(), newExecKey()
+	if len(a) != 64 || a == b {
+		t.Errorf("keys %q and %q, want distinct 32-byte hex keys", a, b)
+	}
+}
+
+// unseal reverses sealMessage the way openssl enc -d does.
+func unseal(t *testing.T, key, sealed string) string {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatalf("sealed message is not base64: %v", err)
+	}
+	if len(raw) < 16 || string(raw[:8]) != "Salted__" {
+		t.Fatalf("sealed message has no salt header: %q", raw)
+	}
+	derived, err := pbkdf2.Key(sha256.New, key, raw[8:16], sealIterations, 32+aes.BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, len(raw)-16)
+	cipher.NewCTR(block, derived[32:]).XORKeyStream(out, raw[16:])
+	return string(out)
+}
+
+func TestSealMessage(t *testing.T) {
+	key := newExecKey()
+	bodies := []string{
+		"",
+		`{"source_code":"print(input())","stdin":"1"}`,
+		strings.Repeat("long body ", 1000),
+		"binary \x00\xff and unicode ✓",
+	}
+	for _, body := range bodies {
+		sealed := sealMessage(key, body)
+		if got := unseal(t, key, sealed); got != body {
+			t.Errorf("unseal(sealMessage(%.20q)) = %.20q", body, got)
+		}
+	}
+
+	// A fresh salt each time: the same body never seals the same way twice.
+	if sealMessage(key, "x") == sealMessage(key, "x") {
+		t.Error("sealing the same body twice gave the same message")
+	}
+	if unseal(t, newExecKey(), sealMessage(key, "secret")) == "secret" {
+		t.Error("another key opened the message")
+	}
+}
+
+// TestSealMessageOpenSSL checks that the jail's openssl command decrypts
+// sealed payloads.
+func TestSealMessageOpenSSL(t *testing.T) {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl not installed")
+	}
+	key := newExecKey()
+	body := `{"source_code":"print(1)","stdin":"line 1\nline 2\n"}`
+
+	cmd := exec.Command("openssl", "enc", "-d", "-aes-256-ctr", "-pbkdf2", "-a", "-A", "-pass", "env:RESULT_KEY")
+	cmd.Env = append(os.Environ(), "RESULT_KEY="+key)
+	cmd.Stdin = strings.NewReader(sealMessage(key, body))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("openssl: %v", err)
+	}
+	if string(out) != body {
+		t.Errorf("openssl decrypted %q, want %q", out, body)
+	}
+}
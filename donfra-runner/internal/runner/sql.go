@@ -0,0 +1,242 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sqliteHarness runs SQL programs against an in-memory SQLite database
+// through Python's sqlite3 module.
+//
+//go:embed sqlite_harness.py
+var sqliteHarness string
+
+// sqlHarnesses run a SQL program against a fresh database and report its
+// result set as JSON. Languages name theirs in Database.
+var sqlHarnesses = map[string]string{
+	"sqlite": sqliteHarness,
+}
+
+// maxResultRows caps the rows returned from a SQL program's result set.
+const maxResultRows = 1000
+
+// ResultSet is the rows returned by the last statement of a SQL program that
+// returned any. Values are JSON null, numbers or strings; blobs are written
+// as X'hex' literals and non-finite reals as "Infinity", "-Infinity" or "NaN".
+type ResultSet struct {
+	Columns   []string            `json:"columns"`
+	Rows      [][]json.RawMessage `json:"rows"`
+	Truncated bool                `json:"truncated,omitempty"` // more than maxResultRows rows or MaxOutputBytes of JSON
+}
+
+// ExpectedResult grades a SQL program by its result set. Numbers compare by
+// value, so 1 matches 1.0; reals should be rounded in the query.
+type ExpectedResult struct {
+	// Columns, when set, must match the result's column names, ignoring case.
+	Columns []string            `json:"columns,omitempty"`
+	Rows    [][]json.RawMessage `json:"rows"`
+	// Ordered requires the rows in the given order, for queries with ORDER BY.
+	Ordered bool `json:"ordered,omitempty"`
+}
+
+// validateSQL checks the SQL fields of req against lang.
+func validateSQL(lang Language, req ExecuteRequest) error {
+	if lang.Database == "" {
+		if req.Schema != "" || req.ExpectedResult != nil {
+			return errors.New("schema and expected_result require a SQL language")
+		}
+		return nil
+	}
+	if req.Mode != ModeRun {
+		return fmt.Errorf("mode %q is not supported for SQL", req.Mode)
+	}
+	if len(req.TestCases) > 0 {
+		return errors.New("test_cases are not supported for SQL; use expected_result")
+	}
+	if req.ExpectedResult != nil {
+		if _, err := rowKeys(req.ExpectedResult.Rows); err != nil {
+			return fmt.Errorf("expected_result: %w", err)
+		}
+	}
+	return nil
+}
+
+// runSQL runs req's program with lang's SQL harness, after req.Schema, and
+// grades the result set against req.ExpectedResult when set. Stdout holds
+// the result set as text for clients that do not read ResultSet.
+func (r *Runner) runSQL(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int) ExecuteResult {
+	wrapped := req
+	wrapped.SourceCode = fmt.Sprintf("%s\nmain(%q, %q, %d, %d)\n", sqlHarnesses[lang.Database],
+		base64.StdEncoding.EncodeToString([]byte(req.Schema)),
+		base64.StdEncoding.EncodeToString([]byte(req.SourceCode)),
+		r.cfg.MaxOutputBytes, maxResultRows)
+	result := r.run(ctx, lang, wrapped, timeoutMs, nil)
+
+	var doc struct {
+		Result    *ResultSet `json:"result"`
+		Error     string     `json:"error"`
+		Stage     string     `json:"stage"`
+		Statement int        `json:"statement"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &doc); err != nil {
+		// The harness was killed at the time or memory limit.
+		result.Stdout, result.StdoutTruncated = "", false
+		if result.Status.ID == StatusAccepted {
+			result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+			result.Message = "database exited before its result was written"
+		}
+		return result
+	}
+	result.Stdout = ""
+	if doc.Error != "" {
+		result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+		result.Message = fmt.Sprintf("%s statement %d failed", doc.Stage, doc.Statement)
+		result.Stderr = fmt.Sprintf("Error in %s statement %d: %s\n", doc.Stage, doc.Statement, doc.Error)
+		return result
+	}
+	if doc.Result != nil {
+		result.ResultSet = doc.Result
+		result.Stdout = formatResultSet(*doc.Result)
+	}
+	if req.ExpectedResult != nil {
+		if msg := compareResultSet(*req.ExpectedResult, doc.Result); msg != "" {
+			result.Status = ExecuteStatus{ID: StatusWrongAnswer, Description: "Wrong Answer"}
+			result.Message = msg
+		} else {
+			result.Message = "result set matches"
+		}
+	}
+	return result
+}
+
+// compareResultSet describes how got differs from want, or returns "" when
+// it matches.
+func compareResultSet(want ExpectedResult, got *ResultSet) string {
+	if got == nil {
+		return "no statement returned rows"
+	}
+	if got.Truncated {
+		return fmt.Sprintf("result set has more than %d rows", len(got.Rows))
+	}
+	if len(want.Columns) > 0 && !slices.EqualFunc(want.Columns, got.Columns, strings.EqualFold) {
+		return fmt.Sprintf("expected columns (%s), got (%s)", strings.Join(want.Columns, ", "), strings.Join(got.Columns, ", "))
+	}
+	if len(want.Rows) != len(got.Rows) {
+		return fmt.Sprintf("expected %d rows, got %d", len(want.Rows), len(got.Rows))
+	}
+	wantKeys, _ := rowKeys(want.Rows)
+	gotKeys, err := rowKeys(got.Rows)
+	if err != nil {
+		return err.Error()
+	}
+	if want.Ordered {
+		for i := range wantKeys {
+			if wantKeys[i] != gotKeys[i] {
+				return fmt.Sprintf("row %d: expected %s, got %s", i+1, formatRow(want.Rows[i], ", "), formatRow(got.Rows[i], ", "))
+			}
+		}
+		return ""
+	}
+	remaining := make(map[string]int, len(wantKeys))
+	for _, k := range wantKeys {
+		remaining[k]++
+	}
+	for i, k := range gotKeys {
+		if remaining[k] == 0 {
+			return fmt.Sprintf("unexpected row %s", formatRow(got.Rows[i], ", "))
+		}
+		remaining[k]--
+	}
+	return ""
+}
+
+// rowKeys returns a comparison key per row.
+func rowKeys(rows [][]json.RawMessage) ([]string, error) {
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		cells := make([]string, len(row))
+		for j, raw := range row {
+			k, err := cellKey(raw)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", i+1, err)
+			}
+			cells[j] = k
+		}
+		keys[i] = strings.Join(cells, "\x00")
+	}
+	return keys, nil
+}
+
+// cellKey returns a key that is equal for equal values: numbers by value,
+// booleans as 0 and 1, as SQLite stores them.
+func cellKey(raw json.RawMessage) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		if v {
+			return "n:1", nil
+		}
+		return "n:0", nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return "", err
+		}
+		// Integers beyond float precision compare exactly.
+		if math.Abs(f) >= 1<<53 && !strings.ContainsAny(v.String(), ".eE") {
+			return "n:" + strings.TrimPrefix(v.String(), "+"), nil
+		}
+		return "n:" + strconv.FormatFloat(f, 'g', -1, 64), nil
+	case string:
+		return "s:" + v, nil
+	default:
+		return "", errors.New("values must be null, numbers, booleans or strings")
+	}
+}
+
+// formatResultSet renders rs as a header line and one line per row, with
+// cells separated by " | ".
+func formatResultSet(rs ResultSet) string {
+	var b strings.Builder
+	b.WriteString(strings.Join(rs.Columns, " | ") + "\n")
+	for _, row := range rs.Rows {
+		b.WriteString(formatRow(row, " | ") + "\n")
+	}
+	if rs.Truncated {
+		fmt.Fprintf(&b, "(showing the first %d rows)\n", len(rs.Rows))
+	}
+	return b.String()
+}
+
+// formatRow renders a row's cells, with strings unquoted and NULL for null.
+func formatRow(row []json.RawMessage, sep string) string {
+	cells := make([]string, len(row))
+	for i, raw := range row {
+		var s string
+		switch {
+		case string(raw) == "null":
+			cells[i] = "NULL"
+		case json.Unmarshal(raw, &s) == nil:
+			cells[i] = s
+		default:
+			cells[i] = string(raw)
+		}
+	}
+	return strings.Join(cells, sep)
+}
@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// sqlRows decodes a JSON array of rows.
+func sqlRows(t *testing.T, s string) [][]json.RawMessage {
+	t.Helper()
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &rows); err != nil {
+		t.Fatalf("bad rows %s: %v", s, err)
+	}
+	return rows
+}
+
+func TestCompareResultSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ExpectedResult
+		got     *ResultSet
+		wantMsg string // substring; empty means a match
+	}{
+		{
+			name: "same rows",
+			want: ExpectedResult{Rows: sqlRows(t, `[[1,"a"],[2,"b"]]`)},
+			got:  &ResultSet{Columns: []string{"id", "name"}, Rows: sqlRows(t, `[[1,"a"],[2,"b"]]`)},
+		},
+		{
+			name: "unordered rows in any order",
+			want: ExpectedResult{Rows: sqlRows(t, `[[1,"a"],[2,"b"]]`)},
+			got:  &ResultSet{Rows: sqlRows(t, `[[2,"b"],[1,"a"]]`)},
+		},
+		{
+			name:    "ordered rows out of order",
+			want:    ExpectedResult{Rows: sqlRows(t, `[[1],[2]]`), Ordered: true},
+			got:     &ResultSet{Rows: sqlRows(t, `[[2],[1]]`)},
+			wantMsg: "row 1: expected 1, got 2",
+		},
+		{
+			name: "numbers compare by value",
+			want: ExpectedResult{Rows: sqlRows(t, `[[1, 2.5, 100]]`)},
+			got:  &ResultSet{Rows: sqlRows(t, `[[1.0, 2.50, 1e2]]`)},
+		},
+		{
+			name: "booleans are 0 and 1",
+			want: ExpectedResult{Rows: sqlRows(t, `[[true, false]]`)},
+			got:  &ResultSet{Rows: sqlRows(t, `[[1, 0]]`)},
+		},
+		{
+			name:    "number is not a string",
+			want:    ExpectedResult{Rows: sqlRows(t, `[["1"]]`)},
+			got:     &ResultSet{Rows: sqlRows(t, `[[1]]`)},
+			wantMsg: "unexpected row 1",
+		},
+		{
+			name:    "null is not zero",
+			want:    ExpectedResult{Rows: sqlRows(t, `[[0]]`)},
+			got:     &ResultSet{Rows: sqlRows(t, `[[null]]`)},
+			wantMsg: "unexpected row NULL",
+		},
+		{
+			name:    "large integers compare exactly",
+			want:    ExpectedResult{Rows: sqlRows(t, `[[9007199254740993]]`)},
+			got:     &ResultSet{Rows: sqlRows(t, `[[9007199254740992]]`)},
+			wantMsg: "unexpected row",
+		},
+		{
+			name:    "duplicates count",
+			want:    ExpectedResult{Rows: sqlRows(t, `[[1],[1],[2]]`)},
+			got:     &ResultSet{Rows: sqlRows(t, `[[1],[2],[2]]`)},
+			wantMsg: "unexpected row 2",
+		},
+		{
+			name:    "row count",
+			want:    ExpectedResult{Rows: sqlRows(t, `[[1],[2]]`)},
+			got:     &ResultSet{Rows: sqlRows(t, `[[1]]`)},
+			wantMsg: "expected 2 rows, got 1",
+		},
+		{
+			name: "columns ignore case",
+			want: ExpectedResult{Columns: []string{"ID", "Name"}, Rows: sqlRows(t, `[[1,"a"]]`)},
+			got:  &ResultSet{Columns: []string{"id", "name"}, Rows: sqlRows(t, `[[1,"a"]]`)},
+		},
+		{
+			name:    "column names differ",
+			want:    ExpectedResult{Columns: []string{"id", "name"}, Rows: sqlRows(t, `[[1,"a"]]`)},
+			got:     &ResultSet{Columns: []string{"id", "title"}, Rows: sqlRows(t, `[[1,"a"]]`)},
+			wantMsg: "expected columns (id, name), got (id, title)",
+		},
+		{
+			name:    "column count differs",
+			want:    ExpectedResult{Columns: []string{"id"}, Rows: sqlRows(t, `[[1]]`)},
+			got:     &ResultSet{Columns: []string{"id", "name"}, Rows: sqlRows(t, `[[1]]`)},
+			wantMsg: "expected columns",
+		},
+		{
+			name:    "no result set",
+			want:    ExpectedResult{Rows: sqlRows(t, `[]`)},
+			wantMsg: "no statement returned rows",
+		},
+		{
+			name:    "truncated result",
+			want:    ExpectedResult{Rows: sqlRows(t, `[[1]]`)},
+			got:     &ResultSet{Rows: sqlRows(t, `[[1]]`), Truncated: true},
+			wantMsg: "more than 1 rows",
+		},
+		{
+			name:    "unsupported value",
+			want:    ExpectedResult{Rows: sqlRows(t, `[[1]]`)},
+			got:     &ResultSet{Rows: sqlRows(t, `[[[1]]]`)},
+			wantMsg: "row 1: values must be",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := compareResultSet(tt.want, tt.got)
+			switch {
+			case tt.wantMsg == "" && msg != "":
+				t.Errorf("compareResultSet() = %q, want a match", msg)
+			case tt.wantMsg != "" && !strings.Contains(msg, tt.wantMsg):
+				t.Errorf("compareResultSet() = %q, want it to contain %q", msg, tt.wantMsg)
+			}
+		})
+	}
+}
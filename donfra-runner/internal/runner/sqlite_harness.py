@@ -0,0 +1,92 @@
+"""Runs a SQL program against a fresh in-memory SQLite database.
+
+The runner appends a call to main() with the base64-encoded schema and
+program, the output budget in bytes and the row cap. The schema runs first,
+then the program, one statement at a time. Exactly one JSON document is
+written to stdout:
+
+  {"result": {"columns", "rows", "truncated"}}  the last statement that
+                                                returned rows
+  {}                                            no statement returned rows
+  {"error", "stage", "statement"}               a statement failed; stage is
+                                                "schema" or "query" and
+                                                statement counts from 1
+"""
+
+import base64
+import json
+import math
+import sqlite3
+import sys
+
+
+def statements(script):
+    """Splits script into complete statements, keeping semicolons in quotes
+    and comments intact."""
+    buf = ""
+    parts = script.split(";")
+    for part in parts[:-1]:
+        buf += part + ";"
+        if sqlite3.complete_statement(buf):
+            yield buf
+            buf = ""
+    buf += parts[-1]
+    if buf.strip():
+        yield buf
+
+
+def encode(value):
+    if isinstance(value, float) and not math.isfinite(value):
+        return "NaN" if math.isnan(value) else ("Infinity" if value > 0 else "-Infinity")
+    if isinstance(value, bytes):
+        return "X'" + value.hex().upper() + "'"
+    return value
+
+
+class StageError(Exception):
+    pass
+
+
+def run(conn, stage, script, budget, max_rows):
+    """Runs script and returns the rows of its last statement that returned
+    any, or None. Rows are fetched before the next statement runs, which
+    could otherwise find the table locked."""
+    result = None
+    for i, stmt in enumerate(statements(script), 1):
+        try:
+            cur = conn.execute(stmt)
+            if cur.description is not None:
+                result = collect(cur, budget, max_rows)
+        except (sqlite3.Error, sqlite3.Warning) as e:
+            raise StageError({"error": str(e), "stage": stage, "statement": i})
+    return result
+
+
+def collect(cur, budget, max_rows):
+    columns = [d[0] for d in cur.description]
+    rows, size, truncated = [], len(json.dumps(columns)), False
+    for row in cur:
+        encoded = [encode(v) for v in row]
+        size += len(json.dumps(encoded)) + 1
+        if len(rows) >= max_rows or size > budget:
+            truncated = True
+            break
+        rows.append(encoded)
+    cur.close()
+    return {"columns": columns, "rows": rows, "truncated": truncated}
+
+
+def main(schema_b64, program_b64, budget, max_rows):
+    schema = base64.b64decode(schema_b64).decode("utf-8")
+    program = base64.b64decode(program_b64).decode("utf-8")
+    # Autocommit, so BEGIN and COMMIT in the program behave as written.
+    conn = sqlite3.connect(":memory:", isolation_level=None)
+    conn.execute("PRAGMA foreign_keys = ON")
+    try:
+        run(conn, "schema", schema, budget // 2, max_rows)
+        result = run(conn, "query", program, budget // 2, max_rows)
+        doc = {"result": result} if result is not None else {}
+    except StageError as e:
+        doc = e.args[0]
+    sys.stdout.write(json.dumps(doc, allow_nan=False))
+    sys.stdout.flush()
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"context"
+	"time"
+)
+
+// Output stream names carried by OutputChunk.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// OutputChunk is a piece of program output delivered while the program runs.
+type OutputChunk struct {
+	Stream    string `json:"stream"`
+	Data      string `json:"data"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+}
+
+// OutputFunc receives output chunks. It may be called concurrently for
+// stdout and stderr, so implementations must be safe for concurrent use.
+type OutputFunc func(OutputChunk)
+
+// ExecuteStream runs req like Execute, delivering stdout/stderr to out as it
+// is produced. The returned result still carries the complete (capped) output.
+// Judge and visualize requests are not streamed.
+func (r *Runner) ExecuteStream(ctx context.Context, req ExecuteRequest, out OutputFunc) ExecuteResult {
+	if len(req.TestCases) > 0 {
+		return errorResult("test_cases are not supported for streaming execution")
+	}
+	if req.Mode != ModeRun {
+		return errorResult("mode is not supported for streaming execution")
+	}
+	if lang, err := GetLanguage(req.LanguageID); err == nil && lang.Database != "" {
+		return errorResult("SQL is not supported for streaming execution")
+	}
+	return r.execute(ctx, req, out)
+}
+
+// streamWriter forwards everything written to it as OutputChunks.
+type streamWriter struct {
+	stream string
+	out    OutputFunc
+	start  time.Time
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.out(OutputChunk{
+		Stream:    sw.stream,
+		Data:      string(p),
+		ElapsedMs: time.Since(sw.start).Milliseconds(),
+	})
+	return len(p), nil
+}
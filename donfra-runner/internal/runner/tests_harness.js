@@ -0,0 +1,451 @@
+// Unit-test harness for unit_tests mode (JavaScript).
+//
+// The runner appends a call to main() with the base64-encoded solution and
+// test file, so this file runs as the program itself, inside the jail and
+// under the language's usual limits. The test file can require the solution
+// as './solution' (or './main') and write tests with node:test's test, it,
+// describe and hooks. Those are implemented here rather than by node:test so
+// every test can be reported as it finishes. Simple import and export
+// statements in either file are rewritten to require and module.exports.
+//
+// Progress is written to stdout as JSON lines, in the same format as
+// tests_harness.py and likewise prefixed with the nonce read from the first
+// line of stdin. A solution or test file that fails to load prints its
+// error to stderr and exits 1.
+
+'use strict';
+
+const fs = require('fs');
+const Module = require('module');
+const path = require('path');
+
+const SOLUTION_FILE = 'solution.js';
+const TESTS_FILE = 'solution.test.js';
+
+const MAX_MESSAGE = 1000;
+const MAX_DETAILS = 4000;
+const FLUSH_AT = 4096; // buffered output is emitted once it reaches this size
+
+const originalLoad = Module._load;
+
+// --- events -----------------------------------------------------------------
+
+let eventPrefix = '';
+let outputBudget = 0;
+let outputUsed = 0;
+let outputTruncated = false;
+let pending = '';
+
+function write(event) {
+  fs.writeSync(1, eventPrefix + JSON.stringify(event) + '\n');
+}
+
+// readNonce reads the first line of stdin byte by byte, leaving the rest
+// for the code under test.
+function readNonce() {
+  const buf = Buffer.alloc(1);
+  let line = '';
+  while (fs.readSync(0, buf, 0, 1, null) === 1 && buf[0] !== 0x0a) {
+    line += String.fromCharCode(buf[0]);
+  }
+  return line;
+}
+
+function emit(event) {
+  flushOutput();
+  write(event);
+}
+
+function output(s) {
+  const room = outputBudget - outputUsed;
+  if (s.length > room) {
+    s = s.slice(0, Math.max(room, 0));
+    if (!outputTruncated) {
+      outputTruncated = true;
+      flushOutput();
+      write({ stdout_truncated: true });
+    }
+  }
+  pending += s;
+  outputUsed += s.length;
+  if (pending.length >= FLUSH_AT) {
+    flushOutput();
+  }
+}
+
+function flushOutput() {
+  if (pending) {
+    write({ stdout: pending });
+    pending = '';
+  }
+}
+
+function captureStdout() {
+  process.stdout.write = (chunk, encoding, callback) => {
+    output(typeof chunk === 'string' ? chunk : Buffer.from(chunk).toString());
+    const done = typeof encoding === 'function' ? encoding : callback;
+    if (done) {
+      process.nextTick(done);
+    }
+    return true;
+  };
+}
+
+// --- node:test stand-in -----------------------------------------------------
+
+function makeSuite(name, parent, options) {
+  return {
+    name,
+    parent,
+    options,
+    children: [],
+    hooks: { before: [], after: [], beforeEach: [], afterEach: [] },
+  };
+}
+
+const root = makeSuite('', null, {});
+let current = root;
+
+function parseArgs(name, options, fn) {
+  if (typeof name === 'function') {
+    return { name: name.name || '<anonymous>', options: {}, fn: name };
+  }
+  if (typeof options === 'function') {
+    return { name: String(name), options: {}, fn: options };
+  }
+  return { name: String(name), options: options || {}, fn };
+}
+
+function test(name, options, fn) {
+  current.children.push({ kind: 'test', ...parseArgs(name, options, fn) });
+  return Promise.resolve();
+}
+
+function describe(name, options, fn) {
+  const args = parseArgs(name, options, fn);
+  const suite = makeSuite(args.name, current, args.options);
+  current.children.push({ kind: 'suite', suite });
+  const parent = current;
+  current = suite;
+  try {
+    if (args.fn) {
+      args.fn();
+    }
+  } catch (err) {
+    suite.children.push({ kind: 'error', name: '(describe)', err });
+  } finally {
+    current = parent;
+  }
+  return Promise.resolve();
+}
+
+function withOption(register, option) {
+  return (name, options, fn) => {
+    const args = parseArgs(name, options, fn);
+    return register(args.name, { ...args.options, [option]: true }, args.fn);
+  };
+}
+
+function hook(kind) {
+  return (fn) => {
+    current.hooks[kind].push(fn);
+  };
+}
+
+test.skip = withOption(test, 'skip');
+test.todo = withOption(test, 'todo');
+test.only = test;
+describe.skip = withOption(describe, 'skip');
+describe.todo = withOption(describe, 'todo');
+describe.only = describe;
+
+const testModule = Object.assign(test, {
+  test,
+  it: test,
+  describe,
+  suite: describe,
+  before: hook('before'),
+  after: hook('after'),
+  beforeEach: hook('beforeEach'),
+  afterEach: hook('afterEach'),
+  default: test,
+});
+// mock is node:test's own; loading node:test does not start its runner.
+Object.defineProperty(testModule, 'mock', { get: () => originalLoad('node:test', module, false).mock });
+
+// --- running ----------------------------------------------------------------
+
+// failCurrent fails the running test with an error thrown outside of it,
+// such as one from a timer callback.
+let failCurrent = null;
+
+function onStrayError(err) {
+  if (failCurrent) {
+    failCurrent(err);
+  } else {
+    process.stderr.write(String((err && err.stack) || err) + '\n');
+  }
+}
+
+async function invoke(fn, ctx) {
+  if (fn.length >= 2) {
+    return new Promise((resolve, reject) => {
+      fn(ctx, (err) => (err ? reject(err) : resolve()));
+    });
+  }
+  return fn(ctx);
+}
+
+// relativize strips the working directory from stack frames, so they name
+// solution.js and solution.test.js as the Python harness does.
+function relativize(line) {
+  return line.split(process.cwd() + path.sep).join('');
+}
+
+function describeError(err) {
+  if (!(err instanceof Error)) {
+    const message = 'thrown: ' + String(err);
+    return { message: message.slice(0, MAX_MESSAGE), details: message.slice(0, MAX_DETAILS) };
+  }
+  const header = `${err.name}: ${err.message}`;
+  const frames = String(err.stack || '')
+    .split('\n')
+    .filter((line) => /^\s+at /.test(line) && (line.includes(SOLUTION_FILE) || line.includes(TESTS_FILE)))
+    .map(relativize);
+  return {
+    message: header.slice(0, MAX_MESSAGE),
+    details: [header, ...frames].join('\n').slice(0, MAX_DETAILS),
+  };
+}
+
+function failureStatus(err) {
+  return err && (err.code === 'ERR_ASSERTION' || err.name === 'AssertionError') ? 'failed' : 'error';
+}
+
+function report(name, status, message, details, ms) {
+  emit({ test: { name, status, message, details, duration_ms: Math.round(ms * 1000) / 1000 } });
+}
+
+function skipped(suite) {
+  for (let s = suite; s; s = s.parent) {
+    if (s.options.skip || s.options.todo) {
+      return true;
+    }
+  }
+  return false;
+}
+
+function hooksFor(suite, kind) {
+  const chain = [];
+  for (let s = suite; s; s = s.parent) {
+    chain.unshift(s);
+  }
+  const hooks = chain.flatMap((s) => s.hooks[kind]);
+  return kind === 'afterEach' ? hooks.reverse() : hooks;
+}
+
+function makeContext(name, subtests) {
+  const ctx = {
+    name,
+    skipReason: null,
+    skip(message) {
+      ctx.skipReason = message || '';
+    },
+    todo(message) {
+      ctx.skipReason = message || 'todo';
+    },
+    diagnostic() {},
+    plan() {},
+    test(subName, options, fn) {
+      const args = parseArgs(subName, options, fn);
+      const run = runTest(args, null, `${name} > ${args.name}`);
+      subtests.push(run);
+      return run.then(() => undefined);
+    },
+  };
+  return ctx;
+}
+
+// runTest runs one test and its subtests and reports whether it passed or
+// was skipped. A test fails when any of its subtests does, as in node:test.
+async function runTest(t, suite, name) {
+  if (t.options.skip || t.options.todo || (suite && skipped(suite))) {
+    const reason = typeof t.options.skip === 'string' ? t.options.skip : t.options.todo ? 'todo' : '';
+    report(name, 'skipped', reason, '', 0);
+    return true;
+  }
+
+  emit({ start: name });
+  const started = performance.now();
+  const subtests = [];
+  const ctx = makeContext(name, subtests);
+  let status = 'passed';
+  let failure = { message: '', details: '' };
+  const fail = (err) => {
+    if (status === 'passed') {
+      status = failureStatus(err);
+      failure = describeError(err);
+    }
+  };
+
+  const outer = failCurrent;
+  try {
+    await new Promise((resolve, reject) => {
+      failCurrent = reject;
+      (async () => {
+        for (const h of suite ? hooksFor(suite, 'beforeEach') : []) {
+          await invoke(h, ctx);
+        }
+        if (t.fn) {
+          await invoke(t.fn, ctx);
+        }
+        const failed = (await Promise.all(subtests)).filter((ok) => !ok).length;
+        if (failed > 0 && status === 'passed') {
+          status = 'failed';
+          failure = { message: `${failed} subtest${failed === 1 ? '' : 's'} failed`, details: '' };
+        }
+      })().then(resolve, reject);
+    });
+  } catch (err) {
+    fail(err);
+  } finally {
+    failCurrent = outer;
+  }
+  for (const h of suite ? hooksFor(suite, 'afterEach') : []) {
+    try {
+      await invoke(h, ctx);
+    } catch (err) {
+      fail(err);
+    }
+  }
+
+  if (status === 'passed' && ctx.skipReason !== null) {
+    status = 'skipped';
+    failure = { message: ctx.skipReason, details: '' };
+  }
+  report(name, status, failure.message, failure.details, performance.now() - started);
+  return status === 'passed' || status === 'skipped';
+}
+
+async function runSuite(suite, prefix) {
+  const names = suite.name ? [...prefix, suite.name] : prefix;
+  const label = (name) => [...names, name].join(' > ');
+  const active = !skipped(suite);
+
+  for (const h of active ? suite.hooks.before : []) {
+    try {
+      await invoke(h, makeContext(label('(before)'), []));
+    } catch (err) {
+      const { message, details } = describeError(err);
+      report(label('(before)'), failureStatus(err), message, details, 0);
+      return;
+    }
+  }
+
+  // Tests may register more tests while they run, so re-read the length.
+  for (let i = 0; i < suite.children.length; i++) {
+    const child = suite.children[i];
+    if (child.kind === 'suite') {
+      await runSuite(child.suite, names);
+    } else if (child.kind === 'error') {
+      const { message, details } = describeError(child.err);
+      report(label(child.name), 'error', message, details, 0);
+    } else {
+      await runTest(child, suite, label(child.name));
+    }
+  }
+
+  for (const h of active ? suite.hooks.after : []) {
+    try {
+      await invoke(h, makeContext(label('(after)'), []));
+    } catch (err) {
+      const { message, details } = describeError(err);
+      report(label('(after)'), failureStatus(err), message, details, 0);
+    }
+  }
+}
+
+// --- loading ----------------------------------------------------------------
+
+// toCommonJS rewrites the usual forms of import and export statements,
+// keeping every statement on its line so stack traces stay accurate.
+function toCommonJS(source) {
+  const from = `\\s+from\\s+(['"][^'"]+['"])\\s*;?`;
+  const exported = [];
+  const names = (list) => list.replace(/([\w$]+)\s+as\s+([\w$]+)/g, '$1: $2');
+  const body = source
+    .replace(new RegExp(`^([ \\t]*)import\\s+([\\w$]+)\\s*,\\s*\\{([^}]*)\\}${from}`, 'gm'),
+      (m, ind, def, list, mod) => `${ind}const ${def} = __default(require(${mod})), {${names(list)}} = require(${mod});`)
+    .replace(new RegExp(`^([ \\t]*)import\\s*\\{([^}]*)\\}${from}`, 'gm'),
+      (m, ind, list, mod) => `${ind}const {${names(list)}} = require(${mod});`)
+    .replace(new RegExp(`^([ \\t]*)import\\s*\\*\\s*as\\s+([\\w$]+)${from}`, 'gm'), '$1const $2 = require($3);')
+    .replace(new RegExp(`^([ \\t]*)import\\s+([\\w$]+)${from}`, 'gm'), '$1const $2 = __default(require($3));')
+    .replace(/^([ \t]*)import\s*(['"][^'"]+['"])\s*;?/gm, '$1require($2);')
+    .replace(/^([ \t]*)export\s+default\s+/gm, '$1module.exports.default = ')
+    .replace(/^([ \t]*)export\s+((?:async\s+)?function\*?|class|const|let|var)\s+([\w$]+)/gm, (m, ind, kind, name) => {
+      exported.push([name, name]);
+      return `${ind}${kind} ${name}`;
+    })
+    .replace(/^([ \t]*)export\s*\{([^}]*)\}\s*;?/gm, (m, ind, list) => {
+      for (const item of list.split(',').map((s) => s.trim()).filter(Boolean)) {
+        const [local, as = local] = item.split(/\s+as\s+/);
+        exported.push([local, as]);
+      }
+      return ind;
+    });
+  const exports = exported.map(([local, as]) => `module.exports.${as} = ${local};`).join(' ');
+  return `const __default = (m) => (m != null && m.default !== undefined ? m.default : m); ${body}\n${exports}\n`;
+}
+
+function load(file, source) {
+  const filename = path.join(process.cwd(), file);
+  const mod = new Module(filename, module);
+  mod.filename = filename;
+  mod.paths = Module._nodeModulePaths(process.cwd());
+  mod._compile(toCommonJS(source), filename);
+  mod.loaded = true;
+  return mod;
+}
+
+function main(solutionB64, testsB64, budget) {
+  const decode = (s) => Buffer.from(s, 'base64').toString('utf8');
+  eventPrefix = readNonce() + ' ';
+  outputBudget = Math.floor(budget / 2);
+  captureStdout();
+  process.on('uncaughtException', onStrayError);
+  process.on('unhandledRejection', onStrayError);
+
+  let solution = null;
+  Module._load = function (request, parent, isMain) {
+    if (['./solution', './solution.js', './main', './main.js'].includes(request) && solution) {
+      return solution.exports;
+    }
+    if (request === 'node:test') {
+      return testModule;
+    }
+    return originalLoad.call(this, request, parent, isMain);
+  };
+
+  try {
+    solution = load(SOLUTION_FILE, decode(solutionB64));
+    load(TESTS_FILE, decode(testsB64));
+  } catch (err) {
+    flushOutput();
+    const lines = String((err && err.stack) || err).split('\n');
+    const shown = lines.filter((line) => !/^\s+at /.test(line) || line.includes(SOLUTION_FILE) || line.includes(TESTS_FILE));
+    process.stderr.write(shown.map(relativize).join('\n') + '\n', () => process.exit(1));
+    return;
+  }
+
+  runSuite(root, []).then(
+    () => {
+      emit({ done: true });
+      // Timers left behind by the code under test must not keep the run alive.
+      process.exit(0);
+    },
+    (err) => {
+      flushOutput();
+      process.stderr.write(String((err && err.stack) || err) + '\n', () => process.exit(1));
+    },
+  );
+}
@@ -0,0 +1,458 @@
+# Unit-test harness for unit_tests mode (Python).
+#
+# The runner appends a call to main() with the base64-encoded solution and
+# test file, so this file runs as the program itself, inside the jail and
+# under the language's usual limits. The solution is importable as `solution`
+# (or `main`). The test file may hold unittest.TestCase classes as well as
+# pytest-style test_* functions and Test* classes using plain asserts; pytest
+# is not installed, so a small stand-in providing raises, approx, fail, skip,
+# mark.skip and mark.parametrize is importable as `pytest`.
+#
+# Progress is written to stdout as JSON lines, so a run cut short by a limit
+# still reports the tests that finished. The runner sends a random nonce as
+# the first line of stdin, read here before any user code is loaded, and
+# every event line starts with it and a space; the runner ignores lines
+# without it, so printing an event does not fake a result:
+#
+#   {"start": name}                     a test is about to run
+#   {"test": {"name", "status", "message", "details", "duration_ms"}}
+#   {"stdout": text}                    output printed by the code under test
+#   {"stdout_truncated": true}          later output was dropped
+#   {"done": true}                      every test ran
+#
+# A solution or test file that fails to load prints its traceback to stderr
+# and exits 1.
+
+import base64
+import inspect
+import io
+import itertools
+import json
+import linecache
+import math
+import os
+import re
+import sys
+import time
+import traceback
+import types
+import unittest
+
+SOLUTION_FILE = "solution.py"
+TESTS_FILE = "test_solution.py"
+USER_FILES = (SOLUTION_FILE, TESTS_FILE)
+
+MAX_MESSAGE = 1000
+MAX_DETAILS = 4000
+FLUSH_AT = 4096  # buffered output is emitted once it reaches this size
+
+
+class Events:
+    def __init__(self, stream, nonce, output_budget):
+        self.stream = stream
+        self.prefix = nonce + " "
+        self.output_budget = output_budget
+        self.output_used = 0
+        self.output_truncated = False
+        self.pending = []
+        self.pending_size = 0
+
+    def emit(self, event):
+        self.flush_output()
+        self.write(event)
+
+    def write(self, event):
+        self.stream.write(self.prefix + json.dumps(event, separators=(",", ":")) + "\n")
+        self.stream.flush()
+
+    def output(self, s):
+        room = self.output_budget - self.output_used
+        if len(s) > room:
+            s = s[: max(room, 0)]
+            if not self.output_truncated:
+                self.output_truncated = True
+                self.flush_output()
+                self.write({"stdout_truncated": True})
+        if s:
+            self.pending.append(s)
+            self.pending_size += len(s)
+            self.output_used += len(s)
+            if self.pending_size >= FLUSH_AT:
+                self.flush_output()
+
+    def flush_output(self):
+        if self.pending:
+            self.write({"stdout": "".join(self.pending)})
+            self.pending, self.pending_size = [], 0
+
+
+class Capture(io.TextIOBase):
+    """Stands in for sys.stdout while the solution and tests run."""
+
+    def __init__(self, events):
+        self.events = events
+
+    def writable(self):
+        return True
+
+    def write(self, s):
+        if not isinstance(s, str):
+            raise TypeError("write() argument must be str, not " + type(s).__name__)
+        self.events.output(s)
+        return len(s)
+
+
+# --- pytest stand-in -------------------------------------------------------
+
+
+class _Raises:
+    def __init__(self, expected, match):
+        self.expected = expected
+        self.match = match
+        self.value = None
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, exc_type, exc, tb):
+        if exc_type is None:
+            raise AssertionError("DID NOT RAISE %s" % getattr(self.expected, "__name__", self.expected))
+        if not issubclass(exc_type, self.expected):
+            return False
+        if self.match is not None and not re.search(self.match, str(exc)):
+            raise AssertionError("Regex pattern %r does not match %r" % (self.match, str(exc)))
+        self.value = exc
+        return True
+
+
+def _raises(expected, *args, match=None, **kwargs):
+    if args:
+        with _Raises(expected, match) as ctx:
+            args[0](*args[1:], **kwargs)
+        return ctx
+    return _Raises(expected, match)
+
+
+class _Approx:
+    def __init__(self, expected, rel=None, abs=None):
+        self.expected = expected
+        self.rel = 1e-6 if rel is None else rel
+        self.abs = 1e-12 if abs is None else abs
+
+    def _close(self, actual, expected):
+        if isinstance(expected, (list, tuple)):
+            return (
+                isinstance(actual, (list, tuple))
+                and len(actual) == len(expected)
+                and all(self._close(a, e) for a, e in zip(actual, expected))
+            )
+        if isinstance(expected, dict):
+            return (
+                isinstance(actual, dict)
+                and actual.keys() == expected.keys()
+                and all(self._close(actual[k], expected[k]) for k in expected)
+            )
+        try:
+            return math.isclose(actual, expected, rel_tol=self.rel, abs_tol=self.abs)
+        except TypeError:
+            return actual == expected
+
+    def __eq__(self, actual):
+        return self._close(actual, self.expected)
+
+    def __repr__(self):
+        return "approx(%r)" % (self.expected,)
+
+
+def _fail(reason=""):
+    raise AssertionError(reason)
+
+
+def _skip(reason=""):
+    raise unittest.SkipTest(reason)
+
+
+class _Marker:
+    """A mark with no effect, usable bare (@mark.slow) or called (@mark.timeout(5))."""
+
+    def __call__(self, *args, **kwargs):
+        if len(args) == 1 and callable(args[0]) and not kwargs:
+            return args[0]
+        return self
+
+
+class _Mark:
+    def parametrize(self, argnames, argvalues, ids=None):
+        if isinstance(argnames, str):
+            argnames = [n.strip() for n in argnames.split(",") if n.strip()]
+
+        def decorate(fn):
+            fn.__dict__.setdefault("_parametrize", []).insert(0, (list(argnames), list(argvalues)))
+            return fn
+
+        return decorate
+
+    def skip(self, reason=""):
+        if callable(reason):
+            reason._skip = ""
+            return reason
+
+        def decorate(fn):
+            fn._skip = reason
+            return fn
+
+        return decorate
+
+    def skipif(self, condition, reason=""):
+        def decorate(fn):
+            if condition:
+                fn._skip = reason
+            return fn
+
+        return decorate
+
+    def __getattr__(self, name):
+        return _Marker()
+
+
+def _pytest_module():
+    mod = types.ModuleType("pytest")
+    mod.raises = _raises
+    mod.approx = _Approx
+    mod.fail = _fail
+    mod.skip = _skip
+    mod.mark = _Mark()
+    return mod
+
+
+# --- collection -------------------------------------------------------------
+
+
+class FunctionCase(unittest.TestCase):
+    """Runs a pytest-style test function (or method of a Test* class)."""
+
+    def __init__(self, name, fn, kwargs, owner=None):
+        super().__init__("run_test")
+        self.name = name
+        self.fn = fn
+        self.kwargs = kwargs
+        self.owner = owner
+
+    def run_test(self):
+        reason = getattr(self.fn, "_skip", None)
+        if reason is not None:
+            raise unittest.SkipTest(reason)
+        if self.owner is None:
+            self.fn(**self.kwargs)
+            return
+        instance = self.owner()
+        _call_fixture(instance, "setup_method", self.fn)
+        try:
+            getattr(instance, self.fn.__name__)(**self.kwargs)
+        finally:
+            _call_fixture(instance, "teardown_method", self.fn)
+
+    def id(self):
+        return self.name
+
+    def __str__(self):
+        return self.name
+
+
+def _call_fixture(instance, name, fn):
+    method = getattr(instance, name, None)
+    if method is None:
+        return
+    if len(inspect.signature(method).parameters) >= 1:
+        method(fn)
+    else:
+        method()
+
+
+def _param_id(value, name, index):
+    if isinstance(value, (int, float, str, bool, type(None))):
+        return str(value)
+    return "%s%d" % (name, index)
+
+
+def _expand(name, fn):
+    """Yields (name, kwargs) for each parametrized case of fn."""
+    params = getattr(fn, "_parametrize", None)
+    if not params:
+        yield name, {}
+        return
+    axes = []
+    for argnames, argvalues in params:
+        axis = []
+        for i, values in enumerate(argvalues):
+            if len(argnames) == 1:
+                values = (values,)
+            ids = "-".join(_param_id(v, n, i) for n, v in zip(argnames, values))
+            axis.append((ids, dict(zip(argnames, values))))
+        axes.append(axis)
+    for combo in itertools.product(*axes):
+        kwargs = {}
+        for _, kw in combo:
+            kwargs.update(kw)
+        yield "%s[%s]" % (name, "-".join(ids for ids, _ in combo)), kwargs
+
+
+def collect(tests):
+    suite = unittest.TestSuite()
+    suite.addTests(unittest.TestLoader().loadTestsFromModule(tests))
+    for name, obj in list(vars(tests).items()):
+        if name.startswith("test") and isinstance(obj, types.FunctionType) and obj.__globals__ is vars(tests):
+            for case, kwargs in _expand(name, obj):
+                suite.addTest(FunctionCase(case, obj, kwargs))
+        elif (
+            name.startswith("Test")
+            and isinstance(obj, type)
+            and not issubclass(obj, unittest.TestCase)
+            and obj.__module__ == tests.__name__
+        ):
+            for attr, fn in vars(obj).items():
+                if attr.startswith("test") and isinstance(fn, types.FunctionType):
+                    for case, kwargs in _expand("%s.%s" % (name, attr), fn):
+                        suite.addTest(FunctionCase(case, fn, kwargs, owner=obj))
+    return suite
+
+
+# --- running ----------------------------------------------------------------
+
+
+def test_name(test):
+    if isinstance(test, FunctionCase):
+        return test.name
+    method = getattr(test, "_testMethodName", None)
+    if method is None:
+        return str(test)  # a class or module fixture error
+    return "%s.%s" % (type(test).__name__, method)
+
+
+def describe(err):
+    """Returns the message and user-file traceback of an exception."""
+    exc_type, exc, tb = err
+    frames = [f for f in traceback.extract_tb(tb) if f.filename in USER_FILES]
+    message = traceback.format_exception_only(exc_type, exc)[-1].strip()
+    if isinstance(exc, AssertionError) and not str(exc) and frames:
+        message = "AssertionError: %s" % frames[-1].line
+    details = "".join(traceback.format_list(frames) + traceback.format_exception_only(exc_type, exc))
+    return message[:MAX_MESSAGE], details[:MAX_DETAILS]
+
+
+class Result(unittest.TestResult):
+    def __init__(self, events):
+        super().__init__()
+        self.events = events
+        self.current = None
+        self.started = 0.0
+        self.outcome = None
+
+    def startTest(self, test):
+        super().startTest(test)
+        self.current = test
+        self.outcome = None
+        self.events.emit({"start": test_name(test)})
+        self.started = time.perf_counter()
+
+    def stopTest(self, test):
+        status, message, details = self.outcome or ("passed", "", "")
+        self.report(test, status, message, details, time.perf_counter() - self.started)
+        self.current = None
+        super().stopTest(test)
+
+    def report(self, test, status, message, details, seconds=0.0):
+        self.events.emit(
+            {
+                "test": {
+                    "name": test_name(test),
+                    "status": status,
+                    "message": message,
+                    "details": details,
+                    "duration_ms": round(seconds * 1000, 3),
+                }
+            }
+        )
+
+    def record(self, test, status, message="", details=""):
+        if test is not self.current:
+            # setUpClass and friends fail outside any test.
+            self.report(test, status, message, details)
+        elif self.outcome is None or self.outcome[0] == "passed":
+            self.outcome = (status, message, details)
+
+    def addSuccess(self, test):
+        self.record(test, "passed")
+
+    def addFailure(self, test, err):
+        self.record(test, "failed", *describe(err))
+
+    def addError(self, test, err):
+        self.record(test, "error", *describe(err))
+
+    def addSkip(self, test, reason):
+        self.record(test, "skipped", reason)
+
+    def addExpectedFailure(self, test, err):
+        self.record(test, "passed")
+
+    def addUnexpectedSuccess(self, test):
+        self.record(test, "failed", "unexpected success")
+
+    def addSubTest(self, test, subtest, err):
+        if err is not None:
+            status = "failed" if issubclass(err[0], test.failureException) else "error"
+            message, details = describe(err)
+            self.record(test, status, "%s: %s" % (subtest._subDescription(), message), details)
+
+
+def load(name, filename, source):
+    mod = types.ModuleType(name)
+    mod.__file__ = filename
+    sys.modules[name] = mod
+    exec(compile(source, filename, "exec"), vars(mod))
+    return mod
+
+
+def read_nonce():
+    """Reads the first line of stdin byte by byte, leaving the rest unbuffered
+    for the code under test."""
+    line = b""
+    while True:
+        c = os.read(0, 1)
+        if c in (b"", b"\n"):
+            return line.decode("ascii", "replace")
+        line += c
+
+
+def main(solution_b64, tests_b64, budget):
+    nonce = read_nonce()
+    solution = base64.b64decode(solution_b64).decode("utf-8")
+    tests = base64.b64decode(tests_b64).decode("utf-8")
+    for filename, source in ((SOLUTION_FILE, solution), (TESTS_FILE, tests)):
+        linecache.cache[filename] = (len(source), None, source.splitlines(True), filename)
+
+    real_stdout = sys.stdout
+    events = Events(real_stdout, nonce, budget // 2)
+    sys.stdout = Capture(events)
+    if "pytest" not in sys.modules:
+        sys.modules["pytest"] = _pytest_module()
+
+    try:
+        sys.modules["main"] = load("solution", SOLUTION_FILE, solution)
+        suite = collect(load("test_solution", TESTS_FILE, tests))
+    except BaseException as e:
+        events.flush_output()
+        tb = e.__traceback__
+        while tb is not None and tb.tb_frame.f_code.co_filename not in USER_FILES:
+            tb = tb.tb_next
+        traceback.print_exception(type(e), e, tb, file=sys.stderr)
+        sys.stderr.flush()
+        os._exit(1)
+
+    suite.run(Result(events))
+    events.emit({"done": True})
+    sys.stderr.flush()
+    # Threads or atexit hooks left behind by the code under test must not
+    # keep the run alive.
+    os._exit(0)
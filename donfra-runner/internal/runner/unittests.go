@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Test harnesses run a unit-test file against a solution in one process and
+// report each test as a JSON line prefixed with a per-run nonce, which they
+// read from the first line of stdin before loading any user code. Lines
+// without the nonce are output of the code under test. Languages name their
+// harness in TestHarness.
+var (
+	//go:embed tests_harness.py
+	pythonTestHarness string
+	//go:embed tests_harness.js
+	nodeTestHarness string
+)
+
+var testHarnesses = map[string]string{
+	"python": pythonTestHarness,
+	"node":   nodeTestHarness,
+}
+
+// Unit test statuses. A test fails on an assertion and errors on any other
+// exception.
+const (
+	UnitTestPassed  = "passed"
+	UnitTestFailed  = "failed"
+	UnitTestError   = "error"
+	UnitTestSkipped = "skipped"
+)
+
+// UnitTestResult is the outcome of one test of a unit_tests run.
+type UnitTestResult struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Message    string  `json:"message,omitempty"`
+	Details    string  `json:"details,omitempty"` // traceback within the solution and test file
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// testEvent is one line of harness output.
+type testEvent struct {
+	Start           *string         `json:"start"`
+	Test            *UnitTestResult `json:"test"`
+	Stdout          *string         `json:"stdout"`
+	StdoutTruncated bool            `json:"stdout_truncated"`
+	Done            bool            `json:"done"`
+}
+
+// unitTests runs req.TestCode against req.SourceCode under lang's test
+// harness. Tests that finished are reported even when the run is cut short;
+// a test still running at that point is reported as an error.
+func (r *Runner) unitTests(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int) ExecuteResult {
+	nonce := rand.Text()
+	harnessed := req
+	harnessed.Stdin = nonce + "\n" + req.Stdin
+	harnessed.SourceCode = fmt.Sprintf("%s\nmain(%q, %q, %d)\n", testHarnesses[lang.TestHarness],
+		base64.StdEncoding.EncodeToString([]byte(req.SourceCode)),
+		base64.StdEncoding.EncodeToString([]byte(req.TestCode)),
+		r.cfg.MaxOutputBytes)
+	result := r.run(ctx, lang, harnessed, timeoutMs, nil)
+
+	var stdout strings.Builder
+	var tests []UnitTestResult
+	var running *string
+	done, stdoutTruncated := false, false
+	lines := strings.Split(result.Stdout, "\n")
+	for i, line := range lines {
+		var ev testEvent
+		event, ok := strings.CutPrefix(line, nonce+" ")
+		if !ok || json.Unmarshal([]byte(event), &ev) != nil {
+			// Output written around the harness, or a line cut by the output limit.
+			if line != "" && !(result.StdoutTruncated && i == len(lines)-1) {
+				stdout.WriteString(line + "\n")
+			}
+			continue
+		}
+		switch {
+		case ev.Start != nil:
+			running = ev.Start
+		case ev.Test != nil:
+			tests = append(tests, *ev.Test)
+			running = nil
+		case ev.Stdout != nil:
+			stdout.WriteString(*ev.Stdout)
+		case ev.StdoutTruncated:
+			stdoutTruncated = true
+		case ev.Done:
+			done = true
+		}
+	}
+	result.Stdout = stdout.String()
+	result.StdoutTruncated = stdoutTruncated || result.StdoutTruncated
+
+	if !done {
+		if running != nil {
+			tests = append(tests, UnitTestResult{Name: *running, Status: UnitTestError, Message: failureReason(result)})
+		}
+		result.UnitTests = tests
+		switch {
+		case result.Status.ID == StatusAccepted:
+			result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+			result.Message = "tests exited before finishing"
+		case result.Status.ID == StatusRuntimeError && len(tests) == 0 && result.ExitCode != nil:
+			result.Message = "solution or test_code failed to load; see stderr"
+		}
+		return result
+	}
+
+	result.UnitTests = tests
+	passed, failed, skipped := 0, 0, 0
+	for _, t := range tests {
+		switch t.Status {
+		case UnitTestPassed:
+			passed++
+		case UnitTestSkipped:
+			skipped++
+		default:
+			failed++
+		}
+	}
+	switch {
+	case len(tests) == 0:
+		result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+		result.Message = "no tests found in test_code"
+	case failed > 0:
+		result.Status = ExecuteStatus{ID: StatusWrongAnswer, Description: "Wrong Answer"}
+		result.Message = fmt.Sprintf("%d of %d tests failed", failed, len(tests)-skipped)
+	default:
+		result.Message = fmt.Sprintf("%d tests passed", passed)
+		if skipped > 0 {
+			result.Message += fmt.Sprintf(", %d skipped", skipped)
+		}
+	}
+	return result
+}
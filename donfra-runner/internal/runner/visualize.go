@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// tracerSource is the Python step tracer, run in place of the program.
+//
+//go:embed visualize.py
+var tracerSource string
+
+// Trace is the step-by-step record of a visualize run. It is capped at
+// MaxTraceSteps steps and MaxOutputBytes of JSON; when either cap is reached
+// the program is stopped and Truncated is set.
+type Trace struct {
+	Steps     []TraceStep `json:"steps"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// TraceStep is the program state when a line is about to run ("line"), a
+// function was entered ("call") or is returning ("return"), or an exception
+// was raised ("exception").
+type TraceStep struct {
+	Event     string       `json:"event"`
+	Line      int          `json:"line"`
+	Stack     []TraceFrame `json:"stack"` // outermost first; the first is the module
+	Exception string       `json:"exception,omitempty"`
+
+	// Heap holds the objects referenced from Stack by {"ref": id}, keyed by
+	// id. Ids are stable across steps. Objects are encoded as
+	// {"type": "list"|"tuple"|"set"|"frozenset", "items", "len"},
+	// {"type": "dict", "entries": [{"key", "value"}], "len"},
+	// {"type": "instance", "class", "attrs"}, {"type": "class", "name", "attrs"},
+	// {"type": "function", "name"} or {"type": "other", "class", "repr"}.
+	Heap map[string]json.RawMessage `json:"heap"`
+
+	// Stdout is the program's output up to this step.
+	Stdout string `json:"stdout"`
+}
+
+// TraceFrame is one call on the stack. Values are JSON primitives (strings,
+// numbers that fit a double, booleans, null), {"ref": id} for heap objects,
+// or {"type": "int"|"float", "repr"} for other numbers.
+type TraceFrame struct {
+	Function    string          `json:"function"`
+	Line        int             `json:"line"`
+	Locals      []TraceVar      `json:"locals"`
+	ReturnValue json.RawMessage `json:"return_value,omitempty"`
+}
+
+type TraceVar struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+// visualize runs req's program under the step tracer and replaces the
+// tracer's output with the trace and the program's own stdout.
+func (r *Runner) visualize(ctx context.Context, lang Language, req ExecuteRequest, timeoutMs int) ExecuteResult {
+	traced := req
+	traced.SourceCode = tracerProgram(req.SourceCode, r.cfg.MaxOutputBytes, r.cfg.MaxTraceSteps)
+	result := r.run(ctx, lang, traced, timeoutMs, nil)
+
+	var doc struct {
+		Trace
+		Stdout          string `json:"stdout"`
+		StdoutTruncated bool   `json:"stdout_truncated"`
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), &doc); err != nil {
+		// The tracer was killed (time or memory limit) or the program
+		// bypassed it, e.g. with os._exit.
+		result.Stdout, result.StdoutTruncated = "", false
+		if result.Status.ID == StatusAccepted || (result.Status.ID == StatusRuntimeError && result.ExitCode != nil) {
+			result.Status = ExecuteStatus{ID: StatusRuntimeError, Description: "Runtime Error"}
+			result.Message = "program exited before its trace was written"
+		}
+		return result
+	}
+	result.Trace = &doc.Trace
+	result.Stdout, result.StdoutTruncated = doc.Stdout, doc.StdoutTruncated
+	return result
+}
+
+// tracerProgram returns the tracer with a call that runs source under it.
+func tracerProgram(source string, budget, maxSteps int) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(source))
+	return fmt.Sprintf("%s\nmain(%q, %d, %d)\n", tracerSource, encoded, budget, maxSteps)
+}
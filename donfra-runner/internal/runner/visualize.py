@@ -0,0 +1,258 @@
+# Step tracer for visualize mode.
+#
+# The runner appends a call to main() with the program's base64-encoded
+# source, so this file runs as the program itself, inside the jail and under
+# the language's usual limits. The program's stdout is captured and one JSON
+# document is written to the real stdout when it ends:
+#
+#   {"steps": [...], "truncated": bool, "stdout": str, "stdout_truncated": bool}
+#
+# The document never exceeds the byte budget: tracing stops (and the program
+# is stopped) once the steps would not fit, or after max_steps steps. The
+# exit status is the program's, and an uncaught exception's traceback goes to
+# stderr as it would without the tracer.
+
+import base64
+import io
+import itertools
+import json
+import linecache
+import math
+import sys
+import traceback
+import types
+
+MAX_ITEMS = 100  # elements shown per container
+MAX_REPR = 200  # characters shown of opaque objects
+
+
+class _Stop(BaseException):
+    """Raised into the program when the step or size budget is used up."""
+
+
+class _Output(io.TextIOBase):
+    """Captures the program's stdout, keeping at most limit characters."""
+
+    def __init__(self, limit):
+        self.limit = limit
+        self.parts = []
+        self.size = 0
+        self.truncated = False
+
+    def writable(self):
+        return True
+
+    def write(self, s):
+        if not isinstance(s, str):
+            raise TypeError("write() argument must be str, not " + type(s).__name__)
+        n = len(s)
+        room = self.limit - self.size
+        if n > room:
+            s = s[: max(room, 0)]
+            self.truncated = True
+        if s:
+            self.parts.append(s)
+            self.size += len(s)
+        return n
+
+    def getvalue(self):
+        if len(self.parts) > 1:
+            self.parts = ["".join(self.parts)]
+        return self.parts[0] if self.parts else ""
+
+
+class Tracer:
+    def __init__(self, budget, max_steps):
+        self.budget = budget
+        self.max_steps = max_steps
+        self.globals = {"__name__": "__main__", "__builtins__": __builtins__}
+        self.out = _Output(budget)
+        self.steps = []  # serialized steps
+        self.size = 0
+        self.truncated = False
+        self.stopped = False
+        # Heap ids stay stable across steps so the viewer can follow objects;
+        # encoded objects are kept alive so their id() is never reused.
+        self.ids = {}
+        self.keep = []
+
+    def trace(self, frame, event, arg):
+        if frame.f_globals is not self.globals:
+            return None
+        if event == "call" and frame.f_code.co_name == "<module>":
+            return self.trace
+        self.record(frame, event, arg)
+        return self.trace
+
+    def record(self, frame, event, arg):
+        if self.stopped or len(self.steps) >= self.max_steps:
+            self.stop()
+
+        frames = []
+        f = frame
+        while f is not None and f.f_globals is self.globals:
+            frames.append(f)
+            f = f.f_back
+        frames.reverse()
+
+        heap, pending = {}, []
+        stack = [
+            {
+                "function": f.f_code.co_name,
+                "line": f.f_lineno,
+                "locals": self.variables(f.f_locals.items(), heap, pending),
+            }
+            for f in frames
+        ]
+        step = {"event": event, "line": frame.f_lineno, "stack": stack}
+        if event == "return":
+            stack[-1]["return_value"] = self.value(arg, heap, pending)
+        elif event == "exception":
+            step["exception"] = traceback.format_exception_only(arg[0], arg[1])[-1].strip()
+
+        while pending:
+            hid, obj = pending.pop()
+            heap[str(hid)] = self.heap_object(obj, heap, pending)
+        step["heap"] = heap
+        step["stdout"] = self.out.getvalue()
+
+        data = json.dumps(step, separators=(",", ":"))
+        if self.size + len(data) + 1 > self.budget:
+            self.stop()
+        self.steps.append(data)
+        self.size += len(data) + 1
+
+    def stop(self):
+        self.truncated = True
+        self.stopped = True
+        raise _Stop
+
+    def variables(self, items, heap, pending):
+        return [
+            {"name": name, "value": self.value(value, heap, pending)}
+            for name, value in items
+            if not name.startswith("__") and not isinstance(value, types.ModuleType)
+        ]
+
+    def value(self, obj, heap, pending):
+        """Encodes obj inline if it is a primitive, else as a heap reference."""
+        if obj is None or isinstance(obj, (bool, str)):
+            return obj
+        if type(obj) is int:
+            if -(2**53) <= obj <= 2**53:
+                return obj
+            try:
+                return {"type": "int", "repr": str(obj)}
+            except ValueError:
+                return {"type": "int", "repr": "<%d-bit int>" % obj.bit_length()}
+        if type(obj) is float:
+            return obj if math.isfinite(obj) else {"type": "float", "repr": repr(obj)}
+
+        hid = self.ids.get(id(obj))
+        if hid is None:
+            hid = len(self.keep) + 1
+            self.ids[id(obj)] = hid
+            self.keep.append(obj)
+        if str(hid) not in heap:
+            heap[str(hid)] = None
+            pending.append((hid, obj))
+        return {"ref": hid}
+
+    def heap_object(self, obj, heap, pending):
+        def enc(x):
+            return self.value(x, heap, pending)
+
+        t = type(obj)
+        if t in (list, tuple, set, frozenset):
+            items = [enc(x) for x in itertools.islice(obj, MAX_ITEMS)]
+            return {"type": t.__name__, "items": items, "len": len(obj)}
+        if t is dict:
+            entries = [{"key": enc(k), "value": enc(v)} for k, v in itertools.islice(obj.items(), MAX_ITEMS)]
+            return {"type": "dict", "entries": entries, "len": len(obj)}
+        if isinstance(obj, (types.FunctionType, types.BuiltinFunctionType, types.MethodType)):
+            return {"type": "function", "name": getattr(obj, "__qualname__", t.__name__)}
+        if isinstance(obj, type):
+            attrs = []
+            if obj.__module__ == "__main__":
+                # Methods are shown even when they are dunders, like __init__.
+                items = [(k, v) for k, v in vars(obj).items() if isinstance(v, types.FunctionType) or not k.startswith("__")]
+                attrs = [{"name": k, "value": self.value(v, heap, pending)} for k, v in items]
+            return {"type": "class", "name": obj.__name__, "attrs": attrs}
+        if t.__module__ == "__main__" and hasattr(obj, "__dict__"):
+            items = itertools.islice(vars(obj).items(), MAX_ITEMS)
+            return {"type": "instance", "class": t.__name__, "attrs": self.variables(items, heap, pending)}
+        try:
+            text = repr(obj)
+        except Exception:
+            text = "<%s object>" % t.__name__
+        return {"type": "other", "class": t.__name__, "repr": text[:MAX_REPR]}
+
+    def document(self):
+        """Serializes the trace, dropping trailing steps until it fits the budget."""
+        stdout, stdout_truncated = self.out.getvalue(), self.out.truncated
+        while True:
+            tail = json.dumps(
+                {"truncated": self.truncated, "stdout": stdout, "stdout_truncated": stdout_truncated},
+                separators=(",", ":"),
+            )
+            if len('{"steps":[],') + self.size + len(tail) <= self.budget:
+                break
+            if self.steps:
+                self.size -= len(self.steps.pop()) + 1
+                self.truncated = True
+            else:
+                stdout = stdout[: len(stdout) // 2]
+                stdout_truncated = True
+        tail = json.dumps(
+            {"truncated": self.truncated, "stdout": stdout, "stdout_truncated": stdout_truncated},
+            separators=(",", ":"),
+        )
+        return '{"steps":[' + ",".join(self.steps) + "]," + tail[1:]
+
+
+def main(encoded, budget, max_steps):
+    source = base64.b64decode(encoded).decode("utf-8")
+    tracer = Tracer(budget, max_steps)
+    real_stdout = sys.stdout
+    status = 0
+
+    # Tracebacks quote the program's lines, not this file's.
+    linecache.cache["main.py"] = (len(source), None, source.splitlines(True), "main.py")
+    try:
+        code = compile(source, "main.py", "exec")
+    except SyntaxError:
+        # CPython quotes the offending line from the file named main.py,
+        # which is this one, so report the error of a name not on disk.
+        try:
+            compile(source, "<main.py>", "exec")
+        except SyntaxError as e:
+            e.filename = "main.py"
+            sys.stderr.write("".join(traceback.format_exception_only(type(e), e)))
+        real_stdout.write(tracer.document())
+        sys.exit(1)
+
+    sys.stdout = tracer.out
+    sys.settrace(tracer.trace)
+    try:
+        exec(code, tracer.globals)
+    except _Stop:
+        pass
+    except SystemExit as e:
+        if e.code is None or isinstance(e.code, int):
+            status = e.code or 0
+        else:
+            sys.stderr.write(str(e.code) + "\n")
+            status = 1
+    except BaseException as e:
+        tb = e.__traceback__
+        while tb is not None and tb.tb_frame.f_globals is not tracer.globals:
+            tb = tb.tb_next
+        traceback.print_exception(type(e), e, tb, file=sys.stderr)
+        status = 1
+    finally:
+        sys.settrace(None)
+        sys.stdout = real_stdout
+
+    real_stdout.write(tracer.document())
+    real_stdout.flush()
+    sys.exit(status)
@@ -0,0 +1,110 @@
+package submission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"donfra-runner/internal/runner"
+)
+
+// Submission is a stored submission: its current result and the caller it
+// belongs to.
+type Submission struct {
+	CallerID string               `json:"caller_id,omitempty"`
+	Result   runner.ExecuteResult `json:"result"`
+}
+
+// Store keeps submissions for a limited time.
+type Store interface {
+	Put(ctx context.Context, token string, sub Submission) error
+	// Get returns the stored submission; ok is false when the token is unknown or expired.
+	Get(ctx context.Context, token string) (sub Submission, ok bool, err error)
+}
+
+// RedisStore keeps submissions in Redis so any runner replica can serve them.
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisStore(client *redis.Client, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, ttl: ttl}
+}
+
+func (s *RedisStore) Put(ctx context.Context, token string, sub Submission) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisKey(token), data, s.ttl).Err()
+}
+
+func (s *RedisStore) Get(ctx context.Context, token string) (Submission, bool, error) {
+	var sub Submission
+	data, err := s.client.Get(ctx, redisKey(token)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return sub, false, nil
+	}
+	if err != nil {
+		return sub, false, err
+	}
+	if err := json.Unmarshal(data, &sub); err != nil {
+		return sub, false, err
+	}
+	return sub, true, nil
+}
+
+func redisKey(token string) string {
+	return "submission:" + token
+}
+
+// MemoryStore keeps submissions in process memory; used when Redis is not configured.
+type MemoryStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	sub       Submission
+	expiresAt time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Put(_ context.Context, token string, sub Submission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.evictExpired(now)
+	s.entries[token] = memoryEntry{sub: sub, expiresAt: now.Add(s.ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, token string) (Submission, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[token]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return Submission{}, false, nil
+	}
+	return entry.sub, true, nil
+}
+
+// evictExpired drops expired entries. Callers must hold s.mu.
+func (s *MemoryStore) evictExpired(now time.Time) {
+	for token, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, token)
+		}
+	}
+}
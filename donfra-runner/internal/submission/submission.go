@@ -0,0 +1,125 @@
+// Package submission runs executions asynchronously: a submission is accepted
+// immediately with a token, and its result is polled from a Store.
+package submission
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"donfra-runner/internal/runner"
+)
+
+// ErrNotFound is returned for unknown or expired tokens, and for submissions
+// of another caller.
+var ErrNotFound = errors.New("submission not found")
+
+// storeTimeout bounds each Store operation made from a background execution.
+const storeTimeout = 5 * time.Second
+
+type Service struct {
+	runner *runner.Runner
+	store  Store
+
+	mu   sync.Mutex
+	done map[string]chan struct{} // closed when the local execution finishes
+}
+
+func NewService(r *runner.Runner, store Store) *Service {
+	return &Service{runner: r, store: store, done: make(map[string]chan struct{})}
+}
+
+// Submit records req as queued, starts executing it in the background and
+// returns its token. The submission belongs to req.CallerID.
+func (s *Service) Submit(ctx context.Context, req runner.ExecuteRequest) (string, error) {
+	token := uuid.New().String()
+
+	queued := Submission{CallerID: req.CallerID, Result: statusResult(token, runner.StatusInQueue, "In Queue")}
+	if err := s.store.Put(ctx, token, queued); err != nil {
+		return "", err
+	}
+
+	ch := make(chan struct{})
+	s.mu.Lock()
+	s.done[token] = ch
+	s.mu.Unlock()
+
+	go s.process(token, req, ch)
+	return token, nil
+}
+
+// Get returns the current state of a submission made by callerID.
+func (s *Service) Get(ctx context.Context, token, callerID string) (runner.ExecuteResult, error) {
+	sub, ok, err := s.store.Get(ctx, token)
+	if err != nil {
+		return sub.Result, err
+	}
+	if !ok || sub.CallerID != callerID {
+		return runner.ExecuteResult{}, ErrNotFound
+	}
+	return sub.Result, nil
+}
+
+// Wait blocks until a submission started by this process finishes or ctx is
+// done, then returns its state.
+func (s *Service) Wait(ctx context.Context, token, callerID string) (runner.ExecuteResult, error) {
+	s.mu.Lock()
+	ch, ok := s.done[token]
+	s.mu.Unlock()
+
+	if ok {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+		}
+	}
+	return s.Get(context.WithoutCancel(ctx), token, callerID)
+}
+
+// process runs the submission detached from the request that created it.
+func (s *Service) process(token string, req runner.ExecuteRequest, done chan struct{}) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.done, token)
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	// The submission stays In Queue, with its place once it has to wait for a
+	// slot, until it starts Processing.
+	ctx := runner.WithQueueReporter(context.Background(), func(status runner.QueueStatus) {
+		if status.Position == 0 {
+			s.put(token, req.CallerID, statusResult(token, runner.StatusProcessing, "Processing"))
+			return
+		}
+		result := statusResult(token, runner.StatusInQueue, "In Queue")
+		result.Queue = &status
+		s.put(token, req.CallerID, result)
+	})
+
+	result := s.runner.Execute(ctx, req)
+	result.Token = token
+	s.put(token, req.CallerID, result)
+
+	log.Printf("submission %s lang=%d status=%d duration=%dms",
+		token, req.LanguageID, result.Status.ID, result.ExecutionTimeMs)
+}
+
+func (s *Service) put(token, callerID string, result runner.ExecuteResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := s.store.Put(ctx, token, Submission{CallerID: callerID, Result: result}); err != nil {
+		log.Printf("submission %s: store result: %v", token, err)
+	}
+}
+
+func statusResult(token string, id int, description string) runner.ExecuteResult {
+	return runner.ExecuteResult{
+		Token:  token,
+		Status: runner.ExecuteStatus{ID: id, Description: description},
+	}
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "donfra-runner"
+
+// StartSpan creates a new span for the given operation.
+// Usage:
+//
+//	ctx, span := tracing.StartSpan(ctx, "operation-name")
+//	defer span.End()
+func StartSpan(ctx context.Context, spanName string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := otel.Tracer(tracerName)
+	ctx, span := tracer.Start(ctx, spanName)
+
+	if len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+
+	return ctx, span
+}
+
+// RecordError records an error on the span and sets the status to error.
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Common attribute keys for consistency
+var (
+	AttrLanguage   = attribute.Key("exec.language")
+	AttrStatus     = attribute.Key("exec.status")
+	AttrJailMode   = attribute.Key("exec.jail_mode")
+	AttrTestCases  = attribute.Key("exec.test_cases")
+	AttrBatchItems = attribute.Key("exec.batch_items")
+	AttrCached     = attribute.Key("exec.cached")
+	AttrJobName    = attribute.Key("k8s.job_name")
+)
@@ -0,0 +1,21 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Middleware wraps the handler with OpenTelemetry HTTP instrumentation. The
+// incoming W3C trace context is extracted, so executions requested by
+// donfra-api appear as children of the API request span.
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(
+		next,
+		"donfra-runner",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			// Format: "HTTP POST /execute"
+			return "HTTP " + r.Method + " " + r.URL.Path
+		}),
+	)
+}
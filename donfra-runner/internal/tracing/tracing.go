@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// InitTracer initializes the OpenTelemetry tracer with Jaeger exporter.
+// Returns a cleanup function that should be called on shutdown.
+func InitTracer(serviceName, serviceVersion, jaegerEndpoint string) (func(context.Context) error, error) {
+	// If no Jaeger endpoint is configured, return a no-op shutdown function
+	if jaegerEndpoint == "" {
+		log.Println("[tracing] Jaeger endpoint not configured, tracing disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Create OTLP HTTP exporter for Jaeger
+	exporter, err := otlptracehttp.New(
+		context.Background(),
+		otlptracehttp.WithEndpoint(jaegerEndpoint),
+		otlptracehttp.WithInsecure(), // Use insecure for local development
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create resource with service information
+	res, err := resource.New(
+		context.Background(),
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(serviceVersion),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create trace provider with batch span processor
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()), // Sample all traces (adjust for production)
+	)
+
+	// Set global tracer provider
+	otel.SetTracerProvider(tp)
+
+	// Set global propagator for context propagation (W3C Trace Context)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	log.Printf("[tracing] Jaeger tracing initialized: %s -> %s", serviceName, jaegerEndpoint)
+
+	// Return shutdown function
+	return func(ctx context.Context) error {
+		// Create a context with timeout for shutdown
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return tp.Shutdown(ctx)
+	}, nil
+}
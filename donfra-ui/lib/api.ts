@@ -1,6 +1,6 @@
 // lib/api.ts
 // Use proxy for both browser and SSR to maintain same-origin for cookies
-import type { User, InterviewRoom } from '@/types/models';
+import type { User, InterviewRoom, Language } from '@/types/models';
 import type { LessonSummary, Lesson } from '@/features/lessons/lessonsTypes';
 
 export const API_BASE = process.env.NEXT_PUBLIC_API_BASE_URL || "/api";
@@ -234,6 +234,10 @@ export const api = {
         total_count: number;
       }>("/live/sessions"),
   },
+  runner: {
+    languages: () =>
+      getJSON<{ languages: Language[] }>("/languages"),
+  },
   ai: {
     chatStream: async (
       codeContent: string | undefined,
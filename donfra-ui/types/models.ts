@@ -29,3 +29,22 @@ export interface InterviewRoom {
   created_at: string;
   updated_at: string;
 }
+
+/**
+ * Language is an entry of the code runner's language catalog
+ */
+export interface Language {
+  id: number;
+  name: string;
+  version?: string;
+  source_file: string;
+  extension: string;
+  compiled: boolean;
+  default_timeout_ms: number;
+  max_timeout_ms: number;
+  memory_mb?: number;
+  visualize?: boolean;
+  test_harness?: string;
+  database?: string;
+  repl?: boolean;
+}
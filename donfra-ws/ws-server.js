@@ -13,6 +13,7 @@ const production = process.env.PRODUCTION != null
 const port = process.env.PORT || 6789
 const redisAddr = process.env.REDIS_ADDR || 'localhost:6379'
 const runnerUrl = process.env.RUNNER_URL || 'http://runner:8090'
+const runnerApiToken = process.env.RUNNER_API_TOKEN || ''
 
 // Initialize Redis publisher client
 let redisPublisher = null
@@ -63,7 +64,10 @@ async function executeCode(req) {
 
   const resp = await fetch(`${runnerUrl}/execute`, {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: {
+      'Content-Type': 'application/json',
+      ...(runnerApiToken && { Authorization: `Bearer ${runnerApiToken}` })
+    },
     body: JSON.stringify({ source_code, language_id, stdin, timeout_ms: 5000 }),
     signal: AbortSignal.timeout(12000)
   })
@@ -102,7 +106,6 @@ const server = http.createServer(async (request, response) => {
         console.error(`[${new Date().toISOString()}] Code execution error:`, err)
         response.writeHead(500, { 'Content-Type': 'application/json' })
         response.end(JSON.stringify({
-          token: 'ws-exec',
           status: { id: 11, description: 'Runtime Error' },
           message: err.message
         }))
@@ -184,8 +187,7 @@ wss.on('connection', (conn, req) => {
                 console.error(`[${new Date().toISOString()}] ❌ Code execution error:`, execErr)
                 conn.send(JSON.stringify({
                   type: 'execution-result',
-                  token: 'ws-exec',
-                  status: { id: 11, description: 'Runtime Error' },
+                          status: { id: 11, description: 'Runtime Error' },
                   message: execErr.message
                 }))
               }
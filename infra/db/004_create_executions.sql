@@ -0,0 +1,31 @@
+-- Migration: Create executions table
+-- Stores the history of code runs for per-user, per-room and admin browsing
+
+CREATE TABLE IF NOT EXISTS executions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    room_id VARCHAR(255) DEFAULT '',
+    lesson_slug VARCHAR(255) DEFAULT '',
+    token VARCHAR(64) DEFAULT '',
+    language_id INTEGER NOT NULL,
+    source_hash VARCHAR(64) NOT NULL,
+    stdin TEXT,
+    status_id INTEGER NOT NULL,
+    status_description VARCHAR(64),
+    execution_time_ms BIGINT DEFAULT 0,
+    stdout TEXT,
+    stderr TEXT,
+    compile_output TEXT,
+    message TEXT,
+    test_cases_total INTEGER DEFAULT 0,
+    test_cases_passed INTEGER DEFAULT 0,
+    truncated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes for the history views (newest first)
+CREATE INDEX IF NOT EXISTS idx_executions_user_id ON executions(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_executions_room_id ON executions(room_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_executions_lesson_slug ON executions(lesson_slug, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_executions_created_at ON executions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_executions_token ON executions(token) WHERE token <> '';